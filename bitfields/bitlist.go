@@ -13,6 +13,13 @@ type BitlistMeta interface {
 	lists.List
 }
 
+type ProgressiveBitlistMeta interface {
+	// Length (in bits) of the Bitlist.
+	SizedBits
+	// Marks the bitlist as progressive, without limit.
+	lists.ProgressiveList
+}
+
 type Bitlist interface {
 	Bitfield
 	BitlistMeta
@@ -0,0 +1,287 @@
+package dynamic
+
+import (
+	"encoding/binary"
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"math/big"
+)
+
+type BoolType struct{}
+
+var Bool = BoolType{}
+
+func (t BoolType) IsFixed() bool {
+	return true
+}
+
+func (t BoolType) FixedLen() uint64 {
+	return 1
+}
+
+func (t BoolType) MinLen() uint64 {
+	return 1
+}
+
+func (t BoolType) MaxLen() uint64 {
+	return 1
+}
+
+func (t BoolType) Default() Value {
+	return false
+}
+
+func (t BoolType) Check(v Value) error {
+	if _, ok := v.(bool); !ok {
+		return fmt.Errorf("expected bool value, got %T", v)
+	}
+	return nil
+}
+
+func (t BoolType) SizeOf(v Value) uint64 {
+	return 1
+}
+
+func (t BoolType) PutBasic(dst []byte, v Value) {
+	if v.(bool) {
+		dst[0] = 1
+	} else {
+		dst[0] = 0
+	}
+}
+
+func (t BoolType) ReadBasic(src []byte) (Value, error) {
+	switch src[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	default:
+		return nil, fmt.Errorf("bool value is invalid")
+	}
+}
+
+func (t BoolType) Encode(eb *EncodingWriter, v Value) error {
+	if v.(bool) {
+		return eb.WriteByte(1)
+	}
+	return eb.WriteByte(0)
+}
+
+func (t BoolType) Decode(dr *DecodingReader) (Value, error) {
+	b, err := dr.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return t.ReadBasic([]byte{b})
+}
+
+func (t BoolType) DryCheck(dr *DecodingReader) error {
+	_, err := t.Decode(dr)
+	return err
+}
+
+func (t BoolType) HashTreeRoot(h MerkleFn, v Value) (out [32]byte) {
+	t.PutBasic(out[:], v)
+	return
+}
+
+func (t BoolType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	w.WriteIndent(indent)
+	if v.(bool) {
+		w.Write("True")
+	} else {
+		w.Write("False")
+	}
+}
+
+func (t BoolType) String() string {
+	return "boolean"
+}
+
+// An unsigned integer type of 1, 2, 4, 8, 16 or 32 bytes.
+// Values are uint64, or *big.Int for the uint128 and uint256 types.
+type UintType struct {
+	byteLen uint64
+}
+
+var (
+	Uint8   = UintType{1}
+	Uint16  = UintType{2}
+	Uint32  = UintType{4}
+	Uint64  = UintType{8}
+	Uint128 = UintType{16}
+	Uint256 = UintType{32}
+)
+
+func NewUintType(byteLen uint64) (UintType, error) {
+	switch byteLen {
+	case 1, 2, 4, 8, 16, 32:
+		return UintType{byteLen}, nil
+	default:
+		return UintType{}, fmt.Errorf("uint of %d bytes is not supported", byteLen)
+	}
+}
+
+// If the values are *big.Int, instead of uint64
+func (t UintType) IsBig() bool {
+	return t.byteLen > 8
+}
+
+func (t UintType) IsFixed() bool {
+	return true
+}
+
+func (t UintType) FixedLen() uint64 {
+	return t.byteLen
+}
+
+func (t UintType) MinLen() uint64 {
+	return t.byteLen
+}
+
+func (t UintType) MaxLen() uint64 {
+	return t.byteLen
+}
+
+func (t UintType) Default() Value {
+	if t.IsBig() {
+		return new(big.Int)
+	}
+	return uint64(0)
+}
+
+func (t UintType) Check(v Value) error {
+	if t.IsBig() {
+		x, ok := v.(*big.Int)
+		if !ok || x == nil {
+			return fmt.Errorf("expected *big.Int value, got %T", v)
+		}
+		if x.Sign() < 0 {
+			return fmt.Errorf("uint value %s is negative", x.String())
+		}
+		if uint64(x.BitLen()) > t.byteLen*8 {
+			return fmt.Errorf("uint value %s does not fit in %d bytes", x.String(), t.byteLen)
+		}
+		return nil
+	}
+	x, ok := v.(uint64)
+	if !ok {
+		return fmt.Errorf("expected uint64 value, got %T", v)
+	}
+	if t.byteLen < 8 && x>>(t.byteLen*8) != 0 {
+		return fmt.Errorf("uint value %d does not fit in %d bytes", x, t.byteLen)
+	}
+	return nil
+}
+
+func (t UintType) SizeOf(v Value) uint64 {
+	return t.byteLen
+}
+
+func (t UintType) PutBasic(dst []byte, v Value) {
+	if t.IsBig() {
+		// big-endian bytes, reversed into little-endian
+		be := v.(*big.Int).Bytes()
+		for i := 0; i < len(be); i++ {
+			dst[i] = be[len(be)-1-i]
+		}
+		for i := len(be); i < int(t.byteLen); i++ {
+			dst[i] = 0
+		}
+		return
+	}
+	x := v.(uint64)
+	switch t.byteLen {
+	case 1:
+		dst[0] = byte(x)
+	case 2:
+		binary.LittleEndian.PutUint16(dst, uint16(x))
+	case 4:
+		binary.LittleEndian.PutUint32(dst, uint32(x))
+	default:
+		binary.LittleEndian.PutUint64(dst, x)
+	}
+}
+
+func (t UintType) ReadBasic(src []byte) (Value, error) {
+	if t.IsBig() {
+		be := make([]byte, t.byteLen)
+		for i := range be {
+			be[i] = src[len(be)-1-i]
+		}
+		return new(big.Int).SetBytes(be), nil
+	}
+	switch t.byteLen {
+	case 1:
+		return uint64(src[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(src)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(src)), nil
+	default:
+		return binary.LittleEndian.Uint64(src), nil
+	}
+}
+
+func (t UintType) Encode(eb *EncodingWriter, v Value) error {
+	var tmp [32]byte
+	t.PutBasic(tmp[:t.byteLen], v)
+	return eb.Write(tmp[:t.byteLen])
+}
+
+func (t UintType) Decode(dr *DecodingReader) (Value, error) {
+	var tmp [32]byte
+	if _, err := dr.Read(tmp[:t.byteLen]); err != nil {
+		return nil, err
+	}
+	return t.ReadBasic(tmp[:t.byteLen])
+}
+
+func (t UintType) DryCheck(dr *DecodingReader) error {
+	_, err := dr.Skip(t.byteLen)
+	return err
+}
+
+func (t UintType) HashTreeRoot(h MerkleFn, v Value) (out [32]byte) {
+	t.PutBasic(out[:t.byteLen], v)
+	return
+}
+
+func (t UintType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	w.WriteIndent(indent)
+	if t.IsBig() {
+		w.Write(v.(*big.Int).String())
+		return
+	}
+	x := v.(uint64)
+	switch t.byteLen {
+	case 1:
+		w.Write(fmt.Sprintf("0x%02x", x))
+	case 2:
+		if x == 0xFFFF {
+			w.Write("0xFFFF")
+		} else {
+			w.Write(fmt.Sprintf("%d", x))
+		}
+	case 4:
+		if x == 0xFFFFFFFF {
+			w.Write("0xFFFFFFFF")
+		} else {
+			w.Write(fmt.Sprintf("%d", x))
+		}
+	default:
+		if x == ^uint64(0) {
+			w.Write("0xFFFFFFFFFFFFFFFF")
+		} else {
+			w.Write(fmt.Sprintf("%d", x))
+		}
+	}
+}
+
+func (t UintType) String() string {
+	return fmt.Sprintf("uint%d", t.byteLen*8)
+}
@@ -0,0 +1,194 @@
+package dynamic
+
+import (
+	"fmt"
+	"github.com/protolambda/zssz/bitfields"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/types"
+)
+
+// A fixed-length series of bits. Values are []byte, with the unused bits of the last byte set to 0.
+type BitvectorType struct {
+	BitLen uint64
+}
+
+func NewBitvectorType(bitLen uint64) (*BitvectorType, error) {
+	if bitLen == 0 {
+		return nil, fmt.Errorf("bitvector must have a non-zero length")
+	}
+	return &BitvectorType{BitLen: bitLen}, nil
+}
+
+func (t *BitvectorType) byteLen() uint64 {
+	return (t.BitLen + 7) >> 3
+}
+
+func (t *BitvectorType) IsFixed() bool {
+	return true
+}
+
+func (t *BitvectorType) FixedLen() uint64 {
+	return t.byteLen()
+}
+
+func (t *BitvectorType) MinLen() uint64 {
+	return t.byteLen()
+}
+
+func (t *BitvectorType) MaxLen() uint64 {
+	return t.byteLen()
+}
+
+func (t *BitvectorType) Default() Value {
+	return make([]byte, t.byteLen())
+}
+
+func (t *BitvectorType) Check(v Value) error {
+	data, ok := v.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte value, got %T", v)
+	}
+	if uint64(len(data)) != t.byteLen() {
+		return fmt.Errorf("expected %d bytes, got %d", t.byteLen(), len(data))
+	}
+	return bitfields.BitvectorCheck(data, t.BitLen)
+}
+
+func (t *BitvectorType) SizeOf(v Value) uint64 {
+	return t.byteLen()
+}
+
+func (t *BitvectorType) Encode(eb *EncodingWriter, v Value) error {
+	return eb.Write(v.([]byte))
+}
+
+func (t *BitvectorType) Decode(dr *DecodingReader) (Value, error) {
+	data := make([]byte, t.byteLen())
+	if _, err := dr.Read(data); err != nil {
+		return nil, err
+	}
+	if err := bitfields.BitvectorCheck(data, t.BitLen); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (t *BitvectorType) DryCheck(dr *DecodingReader) error {
+	if byteLen := t.byteLen(); byteLen > 1 {
+		if _, err := dr.Skip(byteLen - 1); err != nil {
+			return err
+		}
+	}
+	last, err := dr.ReadByte()
+	if err != nil {
+		return err
+	}
+	return bitfields.BitvectorCheckLastByte(last, t.BitLen)
+}
+
+func (t *BitvectorType) HashTreeRoot(h MerkleFn, v Value) [32]byte {
+	return packedRoot(h, v.([]byte), t.byteLen())
+}
+
+func (t *BitvectorType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	w.WriteIndent(indent)
+	w.Write(fmt.Sprintf("%08b", v.([]byte)))
+}
+
+func (t *BitvectorType) String() string {
+	return fmt.Sprintf("Bitvector[%d]", t.BitLen)
+}
+
+// A variable-length series of bits, up to the limit.
+// Values are []byte, including the delimiting bit, as in the bitfields package.
+type BitlistType struct {
+	Limit uint64
+}
+
+func NewBitlistType(limit uint64) (*BitlistType, error) {
+	return &BitlistType{Limit: limit}, nil
+}
+
+func (t *BitlistType) IsFixed() bool {
+	return false
+}
+
+func (t *BitlistType) FixedLen() uint64 {
+	return 0
+}
+
+// Includes the delimiting bit.
+func (t *BitlistType) MinLen() uint64 {
+	return 1
+}
+
+func (t *BitlistType) MaxLen() uint64 {
+	return (t.Limit >> 3) + 1
+}
+
+// The empty bitlist, a single delimiting bit.
+func (t *BitlistType) Default() Value {
+	return []byte{1}
+}
+
+func (t *BitlistType) Check(v Value) error {
+	data, ok := v.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte value, got %T", v)
+	}
+	return bitfields.BitlistCheck(data, t.Limit)
+}
+
+func (t *BitlistType) SizeOf(v Value) uint64 {
+	return uint64(len(v.([]byte)))
+}
+
+func (t *BitlistType) Encode(eb *EncodingWriter, v Value) error {
+	return eb.Write(v.([]byte))
+}
+
+func (t *BitlistType) Decode(dr *DecodingReader) (Value, error) {
+	data := make([]byte, dr.GetBytesSpan())
+	if _, err := dr.Read(data); err != nil {
+		return nil, err
+	}
+	if err := bitfields.BitlistCheck(data, t.Limit); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (t *BitlistType) DryCheck(dr *DecodingReader) error {
+	span := dr.GetBytesSpan()
+	if err := bitfields.BitlistCheckByteLen(span, t.Limit); err != nil {
+		return err
+	}
+	// 0 span is already checked by BitlistCheckByteLen
+	if _, err := dr.Skip(span - 1); err != nil {
+		return err
+	}
+	last, err := dr.ReadByte()
+	if err != nil {
+		return err
+	}
+	return bitfields.BitlistCheckLastByte(last, t.Limit-((span-1)<<3))
+}
+
+func (t *BitlistType) HashTreeRoot(h MerkleFn, v Value) [32]byte {
+	leaf, leafCount, bitLen := types.BitlistLeaves(v.([]byte))
+	leafLimit := (((t.Limit + 7) >> 3) + 31) >> 5
+	return h.MixIn(merkle.Merkleize(h, leafCount, leafLimit, leaf), bitLen)
+}
+
+func (t *BitlistType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	w.WriteIndent(indent)
+	w.Write(fmt.Sprintf("%08b", v.([]byte)))
+}
+
+func (t *BitlistType) String() string {
+	return fmt.Sprintf("Bitlist[%d]", t.Limit)
+}
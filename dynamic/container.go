@@ -0,0 +1,284 @@
+package dynamic
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+	. "github.com/protolambda/zssz/pretty"
+	"strings"
+)
+
+type Field struct {
+	Name string
+	Type Type
+}
+
+// A container of fields. Values are []Value, a value per field, in field order.
+type ContainerType struct {
+	Name   string
+	Fields []Field
+}
+
+func NewContainerType(name string, fields []Field) (*ContainerType, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("container %s must have at least one field", name)
+	}
+	names := make(map[string]struct{}, len(fields))
+	for i, f := range fields {
+		if f.Type == nil {
+			return nil, fmt.Errorf("field %d (%s) of container %s has no type", i, f.Name, name)
+		}
+		if _, ok := names[f.Name]; ok {
+			return nil, fmt.Errorf("container %s has duplicate field %s", name, f.Name)
+		}
+		names[f.Name] = struct{}{}
+	}
+	return &ContainerType{Name: name, Fields: fields}, nil
+}
+
+func (t *ContainerType) IsFixed() bool {
+	for _, f := range t.Fields {
+		if !f.Type.IsFixed() {
+			return false
+		}
+	}
+	return true
+}
+
+// The size of the fixed part: fixed-size fields, and offsets for variable-size fields
+func (t *ContainerType) FixedLen() uint64 {
+	out := uint64(0)
+	for _, f := range t.Fields {
+		if f.Type.IsFixed() {
+			out += f.Type.FixedLen()
+		} else {
+			out += offsetLen
+		}
+	}
+	return out
+}
+
+func (t *ContainerType) MinLen() uint64 {
+	out := uint64(0)
+	for _, f := range t.Fields {
+		if !f.Type.IsFixed() {
+			out += offsetLen
+		}
+		out += f.Type.MinLen()
+	}
+	return out
+}
+
+func (t *ContainerType) MaxLen() uint64 {
+	out := uint64(0)
+	for _, f := range t.Fields {
+		if !f.Type.IsFixed() {
+			out += offsetLen
+		}
+		out += f.Type.MaxLen()
+	}
+	return out
+}
+
+func (t *ContainerType) Default() Value {
+	values := make([]Value, len(t.Fields))
+	for i, f := range t.Fields {
+		values[i] = f.Type.Default()
+	}
+	return values
+}
+
+func (t *ContainerType) Check(v Value) error {
+	values, ok := v.([]Value)
+	if !ok {
+		return fmt.Errorf("expected []Value value, got %T", v)
+	}
+	if len(values) != len(t.Fields) {
+		return fmt.Errorf("container %s has %d fields, got %d values", t.Name, len(t.Fields), len(values))
+	}
+	for i, f := range t.Fields {
+		if err := f.Type.Check(values[i]); err != nil {
+			return fmt.Errorf("field %s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func (t *ContainerType) SizeOf(v Value) uint64 {
+	values := v.([]Value)
+	out := t.FixedLen()
+	for i, f := range t.Fields {
+		if !f.Type.IsFixed() {
+			out += f.Type.SizeOf(values[i])
+		}
+	}
+	return out
+}
+
+func (t *ContainerType) Encode(eb *EncodingWriter, v Value) error {
+	values := v.([]Value)
+	// the previous offset, to calculate a new offset from, starting after the fixed data.
+	prevOffset := t.FixedLen()
+	// span of the previous var-size element
+	prevSize := uint64(0)
+	for i, f := range t.Fields {
+		if f.Type.IsFixed() {
+			if err := f.Type.Encode(eb, values[i]); err != nil {
+				return err
+			}
+		} else {
+			offset, err := eb.WriteOffset(prevOffset, prevSize)
+			if err != nil {
+				return err
+			}
+			prevOffset = offset
+			prevSize = f.Type.SizeOf(values[i])
+		}
+	}
+	for i, f := range t.Fields {
+		if !f.Type.IsFixed() {
+			if err := f.Type.Encode(eb, values[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Process the fixed-size fields, and read the offsets of the variable-size fields
+func (t *ContainerType) processFixedPart(dr *DecodingReader, fieldHandler func(i int, f *Field) error) ([]uint64, error) {
+	offsets := make([]uint64, 0, len(t.Fields))
+	startIndex := dr.Index()
+	fixedI := startIndex
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		if f.Type.IsFixed() {
+			fixedI += f.Type.FixedLen()
+			if err := fieldHandler(i, f); err != nil {
+				return nil, err
+			}
+		} else {
+			fixedI += offsetLen
+			offset, err := dr.ReadOffset()
+			if err != nil {
+				return nil, err
+			}
+			offsets = append(offsets, offset)
+		}
+		if i := dr.Index(); i != fixedI {
+			return nil, fmt.Errorf("fixed part had different size than expected, now at %d, expected to be at %d", i, fixedI)
+		}
+	}
+	return offsets, nil
+}
+
+// Process the variable-size fields, each with a reader scoped to the field, as defined by the offsets.
+func (t *ContainerType) processDynamicPart(dr *DecodingReader, offsets []uint64, fieldHandler func(scoped *DecodingReader, i int, f *Field) error) error {
+	j := 0
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		if f.Type.IsFixed() {
+			continue
+		}
+		currentOffset := offsets[j]
+		var scope uint64
+		if next := j + 1; next < len(offsets) {
+			if nextOffset := offsets[next]; nextOffset >= currentOffset {
+				scope = nextOffset - currentOffset
+			} else {
+				return fmt.Errorf("offset %d for field %s is invalid", j, f.Name)
+			}
+		} else {
+			scope = dr.Max() - currentOffset
+		}
+		if realOffset := dr.Index(); currentOffset != realOffset {
+			return fmt.Errorf("expected to be at %d bytes, but currently at %d", currentOffset, realOffset)
+		}
+		scoped, err := dr.Scope(scope)
+		if err != nil {
+			return err
+		}
+		if err := fieldHandler(scoped, i, f); err != nil {
+			return err
+		}
+		dr.UpdateIndexFromScoped(scoped)
+		j++
+	}
+	return nil
+}
+
+func (t *ContainerType) Decode(dr *DecodingReader) (Value, error) {
+	values := make([]Value, len(t.Fields))
+	offsets, err := t.processFixedPart(dr, func(i int, f *Field) error {
+		fieldV, err := f.Type.Decode(dr)
+		values[i] = fieldV
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = t.processDynamicPart(dr, offsets, func(scoped *DecodingReader, i int, f *Field) error {
+		fieldV, err := f.Type.Decode(scoped)
+		values[i] = fieldV
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (t *ContainerType) DryCheck(dr *DecodingReader) error {
+	offsets, err := t.processFixedPart(dr, func(i int, f *Field) error {
+		return f.Type.DryCheck(dr)
+	})
+	if err != nil {
+		return err
+	}
+	return t.processDynamicPart(dr, offsets, func(scoped *DecodingReader, i int, f *Field) error {
+		return f.Type.DryCheck(scoped)
+	})
+}
+
+func (t *ContainerType) HashTreeRoot(h MerkleFn, v Value) [32]byte {
+	values := v.([]Value)
+	leaf := func(i uint64) []byte {
+		r := t.Fields[i].Type.HashTreeRoot(h, values[i])
+		return r[:]
+	}
+	leafCount := uint64(len(t.Fields))
+	return merkle.Merkleize(h, leafCount, leafCount, leaf)
+}
+
+func (t *ContainerType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	values := v.([]Value)
+	w.WriteIndent(indent)
+	w.Write("{\n")
+	for i, f := range t.Fields {
+		w.WriteIndent(indent + 1)
+		w.Write(f.Name)
+		w.Write(":\n")
+		f.Type.Pretty(indent+3, w, values[i])
+		if i == len(t.Fields)-1 {
+			w.Write("\n")
+		} else {
+			w.Write(",\n")
+		}
+	}
+	w.WriteIndent(indent)
+	w.Write("}")
+}
+
+// The container name, or the fields if the container has no name
+func (t *ContainerType) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	fields := make([]string, len(t.Fields))
+	for i, f := range t.Fields {
+		fields[i] = f.Name + ": " + f.Type.String()
+	}
+	return "Container{" + strings.Join(fields, ", ") + "}"
+}
@@ -0,0 +1,85 @@
+// Package dynamic implements SSZ types that are described at runtime, with generic value trees,
+// instead of with Go types and reflection. The results are the same as those of the equivalent static definitions.
+package dynamic
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"io"
+)
+
+func Decode(r io.Reader, bytesLen uint64, typ Type) (Value, error) {
+	if bytesLen < typ.MinLen() {
+		return nil, fmt.Errorf("expected object length is larger than given bytesLen")
+	}
+	unscoped := NewDecodingReader(r)
+	dr, err := unscoped.Scope(bytesLen)
+	if err != nil {
+		return nil, err
+	}
+	v, err := typ.Decode(dr)
+	if err != nil {
+		return nil, err
+	}
+	if readCount := dr.Index(); readCount != bytesLen {
+		return nil, fmt.Errorf("read total of %d bytes, but expected %d", readCount, bytesLen)
+	}
+	return v, nil
+}
+
+func DryCheck(r io.Reader, bytesLen uint64, typ Type) error {
+	if bytesLen < typ.MinLen() {
+		return fmt.Errorf("expected object length is larger than given bytesLen")
+	}
+	unscoped := NewDecodingReader(r)
+	dr, err := unscoped.Scope(bytesLen)
+	if err != nil {
+		return err
+	}
+	if err := typ.DryCheck(dr); err != nil {
+		return err
+	}
+	if readCount := dr.Index(); readCount != bytesLen {
+		return fmt.Errorf("read total of %d bytes, but expected %d", readCount, bytesLen)
+	}
+	return nil
+}
+
+// Errors if the value is not valid for the type.
+func SizeOf(v Value, typ Type) (uint64, error) {
+	if err := typ.Check(v); err != nil {
+		return 0, err
+	}
+	return typ.SizeOf(v), nil
+}
+
+// Errors if the value is not valid for the type, nothing is written in that case.
+func Encode(w io.Writer, v Value, typ Type) (n int, err error) {
+	if err := typ.Check(v); err != nil {
+		return 0, err
+	}
+	ew := NewEncodingWriter(w)
+	err = typ.Encode(ew, v)
+	return ew.Written(), err
+}
+
+// Errors if the value is not valid for the type, nothing is written in that case.
+func Pretty(w io.Writer, indent string, v Value, typ Type) error {
+	if err := typ.Check(v); err != nil {
+		return err
+	}
+	pw := NewPrettyWriter(w, indent)
+	typ.Pretty(0, pw, v)
+	return nil
+}
+
+// Errors if the value is not valid for the type.
+func HashTreeRoot(h MerkleFn, v Value, typ Type) ([32]byte, error) {
+	if err := typ.Check(v); err != nil {
+		return [32]byte{}, err
+	}
+	return typ.HashTreeRoot(h, v), nil
+}
@@ -0,0 +1,361 @@
+package dynamic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/protolambda/zssz"
+	"github.com/protolambda/zssz/bitfields"
+	"github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/uints"
+	"github.com/protolambda/zssz/unions"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bitvec10 [2]byte
+
+func (*bitvec10) BitLen() uint64 { return 10 }
+
+type bitlist9 []byte
+
+func (*bitlist9) Limit() uint64   { return 9 }
+func (b bitlist9) BitLen() uint64 { return bitfields.BitlistLen(b) }
+
+type pair struct {
+	X uint16
+	Y []byte `ssz-max:"4"`
+}
+
+type pairUnion unions.Union
+
+func (*pairUnion) UnionOptions() []interface{} {
+	return []interface{}{nil, (*uint32)(nil), (*pair)(nil)}
+}
+
+type staticStruct struct {
+	A bool
+	B uint8
+	C uint64
+	D uints.Uint256
+	E [3]uint16
+	F []uint32 `ssz-max:"5"`
+	G [4]byte
+	H []byte `ssz-max:"40"`
+	I bitvec10
+	J bitlist9
+	K []pair `ssz-max:"3"`
+	L [2]pair
+	M [][]uint16 `ssz-max:"2,3"`
+	N pairUnion
+	O pairUnion
+}
+
+// Named like the Go type, the union pretty-prints the type name of the selected option.
+func pairType() *ContainerType {
+	return &ContainerType{Name: "dynamic.pair", Fields: []Field{
+		{"X", Uint16},
+		{"Y", &ListType{Uint8, 4}},
+	}}
+}
+
+func dynamicStructType() *ContainerType {
+	pairUnionType := &UnionType{Options: []Type{nil, Uint32, pairType()}}
+	return &ContainerType{Name: "StaticStruct", Fields: []Field{
+		{"A", Bool},
+		{"B", Uint8},
+		{"C", Uint64},
+		{"D", Uint256},
+		{"E", &VectorType{Uint16, 3}},
+		{"F", &ListType{Uint32, 5}},
+		{"G", &VectorType{Uint8, 4}},
+		{"H", &ListType{Uint8, 40}},
+		{"I", &BitvectorType{10}},
+		{"J", &BitlistType{9}},
+		{"K", &ListType{pairType(), 3}},
+		{"L", &VectorType{pairType(), 2}},
+		{"M", &ListType{&ListType{Uint16, 3}, 2}},
+		{"N", pairUnionType},
+		{"O", pairUnionType},
+	}}
+}
+
+func testValues() (*staticStruct, Value) {
+	d := new(big.Int).Lsh(big.NewInt(0xabcd), 200)
+	var dLimbs uints.Uint256
+	if err := dLimbs.SetBig(d); err != nil {
+		panic(err)
+	}
+	static := &staticStruct{
+		A: true,
+		B: 0x42,
+		C: 123456789,
+		D: dLimbs,
+		E: [3]uint16{1, 0xFFFF, 3},
+		F: []uint32{4, 5},
+		G: [4]byte{0xde, 0xad, 0xbe, 0xef},
+		H: []byte("hello world, more than one chunk long!"),
+		I: bitvec10{0xff, 0x02},
+		J: bitlist9{0x05, 0x03},
+		K: []pair{{X: 1, Y: []byte{1, 2}}, {X: 2, Y: nil}},
+		L: [2]pair{{X: 3, Y: []byte{3}}, {X: 4, Y: []byte{4, 4, 4, 4}}},
+		M: [][]uint16{{1, 2, 3}, nil},
+		N: pairUnion{Selector: 2, Value: &pair{X: 5, Y: []byte{5}}},
+		O: pairUnion{Selector: 0},
+	}
+	dyn := []Value{
+		true,
+		uint64(0x42),
+		uint64(123456789),
+		d,
+		[]Value{uint64(1), uint64(0xFFFF), uint64(3)},
+		[]Value{uint64(4), uint64(5)},
+		[]byte{0xde, 0xad, 0xbe, 0xef},
+		[]byte("hello world, more than one chunk long!"),
+		[]byte{0xff, 0x02},
+		[]byte{0x05, 0x03},
+		[]Value{
+			[]Value{uint64(1), []byte{1, 2}},
+			[]Value{uint64(2), []byte{}},
+		},
+		[]Value{
+			[]Value{uint64(3), []byte{3}},
+			[]Value{uint64(4), []byte{4, 4, 4, 4}},
+		},
+		[]Value{
+			[]Value{uint64(1), uint64(2), uint64(3)},
+			[]Value{},
+		},
+		&UnionValue{Selector: 2, Value: []Value{uint64(5), []byte{5}}},
+		&UnionValue{Selector: 0},
+	}
+	return static, dyn
+}
+
+func TestStaticEquivalence(t *testing.T) {
+	static, dyn := testValues()
+	staticSSZ := zssz.GetSSZ((*staticStruct)(nil))
+	typ := dynamicStructType()
+	hFn := htr.HashFn(sha256.Sum256)
+
+	var staticBuf, dynBuf bytes.Buffer
+	if _, err := zssz.Encode(&staticBuf, static, staticSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(&dynBuf, dyn, typ); err != nil {
+		t.Fatal(err)
+	}
+	encoded := staticBuf.Bytes()
+	if !bytes.Equal(encoded, dynBuf.Bytes()) {
+		t.Fatalf("encoding differs:\nstatic:  %x\ndynamic: %x", encoded, dynBuf.Bytes())
+	}
+	if size, err := SizeOf(dyn, typ); err != nil {
+		t.Fatal(err)
+	} else if size != uint64(len(encoded)) {
+		t.Errorf("size %d does not match encoding length %d", size, len(encoded))
+	}
+	if typ.MinLen() != staticSSZ.MinLen() || typ.MaxLen() != staticSSZ.MaxLen() {
+		t.Errorf("length bounds differ: static [%d, %d], dynamic [%d, %d]",
+			staticSSZ.MinLen(), staticSSZ.MaxLen(), typ.MinLen(), typ.MaxLen())
+	}
+
+	staticRoot := zssz.HashTreeRoot(hFn, static, staticSSZ)
+	if root, err := HashTreeRoot(hFn, dyn, typ); err != nil {
+		t.Fatal(err)
+	} else if root != staticRoot {
+		t.Errorf("hash-tree-root differs: static %x, dynamic %x", staticRoot, root)
+	}
+
+	var staticPretty, dynPretty strings.Builder
+	zssz.Pretty(&staticPretty, "  ", static, staticSSZ)
+	if err := Pretty(&dynPretty, "  ", dyn, typ); err != nil {
+		t.Fatal(err)
+	}
+	if staticPretty.String() != dynPretty.String() {
+		t.Errorf("pretty output differs:\nstatic:\n%s\ndynamic:\n%s", staticPretty.String(), dynPretty.String())
+	}
+
+	if err := DryCheck(bytes.NewReader(encoded), uint64(len(encoded)), typ); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(bytes.NewReader(encoded), uint64(len(encoded)), typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, dyn) {
+		t.Errorf("decoded value differs:\nexpected: %v\ngot: %v", dyn, decoded)
+	}
+}
+
+type bytes4 []byte
+
+func (*bytes4) Limit() uint64 { return 4 }
+
+type bytes4List2 []bytes4
+
+func (*bytes4List2) Limit() uint64 { return 2 }
+
+func TestListMaxLen(t *testing.T) {
+	typ := &ListType{&ListType{Uint8, 4}, 2}
+	staticSSZ := zssz.GetSSZ((*bytes4List2)(nil))
+	// a full list of variable-size elements, with an offset per element
+	var buf bytes.Buffer
+	if _, err := Encode(&buf, []Value{[]byte{1, 2, 3, 4}, []byte{5, 6, 7, 8}}, typ); err != nil {
+		t.Fatal(err)
+	}
+	if max := uint64(buf.Len()); typ.MaxLen() != max || staticSSZ.MaxLen() != max {
+		t.Errorf("expected max length %d, got static %d, dynamic %d", max, staticSSZ.MaxLen(), typ.MaxLen())
+	}
+}
+
+func TestDefault(t *testing.T) {
+	staticSSZ := zssz.GetSSZ((*staticStruct)(nil))
+	typ := dynamicStructType()
+	// the static default has an empty bitlist, which is invalid without the delimiting bit.
+	static := &staticStruct{J: bitlist9{1}}
+
+	var staticBuf, dynBuf bytes.Buffer
+	if _, err := zssz.Encode(&staticBuf, static, staticSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(&dynBuf, typ.Default(), typ); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(staticBuf.Bytes(), dynBuf.Bytes()) {
+		t.Fatalf("encoding differs:\nstatic:  %x\ndynamic: %x", staticBuf.Bytes(), dynBuf.Bytes())
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	typ := dynamicStructType()
+	_, dyn := testValues()
+	values := dyn.([]Value)
+	values[5] = []Value{uint64(1), uint64(2), uint64(3), uint64(4), uint64(5), uint64(6)}
+	if _, err := Encode(&bytes.Buffer{}, dyn, typ); err == nil {
+		t.Error("expected list limit error")
+	}
+	_, dyn = testValues()
+	values = dyn.([]Value)
+	values[1] = uint64(0x100)
+	if _, err := HashTreeRoot(htr.HashFn(sha256.Sum256), dyn, typ); err == nil {
+		t.Error("expected uint8 overflow error")
+	}
+	_, dyn = testValues()
+	values = dyn.([]Value)
+	values[13] = &UnionValue{Selector: 1, Value: []Value{uint64(5), []byte{5}}}
+	if _, err := Encode(&bytes.Buffer{}, dyn, typ); err == nil {
+		t.Error("expected union option value error")
+	}
+
+	for _, tt := range []struct {
+		typ   Type
+		input string
+	}{
+		{Bool, "02"},
+		// unused bits set
+		{&BitvectorType{10}, "ff0c"},
+		// missing delimiting bit
+		{&BitlistType{9}, "0500"},
+		{&ListType{Uint16, 2}, "010002000300"},
+		// None with a value
+		{&UnionType{Options: []Type{nil, Uint8}}, "0001"},
+		{&UnionType{Options: []Type{nil, Uint8}}, "02"},
+	} {
+		data, _ := hex.DecodeString(tt.input)
+		if _, err := Decode(bytes.NewReader(data), uint64(len(data)), tt.typ); err == nil {
+			t.Errorf("expected decoding error for %s input %s", tt.typ.String(), tt.input)
+		}
+		if err := DryCheck(bytes.NewReader(data), uint64(len(data)), tt.typ); err == nil {
+			t.Errorf("expected dry-check error for %s input %s", tt.typ.String(), tt.input)
+		}
+	}
+}
+
+const specSource = `
+class Epoch(uint64):
+    pass
+
+Root = Bytes32
+BLSSignature = ByteVector[96]
+
+class Checkpoint(Container):
+    epoch: Epoch
+    root: Root
+
+class Attestation(Container):
+    """
+    An attestation, with the checkpoint defined before.
+    """
+    aggregation_bits: Bitlist[MAX_VALIDATORS_PER_COMMITTEE * MAX_COMMITTEES_PER_SLOT]  # comment
+    target: Checkpoint
+    signature: BLSSignature
+
+class Block(Container):
+    attestations: List[Attestation, MAX_ATTESTATIONS]
+    history: Vector[Root, 2**3]
+    payload: Union[None, Checkpoint, ByteList[(2 + 2) // 2]]
+`
+
+func TestParseSpecTypes(t *testing.T) {
+	types, err := ParseSpecTypes(specSource, map[string]uint64{
+		"MAX_VALIDATORS_PER_COMMITTEE": 2048,
+		"MAX_COMMITTEES_PER_SLOT":      64,
+		"MAX_ATTESTATIONS":             128,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &VectorType{Uint8, 32}
+	checkpoint := &ContainerType{Name: "Checkpoint", Fields: []Field{
+		{"epoch", Uint64},
+		{"root", root},
+	}}
+	attestation := &ContainerType{Name: "Attestation", Fields: []Field{
+		{"aggregation_bits", &BitlistType{2048 * 64}},
+		{"target", checkpoint},
+		{"signature", &VectorType{Uint8, 96}},
+	}}
+	block := &ContainerType{Name: "Block", Fields: []Field{
+		{"attestations", &ListType{attestation, 128}},
+		{"history", &VectorType{root, 8}},
+		{"payload", &UnionType{Options: []Type{nil, checkpoint, &ListType{Uint8, 2}}}},
+	}}
+	for name, expected := range map[string]Type{
+		"Epoch":       Uint64,
+		"Root":        root,
+		"Checkpoint":  checkpoint,
+		"Attestation": attestation,
+		"Block":       block,
+	} {
+		if got, ok := types[name]; !ok {
+			t.Errorf("missing type %s", name)
+		} else if !reflect.DeepEqual(got, expected) {
+			t.Errorf("type %s was parsed as %s, expected %s", name, got.String(), expected.String())
+		}
+	}
+	if len(types) != 6 {
+		t.Errorf("expected 6 types, got %d", len(types))
+	}
+}
+
+func TestParseSpecTypesInvalid(t *testing.T) {
+	for _, src := range []string{
+		"class A(Container):\n    x: Unknown\n",
+		"class A(Container):\n    x: List[uint64, N]\n",
+		"class A(Container):\n    x: B\nclass B(Container):\n    y: A\n",
+		"class A(Container):\n    pass\n",
+		"class A(uint64):\n    x: uint64\n",
+		"A = uint64\nA = uint32\n",
+		"A = Vector[uint64]\n",
+		"A = List[uint64, 2**64]\n",
+		"A = Bitlist[4 - 5]\n",
+		"A = Union[uint64, None]\n",
+		"def foo():\n    pass\n",
+	} {
+		if _, err := ParseSpecTypes(src, nil); err == nil {
+			t.Errorf("expected error for spec source:\n%s", src)
+		}
+	}
+}
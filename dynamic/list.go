@@ -0,0 +1,151 @@
+package dynamic
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/types"
+)
+
+// A variable-length series of elements, up to the limit. Values are []byte for uint8 elements, []Value otherwise.
+type ListType struct {
+	Elem  Type
+	Limit uint64
+}
+
+func NewListType(elem Type, limit uint64) (*ListType, error) {
+	if elem == nil {
+		return nil, fmt.Errorf("list must have an element type")
+	}
+	return &ListType{Elem: elem, Limit: limit}, nil
+}
+
+func (t *ListType) IsFixed() bool {
+	return false
+}
+
+func (t *ListType) FixedLen() uint64 {
+	return 0
+}
+
+func (t *ListType) MinLen() uint64 {
+	return 0
+}
+
+func (t *ListType) MaxLen() uint64 {
+	if t.Elem.IsFixed() {
+		return t.Limit * t.Elem.FixedLen()
+	}
+	return t.Limit * (offsetLen + t.Elem.MaxLen())
+}
+
+func (t *ListType) Default() Value {
+	if isBytesElem(t.Elem) {
+		return []byte{}
+	}
+	return []Value{}
+}
+
+func (t *ListType) Check(v Value) error {
+	length, err := checkSeries(t.Elem, v)
+	if err != nil {
+		return err
+	}
+	if length > t.Limit {
+		return fmt.Errorf("got %d elements, expected no more than %d elements", length, t.Limit)
+	}
+	return nil
+}
+
+func (t *ListType) SizeOf(v Value) uint64 {
+	if elem, ok := t.Elem.(BasicType); ok {
+		return seriesLen(elem, v) * elem.FixedLen()
+	}
+	return sizeOfSeries(t.Elem, v.([]Value))
+}
+
+func (t *ListType) Encode(eb *EncodingWriter, v Value) error {
+	if elem, ok := t.Elem.(BasicType); ok {
+		return eb.Write(packBasic(elem, v))
+	}
+	return encodeSeries(eb, t.Elem, v.([]Value))
+}
+
+// The number of elements in the scope of the reader, for fixed-size elements
+func (t *ListType) fixedElemsLen(bytesLen uint64) (uint64, error) {
+	elemLen := t.Elem.FixedLen()
+	if bytesLen%elemLen != 0 {
+		return 0, fmt.Errorf("cannot decode list, input has length %d, not compatible with element length %d", bytesLen, elemLen)
+	}
+	length := bytesLen / elemLen
+	if length > t.Limit {
+		return 0, fmt.Errorf("got %d elements, expected no more than %d elements", length, t.Limit)
+	}
+	return length, nil
+}
+
+func (t *ListType) Decode(dr *DecodingReader) (Value, error) {
+	bytesLen := dr.GetBytesSpan()
+	if elem, ok := t.Elem.(BasicType); ok {
+		if bytesLen%elem.FixedLen() != 0 {
+			return nil, fmt.Errorf("cannot decode basic type list, input has length %d, not compatible with element length %d", bytesLen, elem.FixedLen())
+		}
+		return decodeBasicSeries(elem, bytesLen, t.Limit*elem.FixedLen(), dr)
+	}
+	if t.Elem.IsFixed() {
+		length, err := t.fixedElemsLen(bytesLen)
+		if err != nil {
+			return nil, err
+		}
+		return decodeElems(t.Elem, length, nil, dr)
+	}
+	offsets, err := types.ReadVarSliceOffsets(t.Elem.FixedLen(), bytesLen, t.Limit, dr)
+	if err != nil {
+		return nil, err
+	}
+	return decodeElems(t.Elem, uint64(len(offsets)), offsets, dr)
+}
+
+func (t *ListType) DryCheck(dr *DecodingReader) error {
+	bytesLen := dr.GetBytesSpan()
+	if elem, ok := t.Elem.(BasicType); ok {
+		if bytesLen%elem.FixedLen() != 0 {
+			return fmt.Errorf("invalid basic type list, input has length %d, not compatible with element length %d", bytesLen, elem.FixedLen())
+		}
+		return types.BasicSeriesDryCheck(dr, bytesLen, t.Limit*elem.FixedLen(), elem == Bool)
+	}
+	if t.Elem.IsFixed() {
+		length, err := t.fixedElemsLen(bytesLen)
+		if err != nil {
+			return err
+		}
+		return dryCheckElems(t.Elem, length, nil, dr)
+	}
+	offsets, err := types.ReadVarSliceOffsets(t.Elem.FixedLen(), bytesLen, t.Limit, dr)
+	if err != nil {
+		return err
+	}
+	return dryCheckElems(t.Elem, uint64(len(offsets)), offsets, dr)
+}
+
+func (t *ListType) HashTreeRoot(h MerkleFn, v Value) [32]byte {
+	length := seriesLen(t.Elem, v)
+	if elem, ok := t.Elem.(BasicType); ok {
+		return h.MixIn(packedRoot(h, packBasic(elem, v), t.Limit*elem.FixedLen()), length)
+	}
+	return h.MixIn(elemsRoot(h, t.Elem, v.([]Value), t.Limit), length)
+}
+
+func (t *ListType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	if elem, ok := t.Elem.(BasicType); ok {
+		prettyBasicSeries(indent, w, elem, v)
+		return
+	}
+	prettyElems(indent, w, t.Elem, v.([]Value))
+}
+
+func (t *ListType) String() string {
+	return fmt.Sprintf("List[%s, %d]", t.Elem.String(), t.Limit)
+}
@@ -0,0 +1,271 @@
+package dynamic
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+	. "github.com/protolambda/zssz/pretty"
+)
+
+// Series of uint8 elements are represented as []byte values
+func isBytesElem(elem Type) bool {
+	u, ok := elem.(UintType)
+	return ok && u.byteLen == 1
+}
+
+// Check the series value, and get its length
+func checkSeries(elem Type, v Value) (uint64, error) {
+	if isBytesElem(elem) {
+		data, ok := v.([]byte)
+		if !ok {
+			return 0, fmt.Errorf("expected []byte value, got %T", v)
+		}
+		return uint64(len(data)), nil
+	}
+	values, ok := v.([]Value)
+	if !ok {
+		return 0, fmt.Errorf("expected []Value value, got %T", v)
+	}
+	for i, elemV := range values {
+		if err := elem.Check(elemV); err != nil {
+			return 0, fmt.Errorf("element %d: %v", i, err)
+		}
+	}
+	return uint64(len(values)), nil
+}
+
+func seriesLen(elem Type, v Value) uint64 {
+	if isBytesElem(elem) {
+		return uint64(len(v.([]byte)))
+	}
+	return uint64(len(v.([]Value)))
+}
+
+// The series value of the given length, with zero elements
+func defaultSeries(elem Type, length uint64) Value {
+	if isBytesElem(elem) {
+		return make([]byte, length)
+	}
+	values := make([]Value, length)
+	for i := range values {
+		values[i] = elem.Default()
+	}
+	return values
+}
+
+// Pack the basic elements in little-endian bytes
+func packBasic(elem BasicType, v Value) []byte {
+	if isBytesElem(elem) {
+		return v.([]byte)
+	}
+	values := v.([]Value)
+	size := elem.FixedLen()
+	out := make([]byte, uint64(len(values))*size)
+	for i, elemV := range values {
+		elem.PutBasic(out[uint64(i)*size:uint64(i+1)*size], elemV)
+	}
+	return out
+}
+
+// Unpack the little-endian bytes into basic elements
+func unpackBasic(elem BasicType, data []byte) (Value, error) {
+	if isBytesElem(elem) {
+		return data, nil
+	}
+	size := elem.FixedLen()
+	length := uint64(len(data)) / size
+	values := make([]Value, length)
+	for i := uint64(0); i < length; i++ {
+		elemV, err := elem.ReadBasic(data[i*size : (i+1)*size])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = elemV
+	}
+	return values, nil
+}
+
+// Read the given amount of bytes, and unpack them into basic elements
+func decodeBasicSeries(elem BasicType, bytesLen uint64, bytesLimit uint64, dr *DecodingReader) (Value, error) {
+	if bytesLen > bytesLimit {
+		return nil, fmt.Errorf("got %d bytes, expected no more than %d bytes", bytesLen, bytesLimit)
+	}
+	data := make([]byte, bytesLen)
+	if _, err := dr.Read(data); err != nil {
+		return nil, err
+	}
+	return unpackBasic(elem, data)
+}
+
+// Merkleize the packed bytes, with the given limit in bytes
+func packedRoot(h MerkleFn, data []byte, bytesLimit uint64) [32]byte {
+	dataLen := uint64(len(data))
+	leaf := func(i uint64) []byte {
+		s := i << 5
+		e := (i + 1) << 5
+		// pad the data
+		if e > dataLen {
+			x := [32]byte{}
+			copy(x[:], data[s:dataLen])
+			return x[:]
+		}
+		return data[s:e]
+	}
+	return merkle.Merkleize(h, (dataLen+31)>>5, (bytesLimit+31)>>5, leaf)
+}
+
+func sizeOfSeries(elem Type, values []Value) uint64 {
+	if elem.IsFixed() {
+		return uint64(len(values)) * elem.FixedLen()
+	}
+	out := uint64(len(values)) * offsetLen
+	for _, elemV := range values {
+		out += elem.SizeOf(elemV)
+	}
+	return out
+}
+
+func encodeSeries(eb *EncodingWriter, elem Type, values []Value) error {
+	if !elem.IsFixed() {
+		// the previous offset, to calculate a new offset from, starting after the fixed data.
+		prevOffset := uint64(len(values)) * offsetLen
+		// span of the previous var-size element
+		prevSize := uint64(0)
+		for _, elemV := range values {
+			offset, err := eb.WriteOffset(prevOffset, prevSize)
+			if err != nil {
+				return err
+			}
+			prevOffset = offset
+			prevSize = elem.SizeOf(elemV)
+		}
+	}
+	for _, elemV := range values {
+		if err := elem.Encode(eb, elemV); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Process the variable-size elements, each with a reader scoped to the element, as defined by the offsets.
+func processFromOffsets(offsets []uint64, dr *DecodingReader, fn func(i int, scoped *DecodingReader) error) error {
+	for i := 0; i < len(offsets); i++ {
+		currentOffset := dr.Index()
+		if currentOffset != offsets[i] {
+			return fmt.Errorf("expected to read to data %d bytes, got to %d", offsets[i], currentOffset)
+		}
+		// calculate the scope based on next offset, and max. value of this scope for the last value
+		var scope uint64
+		if next := i + 1; next < len(offsets) {
+			if nextOffset := offsets[next]; nextOffset >= currentOffset {
+				scope = nextOffset - currentOffset
+			} else {
+				return fmt.Errorf("offset %d is invalid", i)
+			}
+		} else {
+			scope = dr.Max() - currentOffset
+		}
+		scoped, err := dr.Scope(scope)
+		if err != nil {
+			return err
+		}
+		if err := fn(i, scoped); err != nil {
+			return err
+		}
+		dr.UpdateIndexFromScoped(scoped)
+	}
+	if i, m := dr.Index(), dr.Max(); i != m {
+		return fmt.Errorf("expected to finish reading the scope to max %d, got to %d", i, m)
+	}
+	return nil
+}
+
+// Decode the composite elements. The offsets are nil for fixed-size elements.
+func decodeElems(elem Type, length uint64, offsets []uint64, dr *DecodingReader) ([]Value, error) {
+	values := make([]Value, length)
+	if elem.IsFixed() {
+		for i := range values {
+			elemV, err := elem.Decode(dr)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = elemV
+		}
+		return values, nil
+	}
+	err := processFromOffsets(offsets, dr, func(i int, scoped *DecodingReader) error {
+		elemV, err := elem.Decode(scoped)
+		values[i] = elemV
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Dry-check the composite elements. The offsets are nil for fixed-size elements.
+func dryCheckElems(elem Type, length uint64, offsets []uint64, dr *DecodingReader) error {
+	if elem.IsFixed() {
+		for i := uint64(0); i < length; i++ {
+			if err := elem.DryCheck(dr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return processFromOffsets(offsets, dr, func(i int, scoped *DecodingReader) error {
+		return elem.DryCheck(scoped)
+	})
+}
+
+func elemsRoot(h MerkleFn, elem Type, values []Value, limit uint64) [32]byte {
+	leaf := func(i uint64) []byte {
+		r := elem.HashTreeRoot(h, values[i])
+		return r[:]
+	}
+	return merkle.Merkleize(h, uint64(len(values)), limit, leaf)
+}
+
+func prettyBasicSeries(indent uint32, w *PrettyWriter, elem BasicType, v Value) {
+	w.WriteIndent(indent)
+	if isBytesElem(elem) {
+		w.Write(fmt.Sprintf("0x%x", v.([]byte)))
+		return
+	}
+	values := v.([]Value)
+	length := uint64(len(values))
+	w.Write("[\n")
+	w.WriteIndent(indent + 1)
+	for i, elemV := range values {
+		elem.Pretty(0, w, elemV)
+		if uint64(i) == length-1 {
+			w.Write("\n")
+		} else if uint64(i)%(32/elem.FixedLen()) == 0 {
+			w.Write(",\n")
+			w.WriteIndent(indent + 1)
+		} else {
+			w.Write(", ")
+		}
+	}
+	w.WriteIndent(indent)
+	w.Write("]")
+}
+
+func prettyElems(indent uint32, w *PrettyWriter, elem Type, values []Value) {
+	w.WriteIndent(indent)
+	w.Write("[\n")
+	for i, elemV := range values {
+		elem.Pretty(indent+1, w, elemV)
+		if i == len(values)-1 {
+			w.Write("\n")
+		} else {
+			w.Write(",\n")
+		}
+	}
+	w.WriteIndent(indent)
+	w.Write("]")
+}
@@ -0,0 +1,518 @@
+package dynamic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Types parsed from consensus-spec definitions, by name
+type SpecTypes map[string]Type
+
+// A class or alias definition, not resolved yet
+type specDef struct {
+	name string
+	// the base type expression, "Container" for containers
+	base   string
+	fields []specFieldDef
+	line   int
+}
+
+type specFieldDef struct {
+	name string
+	expr string
+	line int
+}
+
+// Parse the Python-style SSZ type definitions of the consensus specs, e.g.:
+//
+//	class Epoch(uint64):
+//	    pass
+//
+//	Root = Bytes32
+//
+//	class Checkpoint(Container):
+//	    epoch: Epoch
+//	    root: Root
+//
+// Lengths and limits can be integer expressions (+, -, *, //, ** and parentheses)
+// of literals and constants from the given map. Definitions may refer to definitions later in the source.
+// Comments and docstrings are ignored, other statements are not supported.
+func ParseSpecTypes(src string, constants map[string]uint64) (SpecTypes, error) {
+	defs, order, err := parseSpecDefs(src)
+	if err != nil {
+		return nil, err
+	}
+	r := &specResolver{
+		defs:      defs,
+		constants: constants,
+		types:     make(SpecTypes, len(defs)),
+		resolving: make(map[string]struct{}),
+	}
+	for _, name := range order {
+		if _, err := r.resolve(name); err != nil {
+			return nil, err
+		}
+	}
+	return r.types, nil
+}
+
+func parseSpecDefs(src string) (map[string]*specDef, []string, error) {
+	defs := make(map[string]*specDef)
+	var order []string
+	var current *specDef
+	inDocstring := false
+	for i, line := range strings.Split(src, "\n") {
+		lineNr := i + 1
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, `"""`) {
+			// single-line docstrings start and end on the same line
+			if !(len(trimmed) >= 6 && strings.HasSuffix(trimmed, `"""`)) {
+				inDocstring = !inDocstring
+			}
+			continue
+		}
+		if inDocstring {
+			if strings.HasSuffix(trimmed, `"""`) {
+				inDocstring = false
+			}
+			continue
+		}
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		line = strings.TrimSpace(line)
+		if indented {
+			if current == nil {
+				return nil, nil, fmt.Errorf("line %d: unexpected indentation", lineNr)
+			}
+			if line == "pass" {
+				continue
+			}
+			field, err := parseSpecField(line, lineNr)
+			if err != nil {
+				return nil, nil, err
+			}
+			current.fields = append(current.fields, field)
+			continue
+		}
+		var def *specDef
+		var body string
+		if strings.HasPrefix(line, "class ") {
+			open := strings.Index(line, "(")
+			end := strings.Index(line, "):")
+			if open < 0 || end < open {
+				return nil, nil, fmt.Errorf("line %d: expected class definition with a base type", lineNr)
+			}
+			def = &specDef{
+				name: strings.TrimSpace(line[len("class "):open]),
+				base: strings.TrimSpace(line[open+1 : end]),
+				line: lineNr,
+			}
+			body = strings.TrimSpace(line[end+2:])
+		} else if j := strings.Index(line, "="); j >= 0 {
+			def = &specDef{
+				name: strings.TrimSpace(line[:j]),
+				base: strings.TrimSpace(line[j+1:]),
+				line: lineNr,
+			}
+		} else {
+			return nil, nil, fmt.Errorf("line %d: expected class or alias definition", lineNr)
+		}
+		if !isSpecIdent(def.name) {
+			return nil, nil, fmt.Errorf("line %d: invalid type name %q", lineNr, def.name)
+		}
+		if _, ok := defs[def.name]; ok {
+			return nil, nil, fmt.Errorf("line %d: type %s is defined already", lineNr, def.name)
+		}
+		if body != "" && body != "pass" {
+			field, err := parseSpecField(body, lineNr)
+			if err != nil {
+				return nil, nil, err
+			}
+			def.fields = append(def.fields, field)
+		}
+		defs[def.name] = def
+		order = append(order, def.name)
+		current = def
+	}
+	return defs, order, nil
+}
+
+func parseSpecField(line string, lineNr int) (specFieldDef, error) {
+	j := strings.Index(line, ":")
+	if j < 0 {
+		return specFieldDef{}, fmt.Errorf("line %d: expected field definition", lineNr)
+	}
+	name := strings.TrimSpace(line[:j])
+	if !isSpecIdent(name) {
+		return specFieldDef{}, fmt.Errorf("line %d: invalid field name %q", lineNr, name)
+	}
+	return specFieldDef{name: name, expr: strings.TrimSpace(line[j+1:]), line: lineNr}, nil
+}
+
+func isSpecIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+type specResolver struct {
+	defs      map[string]*specDef
+	constants map[string]uint64
+	types     SpecTypes
+	// definitions currently being resolved, to detect cycles
+	resolving map[string]struct{}
+}
+
+// Resolve the type by name: a built-in type, or a definition
+func (r *specResolver) resolve(name string) (Type, error) {
+	if t, ok := r.types[name]; ok {
+		return t, nil
+	}
+	def, ok := r.defs[name]
+	if !ok {
+		return builtinSpecType(name)
+	}
+	if _, ok := r.resolving[name]; ok {
+		return nil, fmt.Errorf("line %d: type %s is self-referential", def.line, name)
+	}
+	r.resolving[name] = struct{}{}
+	defer delete(r.resolving, name)
+	var t Type
+	if def.base == "Container" {
+		fields := make([]Field, len(def.fields))
+		for i, f := range def.fields {
+			fieldType, err := r.parseExpr(f.expr)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: field %s of %s: %v", f.line, f.name, name, err)
+			}
+			fields[i] = Field{Name: f.name, Type: fieldType}
+		}
+		c, err := NewContainerType(name, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", def.line, err)
+		}
+		t = c
+	} else {
+		if len(def.fields) != 0 {
+			return nil, fmt.Errorf("line %d: type %s has fields, but is not a Container", def.line, name)
+		}
+		base, err := r.parseExpr(def.base)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: type %s: %v", def.line, name, err)
+		}
+		t = base
+	}
+	r.types[name] = t
+	return t, nil
+}
+
+func builtinSpecType(name string) (Type, error) {
+	switch name {
+	case "boolean", "bit":
+		return Bool, nil
+	case "byte", "uint8":
+		return Uint8, nil
+	case "uint16":
+		return Uint16, nil
+	case "uint32":
+		return Uint32, nil
+	case "uint64":
+		return Uint64, nil
+	case "uint128":
+		return Uint128, nil
+	case "uint256":
+		return Uint256, nil
+	}
+	if strings.HasPrefix(name, "Bytes") {
+		if n, err := strconv.ParseUint(name[len("Bytes"):], 10, 64); err == nil {
+			return NewVectorType(Uint8, n)
+		}
+	}
+	return nil, fmt.Errorf("unknown type %s", name)
+}
+
+func (r *specResolver) parseExpr(expr string) (Type, error) {
+	p := &specExprParser{r: r, tokens: tokenizeSpecExpr(expr)}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q after type expression %q", p.tokens[p.pos], expr)
+	}
+	return t, nil
+}
+
+// Split the expression into identifiers, integers, and operator and bracket tokens
+func tokenizeSpecExpr(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '*' || c == '/':
+			// ** and // are single tokens
+			if i+1 < len(expr) && expr[i+1] == c {
+				tokens = append(tokens, expr[i:i+2])
+				i += 2
+			} else {
+				tokens = append(tokens, expr[i:i+1])
+				i++
+			}
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(expr) && (expr[j] == '_' || (expr[j] >= 'a' && expr[j] <= 'z') ||
+				(expr[j] >= 'A' && expr[j] <= 'Z') || (expr[j] >= '0' && expr[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		}
+	}
+	return tokens
+}
+
+type specExprParser struct {
+	r      *specResolver
+	tokens []string
+	pos    int
+}
+
+func (p *specExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *specExprParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *specExprParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		if got == "" {
+			return fmt.Errorf("expected %q, got end of expression", tok)
+		}
+		return fmt.Errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+func (p *specExprParser) parseType() (Type, error) {
+	name := p.next()
+	if !isSpecIdent(name) {
+		return nil, fmt.Errorf("expected type name, got %q", name)
+	}
+	if p.peek() != "[" {
+		switch name {
+		case "Vector", "List", "Bitvector", "Bitlist", "ByteVector", "ByteList", "Union":
+			return nil, fmt.Errorf("type %s requires parameters", name)
+		}
+		return p.r.resolve(name)
+	}
+	p.next()
+	var t Type
+	var err error
+	switch name {
+	case "Vector", "List":
+		var elem Type
+		if elem, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		var n uint64
+		if n, err = p.parseInt(); err != nil {
+			return nil, err
+		}
+		if name == "Vector" {
+			t, err = NewVectorType(elem, n)
+		} else {
+			t, err = NewListType(elem, n)
+		}
+	case "ByteVector", "ByteList", "Bitvector", "Bitlist":
+		var n uint64
+		if n, err = p.parseInt(); err != nil {
+			return nil, err
+		}
+		switch name {
+		case "ByteVector":
+			t, err = NewVectorType(Uint8, n)
+		case "ByteList":
+			t, err = NewListType(Uint8, n)
+		case "Bitvector":
+			t, err = NewBitvectorType(n)
+		default:
+			t, err = NewBitlistType(n)
+		}
+	case "Union":
+		var options []Type
+		for {
+			if p.peek() == "None" {
+				p.next()
+				options = append(options, nil)
+			} else {
+				opt, err := p.parseType()
+				if err != nil {
+					return nil, err
+				}
+				options = append(options, opt)
+			}
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		t, err = NewUnionType(options)
+	default:
+		return nil, fmt.Errorf("type %s does not take parameters", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Parse an integer expression: + and - operands
+func (p *specExprParser) parseInt() (uint64, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != "+" && op != "-" {
+			return x, nil
+		}
+		p.next()
+		y, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == "+" {
+			if x+y < x {
+				return 0, fmt.Errorf("integer overflow in %d + %d", x, y)
+			}
+			x += y
+		} else {
+			if y > x {
+				return 0, fmt.Errorf("negative integer in %d - %d", x, y)
+			}
+			x -= y
+		}
+	}
+}
+
+// Parse * and // operands
+func (p *specExprParser) parseTerm() (uint64, error) {
+	x, err := p.parsePower()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != "*" && op != "//" {
+			return x, nil
+		}
+		p.next()
+		y, err := p.parsePower()
+		if err != nil {
+			return 0, err
+		}
+		if op == "*" {
+			if x != 0 && (x*y)/x != y {
+				return 0, fmt.Errorf("integer overflow in %d * %d", x, y)
+			}
+			x *= y
+		} else {
+			if y == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			x /= y
+		}
+	}
+}
+
+// Parse ** operands, right-associative
+func (p *specExprParser) parsePower() (uint64, error) {
+	x, err := p.parseAtom()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != "**" {
+		return x, nil
+	}
+	p.next()
+	y, err := p.parsePower()
+	if err != nil {
+		return 0, err
+	}
+	out := uint64(1)
+	for i := uint64(0); i < y; i++ {
+		if x != 0 && (out*x)/x != out {
+			return 0, fmt.Errorf("integer overflow in %d ** %d", x, y)
+		}
+		out *= x
+		if out == 0 || out == 1 {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (p *specExprParser) parseAtom() (uint64, error) {
+	tok := p.next()
+	switch {
+	case tok == "(":
+		x, err := p.parseInt()
+		if err != nil {
+			return 0, err
+		}
+		if err := p.expect(")"); err != nil {
+			return 0, err
+		}
+		return x, nil
+	case tok == "":
+		return 0, fmt.Errorf("expected integer, got end of expression")
+	case tok[0] >= '0' && tok[0] <= '9':
+		x, err := strconv.ParseUint(tok, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer %q: %v", tok, err)
+		}
+		return x, nil
+	case isSpecIdent(tok):
+		x, ok := p.r.constants[tok]
+		if !ok {
+			return 0, fmt.Errorf("unknown constant %s", tok)
+		}
+		return x, nil
+	default:
+		return 0, fmt.Errorf("expected integer, got %q", tok)
+	}
+}
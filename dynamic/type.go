@@ -0,0 +1,56 @@
+package dynamic
+
+import (
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+)
+
+const offsetLen = 4
+
+// A value of a dynamic type, represented as generic value tree:
+//   - boolean: bool
+//   - uint8, uint16, uint32, uint64: uint64
+//   - uint128, uint256: *big.Int
+//   - vectors and lists of uint8: []byte
+//   - other vectors and lists: []Value
+//   - containers: []Value, a value per field
+//   - bitvectors and bitlists: []byte, packed bits, bitlists include the delimiting bit (see the bitfields package)
+//   - unions: *UnionValue
+type Value interface{}
+
+// A SSZ type, described at runtime instead of by a Go type.
+// The encoding, decoding and hashing is the same as that of the equivalent static SSZ definition.
+//
+// The methods expect valid values, check a value with Check first.
+// The package-level functions check the value before using it.
+type Type interface {
+	// If the type is fixed-size
+	IsFixed() bool
+	// The fixed size, or the size of the offset if the type is variable-size
+	FixedLen() uint64
+	MinLen() uint64
+	MaxLen() uint64
+	// The zero value of the type
+	Default() Value
+	// Check if the value is a valid value of the type
+	Check(v Value) error
+	SizeOf(v Value) uint64
+	Encode(eb *EncodingWriter, v Value) error
+	Decode(dr *DecodingReader) (Value, error)
+	DryCheck(dr *DecodingReader) error
+	HashTreeRoot(h MerkleFn, v Value) [32]byte
+	Pretty(indent uint32, w *PrettyWriter, v Value)
+	// The type description, e.g. List[uint64, 32]
+	String() string
+}
+
+// A basic type, packed in series.
+type BasicType interface {
+	Type
+	// Write the value in little-endian to dst, of the size of the type
+	PutBasic(dst []byte, v Value)
+	// Read the little-endian value from src, of the size of the type
+	ReadBasic(src []byte) (Value, error)
+}
@@ -0,0 +1,230 @@
+package dynamic
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/types"
+	"strings"
+)
+
+// A value of a union type: the selected option, and the value of the option (nil for the None option).
+type UnionValue struct {
+	Selector uint8
+	Value    Value
+}
+
+// A union of options, selected by index. A nil option at selector 0 is the None option.
+// Values are *UnionValue.
+type UnionType struct {
+	Options []Type
+}
+
+func NewUnionType(options []Type) (*UnionType, error) {
+	if len(options) == 0 {
+		return nil, fmt.Errorf("union must have at least one option")
+	}
+	if len(options) > types.MAX_UNION_OPTIONS {
+		return nil, fmt.Errorf("union has %d options, expected no more than %d", len(options), types.MAX_UNION_OPTIONS)
+	}
+	for i, opt := range options {
+		if opt == nil {
+			if i != 0 {
+				return nil, fmt.Errorf("union option %d is None, only allowed at selector 0", i)
+			}
+			if len(options) == 1 {
+				return nil, fmt.Errorf("union with None as only option is not allowed")
+			}
+		}
+	}
+	return &UnionType{Options: options}, nil
+}
+
+func (t *UnionType) IsFixed() bool {
+	return false
+}
+
+func (t *UnionType) FixedLen() uint64 {
+	return 0
+}
+
+// Includes the selector byte
+func (t *UnionType) MinLen() uint64 {
+	min := ^uint64(0)
+	for _, opt := range t.Options {
+		if opt == nil {
+			min = 0
+		} else if x := opt.MinLen(); x < min {
+			min = x
+		}
+	}
+	return 1 + min
+}
+
+// Includes the selector byte
+func (t *UnionType) MaxLen() uint64 {
+	max := uint64(0)
+	for _, opt := range t.Options {
+		if opt != nil {
+			if x := opt.MaxLen(); x > max {
+				max = x
+			}
+		}
+	}
+	return 1 + max
+}
+
+// The first option, with its default value
+func (t *UnionType) Default() Value {
+	if t.Options[0] == nil {
+		return &UnionValue{}
+	}
+	return &UnionValue{Selector: 0, Value: t.Options[0].Default()}
+}
+
+func (t *UnionType) Check(v Value) error {
+	u, ok := v.(*UnionValue)
+	if !ok || u == nil {
+		return fmt.Errorf("expected *UnionValue value, got %T", v)
+	}
+	if uint64(u.Selector) >= uint64(len(t.Options)) {
+		return fmt.Errorf("union selector %d is invalid, union has %d options", u.Selector, len(t.Options))
+	}
+	opt := t.Options[u.Selector]
+	if opt == nil {
+		if u.Value != nil {
+			return fmt.Errorf("union selector %d is None, but value is not nil", u.Selector)
+		}
+		return nil
+	}
+	if err := opt.Check(u.Value); err != nil {
+		return fmt.Errorf("union option %d: %v", u.Selector, err)
+	}
+	return nil
+}
+
+func (t *UnionType) SizeOf(v Value) uint64 {
+	u := v.(*UnionValue)
+	opt := t.Options[u.Selector]
+	if opt == nil {
+		return 1
+	}
+	return 1 + opt.SizeOf(u.Value)
+}
+
+func (t *UnionType) Encode(eb *EncodingWriter, v Value) error {
+	u := v.(*UnionValue)
+	if err := eb.WriteByte(u.Selector); err != nil {
+		return err
+	}
+	opt := t.Options[u.Selector]
+	if opt == nil {
+		return nil
+	}
+	return opt.Encode(eb, u.Value)
+}
+
+// Read the selector, and get the selected option, checking the span of the remaining value
+func (t *UnionType) readSelector(dr *DecodingReader) (uint8, Type, error) {
+	selector, err := dr.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	if uint64(selector) >= uint64(len(t.Options)) {
+		return 0, nil, fmt.Errorf("union selector %d is invalid, union has %d options", selector, len(t.Options))
+	}
+	opt := t.Options[selector]
+	span := dr.GetBytesSpan()
+	if opt == nil {
+		if span != 0 {
+			return 0, nil, fmt.Errorf("union None value must be empty, but got %d bytes", span)
+		}
+	} else if opt.IsFixed() && span != opt.FixedLen() {
+		return 0, nil, fmt.Errorf("union option %d is fixed-size %d bytes, but got %d bytes", selector, opt.FixedLen(), span)
+	}
+	return selector, opt, nil
+}
+
+func (t *UnionType) Decode(dr *DecodingReader) (Value, error) {
+	selector, opt, err := t.readSelector(dr)
+	if err != nil {
+		return nil, err
+	}
+	if opt == nil {
+		return &UnionValue{Selector: selector}, nil
+	}
+	scoped, err := dr.Scope(dr.GetBytesSpan())
+	if err != nil {
+		return nil, err
+	}
+	optV, err := opt.Decode(scoped)
+	if err != nil {
+		return nil, err
+	}
+	dr.UpdateIndexFromScoped(scoped)
+	return &UnionValue{Selector: selector, Value: optV}, nil
+}
+
+func (t *UnionType) DryCheck(dr *DecodingReader) error {
+	_, opt, err := t.readSelector(dr)
+	if err != nil || opt == nil {
+		return err
+	}
+	scoped, err := dr.Scope(dr.GetBytesSpan())
+	if err != nil {
+		return err
+	}
+	if err := opt.DryCheck(scoped); err != nil {
+		return err
+	}
+	dr.UpdateIndexFromScoped(scoped)
+	return nil
+}
+
+func (t *UnionType) HashTreeRoot(h MerkleFn, v Value) [32]byte {
+	u := v.(*UnionValue)
+	// mix_in_selector: the None option has a zero root.
+	var root [32]byte
+	if opt := t.Options[u.Selector]; opt != nil {
+		root = opt.HashTreeRoot(h, u.Value)
+	}
+	return h.MixIn(root, uint64(u.Selector))
+}
+
+func (t *UnionType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	u := v.(*UnionValue)
+	opt := t.Options[u.Selector]
+	w.WriteIndent(indent)
+	w.Write("{\n")
+	w.WriteIndent(indent + 1)
+	w.Write(fmt.Sprintf("selector: %d,\n", u.Selector))
+	if opt != nil {
+		w.WriteIndent(indent + 1)
+		w.Write(fmt.Sprintf("type: %s,\n", opt.String()))
+	}
+	w.WriteIndent(indent + 1)
+	w.Write("value:\n")
+	if opt == nil {
+		w.WriteIndent(indent + 3)
+		w.Write("null")
+	} else {
+		opt.Pretty(indent+3, w, u.Value)
+	}
+	w.Write("\n")
+	w.WriteIndent(indent)
+	w.Write("}")
+}
+
+func (t *UnionType) String() string {
+	options := make([]string, len(t.Options))
+	for i, opt := range t.Options {
+		if opt == nil {
+			options[i] = "None"
+		} else {
+			options[i] = opt.String()
+		}
+	}
+	return "Union[" + strings.Join(options, ", ") + "]"
+}
@@ -0,0 +1,129 @@
+package dynamic
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/types"
+)
+
+// A fixed-length series of elements. Values are []byte for uint8 elements, []Value otherwise.
+type VectorType struct {
+	Elem   Type
+	Length uint64
+}
+
+func NewVectorType(elem Type, length uint64) (*VectorType, error) {
+	if elem == nil {
+		return nil, fmt.Errorf("vector must have an element type")
+	}
+	if length == 0 {
+		return nil, fmt.Errorf("vector must have a non-zero length")
+	}
+	return &VectorType{Elem: elem, Length: length}, nil
+}
+
+func (t *VectorType) IsFixed() bool {
+	return t.Elem.IsFixed()
+}
+
+func (t *VectorType) FixedLen() uint64 {
+	if t.Elem.IsFixed() {
+		return t.Elem.FixedLen() * t.Length
+	}
+	return offsetLen * t.Length
+}
+
+func (t *VectorType) MinLen() uint64 {
+	if t.Elem.IsFixed() {
+		return t.Elem.MinLen() * t.Length
+	}
+	return (offsetLen + t.Elem.MinLen()) * t.Length
+}
+
+func (t *VectorType) MaxLen() uint64 {
+	if t.Elem.IsFixed() {
+		return t.Elem.MaxLen() * t.Length
+	}
+	return (offsetLen + t.Elem.MaxLen()) * t.Length
+}
+
+func (t *VectorType) Default() Value {
+	return defaultSeries(t.Elem, t.Length)
+}
+
+func (t *VectorType) Check(v Value) error {
+	length, err := checkSeries(t.Elem, v)
+	if err != nil {
+		return err
+	}
+	if length != t.Length {
+		return fmt.Errorf("expected %d elements, got %d", t.Length, length)
+	}
+	return nil
+}
+
+func (t *VectorType) SizeOf(v Value) uint64 {
+	if t.Elem.IsFixed() {
+		return t.FixedLen()
+	}
+	return sizeOfSeries(t.Elem, v.([]Value))
+}
+
+func (t *VectorType) Encode(eb *EncodingWriter, v Value) error {
+	if elem, ok := t.Elem.(BasicType); ok {
+		return eb.Write(packBasic(elem, v))
+	}
+	return encodeSeries(eb, t.Elem, v.([]Value))
+}
+
+func (t *VectorType) Decode(dr *DecodingReader) (Value, error) {
+	if elem, ok := t.Elem.(BasicType); ok {
+		bytesLen := t.Length * elem.FixedLen()
+		return decodeBasicSeries(elem, bytesLen, bytesLen, dr)
+	}
+	var offsets []uint64
+	if !t.Elem.IsFixed() {
+		var err error
+		if offsets, err = types.ReadVarSeriesOffsets(t.Length, dr); err != nil {
+			return nil, err
+		}
+	}
+	return decodeElems(t.Elem, t.Length, offsets, dr)
+}
+
+func (t *VectorType) DryCheck(dr *DecodingReader) error {
+	if elem, ok := t.Elem.(BasicType); ok {
+		bytesLen := t.Length * elem.FixedLen()
+		return types.BasicSeriesDryCheck(dr, bytesLen, bytesLen, elem == Bool)
+	}
+	var offsets []uint64
+	if !t.Elem.IsFixed() {
+		var err error
+		if offsets, err = types.ReadVarSeriesOffsets(t.Length, dr); err != nil {
+			return err
+		}
+	}
+	return dryCheckElems(t.Elem, t.Length, offsets, dr)
+}
+
+func (t *VectorType) HashTreeRoot(h MerkleFn, v Value) [32]byte {
+	if elem, ok := t.Elem.(BasicType); ok {
+		return packedRoot(h, packBasic(elem, v), t.Length*elem.FixedLen())
+	}
+	return elemsRoot(h, t.Elem, v.([]Value), t.Length)
+}
+
+func (t *VectorType) Pretty(indent uint32, w *PrettyWriter, v Value) {
+	if elem, ok := t.Elem.(BasicType); ok {
+		prettyBasicSeries(indent, w, elem, v)
+		return
+	}
+	prettyElems(indent, w, t.Elem, v.([]Value))
+}
+
+func (t *VectorType) String() string {
+	return fmt.Sprintf("Vector[%s, %d]", t.Elem.String(), t.Length)
+}
@@ -2,7 +2,10 @@ package enc
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
+	"runtime"
+	"sort"
 	"unsafe"
 )
 
@@ -11,16 +14,161 @@ type SizeFn func(pointer unsafe.Pointer) uint64
 type EncoderFn func(eb *EncodingWriter, pointer unsafe.Pointer) error
 
 type EncodingWriter struct {
-	w       io.Writer
-	wfn     func(p []byte) (n int, err error)
+	w   io.Writer
+	wfn func(p []byte) (n int, err error)
+	// appended to instead, if there is no io.Writer
+	buf []byte
+	// written to at positions instead, if there is an io.WriterAt. Writes are buffered in buf.
+	wat io.WriterAt
+	// position in wat of the start of the encoding, and of the start of buf
+	watBase int64
+	watOff  int64
 	n       int
 	Scratch [32]byte
+	// Sizes of the variable-size values, recorded before encoding. Optional.
+	Sizes *SizeCache
+	// If offsets are written as placeholders, to patch once the data they point to is written.
+	patching bool
+	// offsets to patch in data that is already flushed, applied in batches on Flush
+	patches  []offsetPatch
+	patchBuf []byte
+	// Encode large series of elements with multiple goroutines. Optional.
+	Parallel *ParallelConfig
+}
+
+// Large lists and vectors are split into ranges of elements, encoded with a goroutine each into separate buffers,
+// and then written in order. The output is the same as when encoding sequentially.
+type ParallelConfig struct {
+	// The number of goroutines to encode a series of elements with. runtime.GOMAXPROCS(0) if 0.
+	Workers int
+	// The minimum number of elements of a series to encode in parallel.
+	Threshold uint64
+}
+
+func (c *ParallelConfig) WorkerCount() int {
+	if c.Workers > 0 {
+		return c.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Writes to an io.WriterAt are buffered up to this size
+const writerAtBufSize = 1 << 16
+
+// Patches of offsets that are already written to an io.WriterAt are buffered up to this count
+const writerAtMaxPatches = 1 << 12
+
+type offsetPatch struct {
+	pos    int
+	offset uint32
 }
 
 func NewEncodingWriter(w io.Writer) *EncodingWriter {
 	return &EncodingWriter{w: w, wfn: w.Write, n: 0}
 }
 
+// Encode by appending to the byte slice directly, instead of writing to an io.Writer.
+// Reserve enough capacity in dst to avoid re-allocations.
+func NewSliceEncodingWriter(dst []byte) *EncodingWriter {
+	ew := &EncodingWriter{}
+	ew.ResetSlice(dst)
+	return ew
+}
+
+// Reset the writer to append to the given byte slice, to reuse the writer with.
+func (ew *EncodingWriter) ResetSlice(dst []byte) {
+	ew.w = nil
+	ew.wfn = nil
+	ew.buf = dst
+	ew.wat = nil
+	ew.patches = ew.patches[:0]
+	ew.n = 0
+	ew.patching = false
+}
+
+// Encode by writing to the io.WriterAt, e.g. a file, starting at position off.
+// Writes are buffered: Flush after encoding.
+func NewWriterAtEncodingWriter(w io.WriterAt, off int64) *EncodingWriter {
+	ew := &EncodingWriter{
+		buf:     make([]byte, 0, writerAtBufSize),
+		wat:     w,
+		watBase: off,
+		watOff:  off,
+	}
+	ew.wfn = ew.bufferWriteAt
+	return ew
+}
+
+func (ew *EncodingWriter) bufferWriteAt(p []byte) (n int, err error) {
+	ew.buf = append(ew.buf, p...)
+	if len(ew.buf) >= writerAtBufSize {
+		if err := ew.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Writes the buffered data and offset patches, if writing to an io.WriterAt.
+func (ew *EncodingWriter) Flush() error {
+	if ew.wat == nil {
+		return nil
+	}
+	if len(ew.buf) > 0 {
+		n, err := ew.wat.WriteAt(ew.buf, ew.watOff)
+		ew.watOff += int64(n)
+		ew.buf = ew.buf[:copy(ew.buf, ew.buf[n:])]
+		if err != nil {
+			return err
+		}
+	}
+	return ew.flushPatches()
+}
+
+// Writes the pending offset patches, with a single write per range of adjacent offsets.
+func (ew *EncodingWriter) flushPatches() error {
+	patches := ew.patches
+	ew.patches = ew.patches[:0]
+	sort.Slice(patches, func(i, j int) bool {
+		return patches[i].pos < patches[j].pos
+	})
+	for i := 0; i < len(patches); {
+		start := patches[i].pos
+		run := ew.patchBuf[:0]
+		for ; i < len(patches) && patches[i].pos == start+len(run); i++ {
+			var tmp [4]byte
+			binary.LittleEndian.PutUint32(tmp[:], patches[i].offset)
+			run = append(run, tmp[:]...)
+		}
+		ew.patchBuf = run
+		if _, err := ew.wat.WriteAt(run, ew.watBase+int64(start)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write offsets as placeholders, and patch them once the data they point to is written,
+// instead of sizing the data before writing the offsets.
+// Only writers to a byte slice or io.WriterAt can patch offsets.
+func (ew *EncodingWriter) EnablePatching() error {
+	if ew.wfn != nil && ew.wat == nil {
+		return fmt.Errorf("cannot patch offsets written to an io.Writer")
+	}
+	ew.patching = true
+	return nil
+}
+
+// If offsets are written as placeholders, to patch with PatchOffset, instead of with WriteOffset.
+func (ew *EncodingWriter) Patching() bool {
+	return ew.patching
+}
+
+// The byte slice that is appended to, if the writer is not encoding to an io.Writer.
+func (ew *EncodingWriter) Bytes() []byte {
+	return ew.buf
+}
+
 // How many bytes were written to the underlying io.Writer before ending encoding (for handling errors)
 func (ew *EncodingWriter) Written() int {
 	return ew.n
@@ -28,6 +176,11 @@ func (ew *EncodingWriter) Written() int {
 
 // Write writes len(p) bytes from p to the underlying accumulated buffer.
 func (ew *EncodingWriter) Write(p []byte) error {
+	if ew.wfn == nil {
+		ew.buf = append(ew.buf, p...)
+		ew.n += len(p)
+		return nil
+	}
 	n, err := ew.wfn(p)
 	ew.n += n
 	return err
@@ -35,6 +188,11 @@ func (ew *EncodingWriter) Write(p []byte) error {
 
 // Write a single byte to the buffer.
 func (ew *EncodingWriter) WriteByte(v byte) error {
+	if ew.wfn == nil {
+		ew.buf = append(ew.buf, v)
+		ew.n++
+		return nil
+	}
 	ew.Scratch[0] = v
 	return ew.Write(ew.Scratch[0:1])
 }
@@ -42,16 +200,57 @@ func (ew *EncodingWriter) WriteByte(v byte) error {
 // Writes an offset for an element
 func (ew *EncodingWriter) WriteOffset(prevOffset uint64, elemLen uint64) (offset uint64, err error) {
 	if prevOffset >= (uint64(1) << 32) {
-		panic("cannot write offset with invalid previous offset")
+		return 0, fmt.Errorf("cannot write offset with invalid previous offset %d", prevOffset)
 	}
 	if elemLen >= (uint64(1) << 32) {
-		panic("cannot write offset with invalid element size")
+		return 0, fmt.Errorf("cannot write offset with invalid element size %d", elemLen)
 	}
 	offset = prevOffset + elemLen
 	if offset >= (uint64(1) << 32) {
-		panic("offset too large, not uint32")
+		return 0, fmt.Errorf("offset %d too large, not uint32", offset)
 	}
 	binary.LittleEndian.PutUint32(ew.Scratch[0:4], uint32(offset))
 	err = ew.Write(ew.Scratch[0:4])
 	return
 }
+
+var zeroOffsets [32]byte
+
+// Writes count zeroed offsets, to patch later with PatchOffset.
+func (ew *EncodingWriter) WriteOffsetPlaceholders(count uint64) error {
+	for count > 0 {
+		k := uint64(len(zeroOffsets)) / 4
+		if k > count {
+			k = count
+		}
+		if err := ew.Write(zeroOffsets[:k*4]); err != nil {
+			return err
+		}
+		count -= k
+	}
+	return nil
+}
+
+// Patches the offset placeholder at position pos, as counted by Written,
+// to point to the current position, relative to position start.
+func (ew *EncodingWriter) PatchOffset(pos int, start int) error {
+	offset := uint64(ew.n - start)
+	if offset >= (uint64(1) << 32) {
+		return fmt.Errorf("offset %d too large, not uint32", offset)
+	}
+	// the buffered data starts at this position
+	bufPos := ew.n - len(ew.buf)
+	if pos < bufPos || pos+4 > ew.n {
+		if ew.wat == nil || pos+4 > bufPos {
+			return fmt.Errorf("cannot patch offset at position %d", pos)
+		}
+		// patch data that is already flushed, with the other patches on the next Flush
+		ew.patches = append(ew.patches, offsetPatch{pos: pos, offset: uint32(offset)})
+		if len(ew.patches) >= writerAtMaxPatches {
+			return ew.Flush()
+		}
+		return nil
+	}
+	binary.LittleEndian.PutUint32(ew.buf[pos-bufPos:], uint32(offset))
+	return nil
+}
@@ -0,0 +1,89 @@
+package enc
+
+import "unsafe"
+
+// Pointers are only compared, not dereferenced: the value being encoded keeps them alive.
+type sizeEntry struct {
+	p    uintptr
+	def  [2]uintptr
+	size uint64
+	// index after the entries recorded while sizing the value, i.e. of the contents of the value
+	end int
+}
+
+// The words of the interface value, to compare definitions by, without requiring them to be comparable.
+func defWords(def interface{}) [2]uintptr {
+	return *(*[2]uintptr)(unsafe.Pointer(&def))
+}
+
+// Sizes of variable-size values, recorded before encoding, in the order the encoding asks for them.
+// This avoids sizing nested values again at every level of nesting.
+// Each size is recorded with the pointer and the definition of the value,
+// and is only used for that same value: a mismatch falls back to sizing the value.
+type SizeCache struct {
+	entries []sizeEntry
+	next    int
+}
+
+// Reset the cache, to reuse it for another value.
+func (c *SizeCache) Reset() {
+	c.entries = c.entries[:0]
+	c.next = 0
+}
+
+// Reserve n entries, to set after sizing the values, and return the index of the first.
+func (c *SizeCache) Reserve(n int) int {
+	start := len(c.entries)
+	end := start + n
+	if end > cap(c.entries) {
+		entries := make([]sizeEntry, start, 2*end)
+		copy(entries, c.entries)
+		c.entries = entries
+	}
+	c.entries = c.entries[:end]
+	return start
+}
+
+// Set a reserved entry to the size of the value at the pointer, as defined by def.
+func (c *SizeCache) Set(i int, p unsafe.Pointer, def interface{}, size uint64) {
+	c.entries[i] = sizeEntry{p: uintptr(p), def: defWords(def), size: size, end: len(c.entries)}
+}
+
+// The index of the next recorded size to take.
+func (c *SizeCache) Next() int {
+	return c.next
+}
+
+// Continue taking sizes from entry i, e.g. to skip the sizes that were taken from a View.
+func (c *SizeCache) Seek(i int) {
+	c.next = i
+}
+
+// The index after the entries recorded while sizing the value of entry i, i.e. after the sizes of its contents.
+func (c *SizeCache) End(i int) int {
+	return c.entries[i].end
+}
+
+// A cache to take sizes from, starting at entry i, sharing the recorded sizes.
+// Views can be used concurrently, as long as the cache is not changed.
+func (c *SizeCache) View(i int) *SizeCache {
+	return &SizeCache{entries: c.entries, next: i}
+}
+
+// The number of recorded sizes that are not taken yet.
+func (c *SizeCache) Pending() int {
+	return len(c.entries) - c.next
+}
+
+// Take the next recorded size, if it is recorded for the value at the pointer, as defined by def.
+func (c *SizeCache) Take(p unsafe.Pointer, def interface{}) (size uint64, ok bool) {
+	if c.next >= len(c.entries) {
+		return 0, false
+	}
+	e := &c.entries[c.next]
+	if e.p != uintptr(p) || e.def != defWords(def) {
+		return 0, false
+	}
+	c.next++
+	return e.size, true
+}
@@ -7,3 +7,10 @@ type List interface {
 	// Limit (count of elements) of the list
 	Limit() uint64
 }
+
+// For slices to be a SSZ progressive list, they need to be marked as such with a pointer-receiver method.
+// Progressive lists have no limit, and are merkleized with the progressive subtree layout of EIP-7916.
+// The encoding is the same as a regular list.
+type ProgressiveList interface {
+	ProgressiveList()
+}
@@ -1,9 +1,13 @@
 package merkle
 
 import (
+	"errors"
 	. "github.com/protolambda/zssz/htr"
 )
 
+// Panicked with by Merkleize and ConstructProof if the count is over the limit.
+var ErrOverLimit = errors.New("merkleizing list that is too large, over limit")
+
 const (
 	mask0 = ^uint64((1 << (1 << iota)) - 1)
 	mask1
@@ -59,10 +63,10 @@ func GetDepth(v uint64) (out uint8) {
 	return
 }
 
-// Merkleize with log(N) space allocation
+// Merkleize with log(N) space allocation. Panics with ErrOverLimit if count is over the limit.
 func Merkleize(hasher MerkleFn, count uint64, limit uint64, leaf func(i uint64) []byte) (out [32]byte) {
 	if count > limit {
-		panic("merkleizing list that is too large, over limit")
+		panic(ErrOverLimit)
 	}
 	if limit == 0 {
 		return
@@ -124,11 +128,33 @@ func Merkleize(hasher MerkleFn, count uint64, limit uint64, leaf func(i uint64)
 	return tmp[limitDepth]
 }
 
+// MerkleizeProgressive merkleizes the leaves with the progressive subtree layout of EIP-7916:
+// subtrees of 1, 4, 16, 64, ... leaves, each hashed together with the root of the remaining subtrees.
+// There is no limit: the tree grows with the amount of leaves.
+func MerkleizeProgressive(hasher MerkleFn, count uint64, leaf func(i uint64) []byte) (out [32]byte) {
+	return merkleizeProgressive(hasher, 0, count, 1, leaf)
+}
+
+func merkleizeProgressive(hasher MerkleFn, start uint64, count uint64, subtreeLimit uint64, leaf func(i uint64) []byte) (out [32]byte) {
+	if start >= count {
+		return
+	}
+	subtreeCount := count - start
+	if subtreeCount > subtreeLimit {
+		subtreeCount = subtreeLimit
+	}
+	subtree := Merkleize(hasher, subtreeCount, subtreeLimit, func(i uint64) []byte {
+		return leaf(start + i)
+	})
+	rest := merkleizeProgressive(hasher, start+subtreeCount, count, subtreeLimit<<2, leaf)
+	return hasher.Combi(rest, subtree)
+}
+
 // ConstructProof builds a merkle-branch of the given depth, at the given index (at that depth),
 // for a list of leafs of a balanced binary tree.
 func ConstructProof(hasher MerkleFn, count uint64, limit uint64, leaf func(i uint64) []byte, index uint64) (branch [][32]byte) {
 	if count > limit {
-		panic("merkleizing list that is too large, over limit")
+		panic(ErrOverLimit)
 	}
 	if index >= limit {
 		panic("index out of range, over limit")
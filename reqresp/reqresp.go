@@ -0,0 +1,207 @@
+// Package reqresp implements the chunk framing of Eth2 req/resp messages:
+// SSZ values, prefixed with their length, compressed with the snappy framing format.
+package reqresp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/protolambda/zssz"
+	"github.com/protolambda/zssz/snappy"
+	. "github.com/protolambda/zssz/types"
+	"io"
+)
+
+// The result code of a response chunk. Chunks other than Success carry an error message.
+type ResultCode byte
+
+const (
+	Success             ResultCode = 0
+	InvalidRequest      ResultCode = 1
+	ServerError         ResultCode = 2
+	ResourceUnavailable ResultCode = 3
+)
+
+func (c ResultCode) String() string {
+	switch c {
+	case Success:
+		return "success"
+	case InvalidRequest:
+		return "invalid request"
+	case ServerError:
+		return "server error"
+	case ResourceUnavailable:
+		return "resource unavailable"
+	default:
+		return fmt.Sprintf("result code %d", byte(c))
+	}
+}
+
+// The maximum length of an error message: it is encoded as a List[byte, 256].
+const MaxErrorMessageLen = 256
+
+// The maximum length of the unsigned varint length prefix
+const maxLengthPrefixLen = binary.MaxVarintLen64
+
+// An error response chunk, as read by a ChunkReader.
+type ErrorResponse struct {
+	Code    ResultCode
+	Message string
+}
+
+func (e *ErrorResponse) Error() string {
+	return fmt.Sprintf("error response (%s): %s", e.Code, e.Message)
+}
+
+// Writes response chunks. Each chunk is written as:
+//
+//	<result code> <context bytes> <length prefix> <snappy framed SSZ payload>
+//
+// Context bytes (e.g. a fork digest) are only written for success chunks, when the protocol defines them.
+type ChunkWriter struct {
+	w      io.Writer
+	sw     *snappy.Writer
+	header [1 + 4 + maxLengthPrefixLen]byte
+}
+
+func NewChunkWriter(w io.Writer) *ChunkWriter {
+	return &ChunkWriter{w: w, sw: snappy.NewWriter(w)}
+}
+
+// Writes a success chunk of the value, with the given context bytes, if any.
+// Nothing is written if the value cannot be encoded.
+func (cw *ChunkWriter) WriteChunk(context []byte, val interface{}, sszTyp SSZ) error {
+	data, err := zssz.MarshalSSZ(val, sszTyp)
+	if err != nil {
+		return err
+	}
+	return cw.writeChunk(Success, context, data)
+}
+
+// Writes an error chunk. Messages longer than MaxErrorMessageLen are truncated.
+func (cw *ChunkWriter) WriteError(code ResultCode, message string) error {
+	if code == Success {
+		return fmt.Errorf("cannot write error chunk with success result code")
+	}
+	if len(message) > MaxErrorMessageLen {
+		message = message[:MaxErrorMessageLen]
+	}
+	return cw.writeChunk(code, nil, []byte(message))
+}
+
+func (cw *ChunkWriter) writeChunk(code ResultCode, context []byte, data []byte) error {
+	// the header is buffered to write it at once, unless the context is unusually large
+	header := append(cw.header[:0], byte(code))
+	header = append(header, context...)
+	var prefix [maxLengthPrefixLen]byte
+	header = append(header, prefix[:binary.PutUvarint(prefix[:], uint64(len(data)))]...)
+	if _, err := cw.w.Write(header); err != nil {
+		return err
+	}
+	cw.sw.Reset(cw.w)
+	if _, err := cw.sw.Write(data); err != nil {
+		return err
+	}
+	return cw.sw.Flush()
+}
+
+// Reads response chunks, as written by a ChunkWriter.
+// The underlying reader is buffered, to read the result code and length prefix byte by byte:
+// a ChunkReader should be used for all remaining chunks of the stream.
+type ChunkReader struct {
+	r          *bufio.Reader
+	sr         *snappy.Reader
+	contextLen int
+}
+
+// Create a reader of chunks with contextLen context bytes in success chunks, 0 if the protocol defines none.
+func NewChunkReader(r io.Reader, contextLen int) *ChunkReader {
+	return &ChunkReader{r: bufio.NewReader(r), contextLen: contextLen}
+}
+
+// Reads the next chunk into the value, and returns the context bytes of the chunk.
+// The length prefix is checked against the bounds of the type, and the compressed payload against its maximum length,
+// before reading the payload.
+// Returns io.EOF if there are no more chunks, and an *ErrorResponse if the chunk is an error.
+func (cr *ChunkReader) ReadChunk(val interface{}, sszTyp SSZ) (context []byte, err error) {
+	code, err := cr.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if ResultCode(code) != Success {
+		message, err := cr.readErrorMessage()
+		if err != nil {
+			return nil, err
+		}
+		return nil, &ErrorResponse{Code: ResultCode(code), Message: message}
+	}
+	if cr.contextLen > 0 {
+		context = make([]byte, cr.contextLen)
+		if _, err := io.ReadFull(cr.r, context); err != nil {
+			return nil, noEOF(err)
+		}
+	}
+	length, err := cr.readLength(sszTyp.MinLen(), sszTyp.MaxLen())
+	if err != nil {
+		return nil, err
+	}
+	sr := cr.payloadReader(length)
+	if err := zssz.Decode(sr, length, val, sszTyp); err != nil {
+		return nil, noEOF(err)
+	}
+	if sr.Buffered() > 0 {
+		return nil, fmt.Errorf("compressed payload is larger than length prefix %d", length)
+	}
+	return context, nil
+}
+
+func (cr *ChunkReader) readErrorMessage() (string, error) {
+	length, err := cr.readLength(0, MaxErrorMessageLen)
+	if err != nil {
+		return "", err
+	}
+	// the SSZ encoding of a byte list is just the bytes
+	message := make([]byte, length)
+	sr := cr.payloadReader(length)
+	if _, err := io.ReadFull(sr, message); err != nil {
+		return "", noEOF(err)
+	}
+	if sr.Buffered() > 0 {
+		return "", fmt.Errorf("compressed error message is larger than length prefix %d", length)
+	}
+	return string(message), nil
+}
+
+func (cr *ChunkReader) readLength(minLen uint64, maxLen uint64) (uint64, error) {
+	length, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read length prefix: %v", noEOF(err))
+	}
+	if length < minLen || length > maxLen {
+		return 0, fmt.Errorf("length prefix %d is not within the bounds of the type: [%d, %d]", length, minLen, maxLen)
+	}
+	return length, nil
+}
+
+// Reads the compressed payload of the given length, without reading more than its maximum compressed length.
+func (cr *ChunkReader) payloadReader(length uint64) *snappy.Reader {
+	limit := int64(1<<63 - 1)
+	// lengths this large are not within the limits of any reader, only avoid overflows
+	if length < 1<<40 {
+		limit = int64(snappy.MaxFramedLen(int(length)))
+	}
+	if cr.sr == nil {
+		cr.sr = snappy.NewReader(io.LimitReader(cr.r, limit))
+	} else {
+		cr.sr.Reset(io.LimitReader(cr.r, limit))
+	}
+	return cr.sr
+}
+
+// An EOF within a chunk is unexpected.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package reqresp
+
+import (
+	"bytes"
+	"github.com/protolambda/zssz"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+type blockBody []byte
+
+func (*blockBody) Limit() uint64 { return 1 << 20 }
+
+type block struct {
+	Slot uint64
+	Body blockBody
+}
+
+var blockSSZ = zssz.GetSSZ((*block)(nil))
+
+func TestChunks(t *testing.T) {
+	blocks := []block{
+		{Slot: 1, Body: nil},
+		{Slot: 2, Body: []byte("hello world")},
+		// larger than a snappy chunk
+		{Slot: 3, Body: bytes.Repeat([]byte("abcdefgh"), 20000)},
+	}
+	digest := []byte{0xde, 0xad, 0xbe, 0xef}
+	longMessage := strings.Repeat("x", 300)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		cw := NewChunkWriter(server)
+		for i := range blocks {
+			if err := cw.WriteChunk(digest, &blocks[i], blockSSZ); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if err := cw.WriteError(ResourceUnavailable, longMessage); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	cr := NewChunkReader(client, len(digest))
+	for i := range blocks {
+		var b block
+		context, err := cr.ReadChunk(&b, blockSSZ)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(context, digest) {
+			t.Fatalf("chunk %d: context %x, expected %x", i, context, digest)
+		}
+		if b.Slot != blocks[i].Slot || !bytes.Equal(b.Body, blocks[i].Body) {
+			t.Fatalf("chunk %d: decoded different block", i)
+		}
+	}
+	var b block
+	_, err := cr.ReadChunk(&b, blockSSZ)
+	errResp, ok := err.(*ErrorResponse)
+	if !ok {
+		t.Fatalf("expected error response, got: %v", err)
+	}
+	if errResp.Code != ResourceUnavailable || errResp.Message != longMessage[:MaxErrorMessageLen] {
+		t.Fatalf("unexpected error response: %v", errResp)
+	}
+	if _, err := cr.ReadChunk(&b, blockSSZ); err != io.EOF {
+		t.Fatalf("expected EOF, got: %v", err)
+	}
+}
+
+type attestationBits []byte
+
+func (*attestationBits) Limit() uint64 { return 4 }
+
+type attestations []attestationBits
+
+func (*attestations) Limit() uint64 { return 2 }
+
+type nestedBody struct {
+	Slot         uint64
+	Attestations attestations
+}
+
+func TestMaxLenChunk(t *testing.T) {
+	sszTyp := zssz.GetSSZ((*nestedBody)(nil))
+	// the largest value: the length prefix is at the max length of the type, including the offsets
+	body := nestedBody{Slot: 1, Attestations: attestations{{1, 2, 3, 4}, {5, 6, 7, 8}}}
+	if size := zssz.SizeOf(&body, sszTyp); size != sszTyp.MaxLen() {
+		t.Fatalf("expected size %d to be the max length %d", size, sszTyp.MaxLen())
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		if err := NewChunkWriter(server).WriteChunk(nil, &body, sszTyp); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var b nestedBody
+	if _, err := NewChunkReader(client, 0).ReadChunk(&b, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if b.Slot != body.Slot || len(b.Attestations) != 2 || !bytes.Equal(b.Attestations[1], body.Attestations[1]) {
+		t.Fatalf("decoded different body")
+	}
+}
+
+func TestInvalidChunks(t *testing.T) {
+	var valid bytes.Buffer
+	if err := NewChunkWriter(&valid).WriteChunk(nil, &block{Slot: 1, Body: []byte("abc")}, blockSSZ); err != nil {
+		t.Fatal(err)
+	}
+	// the compressed payload of a 15-byte block
+	payload := valid.Bytes()[2:]
+	cases := map[string][]byte{
+		"below min length":     {0, 4},
+		"above max length":     {0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+		"truncated length":     {0, 0x80},
+		"varint overflow":      {0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		"shorter than length":  append([]byte{0, 16}, payload...),
+		"longer than length":   append([]byte{0, 14}, payload...),
+		"truncated payload":    valid.Bytes()[:valid.Len()-1],
+		"error message length": {byte(ServerError), 0x81, 0x02},
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() {
+				defer server.Close()
+				server.Write(data)
+			}()
+			var b block
+			_, err := NewChunkReader(client, 0).ReadChunk(&b, blockSSZ)
+			if err == nil || err == io.EOF {
+				t.Fatalf("expected error, got: %v", err)
+			}
+			if _, ok := err.(*ErrorResponse); ok {
+				t.Fatalf("expected invalid chunk, got: %v", err)
+			}
+		})
+	}
+}
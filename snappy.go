@@ -0,0 +1,64 @@
+package zssz
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/protolambda/zssz/snappy"
+	. "github.com/protolambda/zssz/types"
+	"io"
+	"io/ioutil"
+)
+
+// Encodes the value, compressed with the snappy block format, as used for gossip messages.
+func EncodeSnappy(val interface{}, sszTyp SSZ) ([]byte, error) {
+	data, err := MarshalSSZ(val, sszTyp)
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+// Decodes the value from the snappy block format.
+// The decompressed length is checked against the bounds of the type before allocating for it.
+func DecodeSnappy(data []byte, val interface{}, sszTyp SSZ) error {
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return err
+	}
+	if size := uint64(n); size < sszTyp.MinLen() || size > sszTyp.MaxLen() {
+		return fmt.Errorf("decompressed length %d is not within the bounds of the type: [%d, %d]",
+			size, sszTyp.MinLen(), sszTyp.MaxLen())
+	}
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return err
+	}
+	return Decode(bytes.NewReader(decoded), uint64(len(decoded)), val, sszTyp)
+}
+
+// Encodes the value to the writer, compressed with the snappy framing format, as used for req/resp messages.
+func EncodeSnappyFramed(w io.Writer, val interface{}, sszTyp SSZ) error {
+	sw := snappy.NewWriter(w)
+	if _, err := Encode(sw, val, sszTyp); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// Decodes the value from the reader, compressed with the snappy framing format, until EOF.
+// Reading stops as soon as the decompressed data is larger than the maximum length of the type.
+func DecodeSnappyFramed(r io.Reader, val interface{}, sszTyp SSZ) error {
+	maxLen := sszTyp.MaxLen()
+	limit := int64(maxLen) + 1
+	if limit <= 0 || uint64(limit) != maxLen+1 {
+		limit = 1<<63 - 1
+	}
+	decoded, err := ioutil.ReadAll(io.LimitReader(snappy.NewReader(r), limit))
+	if err != nil {
+		return err
+	}
+	if size := uint64(len(decoded)); size > maxLen {
+		return fmt.Errorf("decompressed data is larger than the maximum length of the type: %d", maxLen)
+	}
+	return Decode(bytes.NewReader(decoded), uint64(len(decoded)), val, sszTyp)
+}
@@ -0,0 +1,266 @@
+// Package snappy implements the snappy block and framing formats, as used by Eth2 networking,
+// with only the standard Go libraries.
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrCorrupt     = errors.New("snappy: corrupt input")
+	ErrTooLarge    = errors.New("snappy: decoded block is too large")
+	ErrUnsupported = errors.New("snappy: unsupported input")
+)
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+)
+
+const (
+	// The input is compressed in fragments of this size, to keep match offsets within 16 bits.
+	maxFragmentSize = 1 << 16
+	// Fragments smaller than this are just emitted as literal.
+	minFragmentSize = 17
+	// The minimum length of a match
+	minMatchLen = 4
+
+	tableBits = 14
+	tableSize = 1 << tableBits
+)
+
+// The maximum length of the encoding of srcLen bytes.
+func MaxEncodedLen(srcLen int) int {
+	return 32 + srcLen + srcLen/6
+}
+
+// Gets the length of the decoded block, as declared in its header.
+func DecodedLen(src []byte) (int, error) {
+	n, _, err := decodedLen(src)
+	return n, err
+}
+
+func decodedLen(src []byte) (blockLen int, headerLen int, err error) {
+	v, n := binary.Uvarint(src)
+	if n <= 0 || v > 0xffffffff {
+		return 0, 0, ErrCorrupt
+	}
+	if int(v) < 0 || uint64(int(v)) != v {
+		return 0, 0, ErrTooLarge
+	}
+	return int(v), n, nil
+}
+
+// Encodes src in the snappy block format, into dst if it is large enough, see MaxEncodedLen.
+// Returns the encoded part of dst.
+func Encode(dst []byte, src []byte) []byte {
+	if n := MaxEncodedLen(len(src)); len(dst) < n {
+		dst = make([]byte, n)
+	}
+	d := binary.PutUvarint(dst, uint64(len(src)))
+	var table [tableSize]uint16
+	for len(src) > 0 {
+		fragment := src
+		if len(fragment) > maxFragmentSize {
+			fragment = fragment[:maxFragmentSize]
+		}
+		src = src[len(fragment):]
+		if len(fragment) < minFragmentSize {
+			d += emitLiteral(dst[d:], fragment)
+		} else {
+			d += encodeFragment(dst[d:], fragment, &table)
+		}
+	}
+	return dst[:d]
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i : i+4])
+}
+
+func hash(v uint32) uint32 {
+	return (v * 0x1e35a7bd) >> (32 - tableBits)
+}
+
+// Greedily matches 4-byte sequences against the last position with the same hash.
+func encodeFragment(dst []byte, src []byte, table *[tableSize]uint16) (d int) {
+	for i := range table {
+		table[i] = 0
+	}
+	// start of the pending literal
+	lit := 0
+	for i := 0; i+minMatchLen <= len(src); {
+		v := load32(src, i)
+		h := hash(v)
+		candidate := int(table[h])
+		table[h] = uint16(i)
+		// the table starts zeroed, the bytes are compared to not trust it
+		if candidate >= i || load32(src, candidate) != v {
+			i++
+			continue
+		}
+		matchLen := minMatchLen
+		for i+matchLen < len(src) && src[candidate+matchLen] == src[i+matchLen] {
+			matchLen++
+		}
+		if lit < i {
+			d += emitLiteral(dst[d:], src[lit:i])
+		}
+		d += emitCopy(dst[d:], i-candidate, matchLen)
+		i += matchLen
+		lit = i
+	}
+	if lit < len(src) {
+		d += emitLiteral(dst[d:], src[lit:])
+	}
+	return d
+}
+
+// Literals are at most maxFragmentSize long.
+func emitLiteral(dst []byte, lit []byte) int {
+	n := len(lit) - 1
+	i := 0
+	switch {
+	case n < 60:
+		dst[0] = byte(n)<<2 | tagLiteral
+		i = 1
+	case n < 1<<8:
+		dst[0] = 60<<2 | tagLiteral
+		dst[1] = byte(n)
+		i = 2
+	default:
+		dst[0] = 61<<2 | tagLiteral
+		dst[1] = byte(n)
+		dst[2] = byte(n >> 8)
+		i = 3
+	}
+	return i + copy(dst[i:], lit)
+}
+
+// Offsets are less than maxFragmentSize, lengths at least minMatchLen.
+func emitCopy(dst []byte, offset int, length int) int {
+	i := 0
+	// copies of 2-byte offsets are at most 64 bytes long, and the remainder should be at least 4 bytes.
+	for length >= 68 {
+		dst[i] = 63<<2 | tagCopy2
+		dst[i+1] = byte(offset)
+		dst[i+2] = byte(offset >> 8)
+		i += 3
+		length -= 64
+	}
+	if length > 64 {
+		dst[i] = 59<<2 | tagCopy2
+		dst[i+1] = byte(offset)
+		dst[i+2] = byte(offset >> 8)
+		i += 3
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		dst[i] = byte(length-1)<<2 | tagCopy2
+		dst[i+1] = byte(offset)
+		dst[i+2] = byte(offset >> 8)
+		return i + 3
+	}
+	dst[i] = byte(offset>>8)<<5 | byte(length-4)<<2 | tagCopy1
+	dst[i+1] = byte(offset)
+	return i + 2
+}
+
+// Decodes the snappy block into dst if it is large enough, see DecodedLen. Returns the decoded part of dst.
+func Decode(dst []byte, src []byte) ([]byte, error) {
+	n, headerLen, err := decodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	if err := decode(dst, src[headerLen:]); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// Decodes the elements of a block into dst, which must be exactly the decoded length.
+func decode(dst []byte, src []byte) error {
+	d, s := 0, 0
+	for s < len(src) {
+		var offset, length int
+		switch src[s] & 0x03 {
+		case tagLiteral:
+			x := uint32(src[s] >> 2)
+			switch {
+			case x < 60:
+				s++
+			case x == 60:
+				s += 2
+				if s > len(src) {
+					return ErrCorrupt
+				}
+				x = uint32(src[s-1])
+			case x == 61:
+				s += 3
+				if s > len(src) {
+					return ErrCorrupt
+				}
+				x = uint32(src[s-2]) | uint32(src[s-1])<<8
+			case x == 62:
+				s += 4
+				if s > len(src) {
+					return ErrCorrupt
+				}
+				x = uint32(src[s-3]) | uint32(src[s-2])<<8 | uint32(src[s-1])<<16
+			default:
+				s += 5
+				if s > len(src) {
+					return ErrCorrupt
+				}
+				x = binary.LittleEndian.Uint32(src[s-4 : s])
+			}
+			length = int(x) + 1
+			if length <= 0 || length > len(dst)-d || length > len(src)-s {
+				return ErrCorrupt
+			}
+			copy(dst[d:], src[s:s+length])
+			d += length
+			s += length
+			continue
+		case tagCopy1:
+			s += 2
+			if s > len(src) {
+				return ErrCorrupt
+			}
+			length = 4 + int(src[s-2]>>2)&0x07
+			offset = int(uint32(src[s-2])&0xe0<<3 | uint32(src[s-1]))
+		case tagCopy2:
+			s += 3
+			if s > len(src) {
+				return ErrCorrupt
+			}
+			length = 1 + int(src[s-3]>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s-2 : s]))
+		case tagCopy4:
+			s += 5
+			if s > len(src) {
+				return ErrCorrupt
+			}
+			length = 1 + int(src[s-5]>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s-4 : s]))
+		}
+		if offset <= 0 || offset > d || length > len(dst)-d {
+			return ErrCorrupt
+		}
+		// the copy may overlap with itself, to repeat a sequence.
+		for end := d + length; d < end; d++ {
+			dst[d] = dst[d-offset]
+		}
+	}
+	if d != len(dst) {
+		return ErrCorrupt
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package snappy
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	chunkTypeCompressedData   = 0x00
+	chunkTypeUncompressedData = 0x01
+	chunkTypePadding          = 0xfe
+	chunkTypeStreamIdentifier = 0xff
+
+	// Chunk types 0x02-0x7f are reserved and unskippable, 0x80-0xfd reserved and skippable.
+	maxUnskippableChunkType = 0x7f
+
+	magicBody = "sNaPpY"
+
+	chunkHeaderLen = 4
+	checksumLen    = 4
+	// The maximum amount of uncompressed data in a chunk
+	maxChunkDataLen = 1 << 16
+	// The maximum length of a chunk, excluding the chunk header
+	maxChunkLen = checksumLen + 32 + maxChunkDataLen + maxChunkDataLen/6
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// The maximum length of n bytes in the framing format: the stream identifier, and the chunks.
+func MaxFramedLen(n int) int {
+	chunks := (n + maxChunkDataLen - 1) / maxChunkDataLen
+	return chunkHeaderLen + len(magicBody) + chunks*(chunkHeaderLen+checksumLen+32) + n + n/6
+}
+
+// The masked CRC-32C checksum of the uncompressed data of a chunk
+func checksum(b []byte) uint32 {
+	c := crc32.Update(0, crcTable, b)
+	return (c>>15 | c<<17) + 0xa282ead8
+}
+
+// Writes data in the snappy framing format, in chunks of up to 64 KiB of uncompressed data.
+// Data is buffered until there is a full chunk; Flush or Close to write the remainder.
+type Writer struct {
+	w   io.Writer
+	err error
+	// uncompressed data of the next chunk
+	buf []byte
+	// the chunk to write
+	out           []byte
+	wroteIdentity bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:   w,
+		buf: make([]byte, 0, maxChunkDataLen),
+		out: make([]byte, chunkHeaderLen+maxChunkLen),
+	}
+}
+
+// Reset the writer to write a new stream to the given writer, discarding any buffered data.
+func (w *Writer) Reset(writer io.Writer) {
+	w.w = writer
+	w.err = nil
+	w.buf = w.buf[:0]
+	w.wroteIdentity = false
+}
+
+func (w *Writer) Write(p []byte) (n int, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	for len(p) > 0 {
+		// skip the buffer for full chunks
+		if len(w.buf) == 0 && len(p) >= maxChunkDataLen {
+			if err := w.writeChunk(p[:maxChunkDataLen]); err != nil {
+				return n, err
+			}
+			n += maxChunkDataLen
+			p = p[maxChunkDataLen:]
+			continue
+		}
+		k := maxChunkDataLen - len(w.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		w.buf = append(w.buf, p[:k]...)
+		n += k
+		p = p[k:]
+		if len(w.buf) == maxChunkDataLen {
+			if err := w.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Writes the buffered data as chunk, if any.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeChunk(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// Flushes the buffered data. The underlying writer is not closed.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+func (w *Writer) writeChunk(data []byte) error {
+	if !w.wroteIdentity {
+		if _, err := w.w.Write([]byte("\xff\x06\x00\x00" + magicBody)); err != nil {
+			w.err = err
+			return err
+		}
+		w.wroteIdentity = true
+	}
+	chunkType := byte(chunkTypeCompressedData)
+	body := Encode(w.out[chunkHeaderLen+checksumLen:], data)
+	// only compress if it saves at least 12.5%
+	if len(body) >= len(data)-len(data)/8 {
+		chunkType = chunkTypeUncompressedData
+		body = data
+	}
+	chunkLen := checksumLen + len(body)
+	header := w.out[:chunkHeaderLen+checksumLen]
+	header[0] = chunkType
+	header[1] = byte(chunkLen)
+	header[2] = byte(chunkLen >> 8)
+	header[3] = byte(chunkLen >> 16)
+	binary.LittleEndian.PutUint32(header[4:], checksum(data))
+	if chunkType == chunkTypeCompressedData {
+		_, w.err = w.w.Write(w.out[:chunkHeaderLen+chunkLen])
+	} else if _, w.err = w.w.Write(header); w.err == nil {
+		_, w.err = w.w.Write(body)
+	}
+	return w.err
+}
+
+// Reads data in the snappy framing format. Memory use is bounded by the maximum chunk size.
+type Reader struct {
+	r   io.Reader
+	err error
+	// the chunk, as read
+	buf []byte
+	// the uncompressed data of the chunk, unread in decoded[i:j]
+	decoded      []byte
+	i, j         int
+	readIdentity bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r:       r,
+		buf:     make([]byte, maxChunkLen),
+		decoded: make([]byte, maxChunkDataLen),
+	}
+}
+
+// Reset the reader to read a new stream from the given reader, discarding any buffered data.
+func (r *Reader) Reset(reader io.Reader) {
+	r.r = reader
+	r.err = nil
+	r.i, r.j = 0, 0
+	r.readIdentity = false
+}
+
+// The number of decoded bytes that are buffered, but not read yet.
+func (r *Reader) Buffered() int {
+	return r.j - r.i
+}
+
+// Reads exactly len(p) bytes. An EOF before any bytes is only allowed if allowEOF, and corrupt otherwise.
+func (r *Reader) readFull(p []byte, allowEOF bool) bool {
+	if _, r.err = io.ReadFull(r.r, p); r.err != nil {
+		if r.err == io.ErrUnexpectedEOF || (r.err == io.EOF && !allowEOF) {
+			r.err = ErrCorrupt
+		}
+		return false
+	}
+	return true
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for {
+		if r.i < r.j {
+			n := copy(p, r.decoded[r.i:r.j])
+			r.i += n
+			return n, nil
+		}
+		if !r.readFull(r.buf[:chunkHeaderLen], true) {
+			return 0, r.err
+		}
+		chunkType := r.buf[0]
+		if !r.readIdentity && chunkType != chunkTypeStreamIdentifier {
+			r.err = ErrCorrupt
+			return 0, r.err
+		}
+		chunkLen := int(r.buf[1]) | int(r.buf[2])<<8 | int(r.buf[3])<<16
+		switch {
+		case chunkType == chunkTypeCompressedData || chunkType == chunkTypeUncompressedData:
+			if chunkLen < checksumLen || chunkLen > len(r.buf) {
+				r.err = ErrCorrupt
+				return 0, r.err
+			}
+			chunk := r.buf[:chunkLen]
+			if !r.readFull(chunk, false) {
+				return 0, r.err
+			}
+			sum := binary.LittleEndian.Uint32(chunk[:checksumLen])
+			body := chunk[checksumLen:]
+			var data []byte
+			if chunkType == chunkTypeCompressedData {
+				n, err := DecodedLen(body)
+				if err != nil {
+					r.err = err
+					return 0, r.err
+				}
+				if n > maxChunkDataLen {
+					r.err = ErrCorrupt
+					return 0, r.err
+				}
+				if data, err = Decode(r.decoded, body); err != nil {
+					r.err = err
+					return 0, r.err
+				}
+			} else {
+				if len(body) > maxChunkDataLen {
+					r.err = ErrCorrupt
+					return 0, r.err
+				}
+				data = r.decoded[:copy(r.decoded, body)]
+			}
+			if checksum(data) != sum {
+				r.err = ErrCorrupt
+				return 0, r.err
+			}
+			r.i, r.j = 0, len(data)
+		case chunkType == chunkTypeStreamIdentifier:
+			if chunkLen != len(magicBody) {
+				r.err = ErrCorrupt
+				return 0, r.err
+			}
+			if !r.readFull(r.buf[:chunkLen], false) {
+				return 0, r.err
+			}
+			if string(r.buf[:chunkLen]) != magicBody {
+				r.err = ErrCorrupt
+				return 0, r.err
+			}
+			r.readIdentity = true
+		case chunkType <= maxUnskippableChunkType:
+			r.err = ErrUnsupported
+			return 0, r.err
+		default:
+			// chunkTypePadding, or a reserved skippable chunk
+			if _, err := io.CopyN(ioutil.Discard, r.r, int64(chunkLen)); err != nil {
+				r.err = ErrCorrupt
+				return 0, r.err
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package snappy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testInputs() map[string][]byte {
+	rng := rand.New(rand.NewSource(123))
+	random := make([]byte, 200000)
+	rng.Read(random)
+	// random, but with a small alphabet, for short matches at any offset
+	alphabet := make([]byte, 150000)
+	for i := range alphabet {
+		alphabet[i] = "abcd"[rng.Intn(4)]
+	}
+	return map[string][]byte{
+		"empty":      {},
+		"short":      []byte("hello world"),
+		"repeat":     []byte(strings.Repeat("abc", 1000)),
+		"zeroes":     make([]byte, 300000),
+		"random":     random,
+		"alphabet":   alphabet,
+		"long match": append(append(append([]byte{}, random[:70000]...), random[:70000]...), 1, 2, 3),
+	}
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	for name, input := range testInputs() {
+		t.Run(name, func(t *testing.T) {
+			encoded := Encode(nil, input)
+			if len(encoded) > MaxEncodedLen(len(input)) {
+				t.Fatalf("encoded %d bytes, more than the max %d", len(encoded), MaxEncodedLen(len(input)))
+			}
+			if n, err := DecodedLen(encoded); err != nil {
+				t.Fatal(err)
+			} else if n != len(input) {
+				t.Fatalf("decoded length %d, expected %d", n, len(input))
+			}
+			decoded, err := Decode(nil, encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, input) {
+				t.Fatal("decoded different data")
+			}
+		})
+	}
+}
+
+func TestBlockDecode(t *testing.T) {
+	cases := []struct {
+		name    string
+		encoded string
+		decoded string
+	}{
+		{"empty", "\x00", ""},
+		{"literal", "\x0b\x28hello world", "hello world"},
+		{"copy1 overlap", "\x0c\x08abc\x15\x03", "abcabcabcabc"},
+		{"copy2", "\x08\x04ab\x16\x02\x00", "abababab"},
+		{"copy4", "\x06\x00a\x13\x01\x00\x00\x00", "aaaaaa"},
+		{"literal 1-byte length", "\x40\xf0\x3f" + strings.Repeat("x", 64), strings.Repeat("x", 64)},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := Decode(nil, []byte(tt.encoded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decoded) != tt.decoded {
+				t.Fatalf("decoded %q, expected %q", decoded, tt.decoded)
+			}
+		})
+	}
+}
+
+func TestBlockDecodeCorrupt(t *testing.T) {
+	cases := map[string]string{
+		"no header":          "",
+		"too short":          "\x05\x10abc",
+		"too long":           "\x02\x08abc",
+		"offset before data": "\x08\x04ab\x16\x03\x00",
+		"zero offset":        "\x08\x04ab\x16\x00\x00",
+		"truncated copy":     "\x08\x04ab\x16\x02",
+		"truncated literal":  "\x40\xf0",
+	}
+	for name, encoded := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(nil, []byte(encoded)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestFramingRoundTrip(t *testing.T) {
+	for name, input := range testInputs() {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			// write in uneven parts, to fill chunks across writes
+			for rest := input; len(rest) > 0; {
+				k := 40000
+				if k > len(rest) {
+					k = len(rest)
+				}
+				if _, err := w.Write(rest[:k]); err != nil {
+					t.Fatal(err)
+				}
+				rest = rest[k:]
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if len(input) > 0 && !bytes.HasPrefix(buf.Bytes(), []byte("\xff\x06\x00\x00sNaPpY")) {
+				t.Fatal("expected stream identifier")
+			}
+			decoded, err := ioutil.ReadAll(NewReader(&buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, input) {
+				t.Fatal("decoded different data")
+			}
+		})
+	}
+}
+
+func TestFramingRead(t *testing.T) {
+	identifier := "\xff\x06\x00\x00sNaPpY"
+	// uncompressed chunk of "foo", with its masked CRC-32C checksum
+	sum := checksum([]byte("foo"))
+	chunk := string([]byte{chunkTypeUncompressedData, 7, 0, 0, byte(sum), byte(sum >> 8), byte(sum >> 16), byte(sum >> 24)}) + "foo"
+	padding := "\xfe\x02\x00\x00\x00\x00"
+	cases := []struct {
+		name    string
+		stream  string
+		decoded string
+		ok      bool
+	}{
+		{"chunk", identifier + chunk, "foo", true},
+		{"padding", identifier + padding + chunk, "foo", true},
+		{"skippable", identifier + "\x80\x01\x00\x00x" + chunk, "foo", true},
+		{"concatenated", identifier + chunk + identifier + chunk, "foofoo", true},
+		{"no identifier", chunk, "", false},
+		{"unskippable", identifier + "\x02\x01\x00\x00x", "", false},
+		{"bad checksum", identifier + chunk[:len(chunk)-1] + "x", "", false},
+		{"truncated", identifier + chunk[:len(chunk)-1], "", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := ioutil.ReadAll(NewReader(strings.NewReader(tt.stream)))
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decoded) != tt.decoded {
+				t.Fatalf("decoded %q, expected %q", decoded, tt.decoded)
+			}
+		})
+	}
+}
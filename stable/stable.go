@@ -0,0 +1,23 @@
+package stable
+
+// Marker type for SSZ StableContainer structs (EIP-7495).
+// The first field of the struct must be of this type, tagged with the capacity of the stable container.
+// All other fields must be pointers: a nil pointer is an absent (optional) field. Example:
+//
+//	type Shape struct {
+//		_      stable.Container `ssz:"capacity=4"`
+//		Side   *uint16
+//		Color  *uint8
+//		Radius *uint16
+//	}
+//
+// Profiles of a stable container are declared with a zero-length array of the base type as first field,
+// tagged as profile. Non-pointer fields, and pointer fields tagged as required, are required.
+// Pointer fields are optional otherwise. Fields must match the base fields by name, type and order. Example:
+//
+//	type Square struct {
+//		_     [0]Shape `ssz:"profile"`
+//		Side  uint16
+//		Color uint8
+//	}
+type Container struct{}
@@ -6,6 +6,7 @@ import (
 	. "github.com/protolambda/zssz/enc"
 	. "github.com/protolambda/zssz/htr"
 	"github.com/protolambda/zssz/merkle"
+	"github.com/protolambda/zssz/uints"
 	"github.com/protolambda/zssz/util/ptrutil"
 	"reflect"
 	"unsafe"
@@ -28,6 +29,50 @@ func GetBasicSSZElemType(kind reflect.Kind) (SSZ, error) {
 	}
 }
 
+var uintMeta = reflect.TypeOf((*uints.UintMeta)(nil)).Elem()
+
+// Checks if the type is a uint128 or uint256, i.e. a uint64 limbs array with uint meta information.
+func IsUintN(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Array && typ.Elem().Kind() == reflect.Uint64 && reflect.PtrTo(typ).Implements(uintMeta)
+}
+
+// Gets the SSZ uint128 or uint256 type for the given uint64 limbs array type.
+func GetUintNSSZ(typ reflect.Type) (SSZ, error) {
+	if !IsUintN(typ) {
+		return nil, fmt.Errorf("typ %s is not a uint64 limbs array with uint meta information", typ.String())
+	}
+	typedNil := reflect.New(reflect.PtrTo(typ)).Elem().Interface().(uints.UintMeta)
+	byteLen := typedNil.UintByteLen()
+	if byteLen != uint64(typ.Size()) {
+		return nil, fmt.Errorf("uint type has %d bytes in memory, but declares a %d bytes uint", typ.Size(), byteLen)
+	}
+	switch byteLen {
+	case 16:
+		return SSZUint128{}, nil
+	case 32:
+		return SSZUint256{}, nil
+	default:
+		return nil, fmt.Errorf("uint of %d bytes is not supported", byteLen)
+	}
+}
+
+// Like GetBasicSSZElemType, but also recognizes uint128 and uint256 types.
+func GetBasicSSZElemTypeOf(typ reflect.Type) (SSZ, error) {
+	if IsUintN(typ) {
+		return GetUintNSSZ(typ)
+	}
+	return GetBasicSSZElemType(typ.Kind())
+}
+
+// Basic elements larger than 8 bytes are represented as little-endian ordered uint64 limbs,
+// and only need their bytes to be swapped per limb.
+func basicWordSize(elemSize uint64) uint8 {
+	if elemSize > 8 {
+		return 8
+	}
+	return uint8(elemSize)
+}
+
 // WARNING: for little-endian architectures only, or the elem-length has to be 1 byte
 func LittleEndianBasicSeriesEncode(eb *EncodingWriter, p unsafe.Pointer, bytesLen uint64) error {
 	bytesSh := ptrutil.GetSliceHeader(p, bytesLen)
@@ -65,11 +110,11 @@ func LittleEndianBasicSeriesDecode(dr *DecodingReader, p unsafe.Pointer, bytesLe
 }
 
 // WARNING: for little-endian architectures only, or the elem-length has to be 1 byte
-func LittleEndianBasicSeriesHTR(h MerkleFn, p unsafe.Pointer, bytesLen uint64, bytesLimit uint64) [32]byte {
+func LittleEndianBasicSeriesLeaf(p unsafe.Pointer, bytesLen uint64) func(i uint64) []byte {
 	bytesSh := ptrutil.GetSliceHeader(p, bytesLen)
 	data := *(*[]byte)(unsafe.Pointer(bytesSh))
 
-	leaf := func(i uint64) []byte {
+	return func(i uint64) []byte {
 		s := i << 5
 		e := (i + 1) << 5
 		// pad the data
@@ -80,6 +125,11 @@ func LittleEndianBasicSeriesHTR(h MerkleFn, p unsafe.Pointer, bytesLen uint64, b
 		}
 		return data[s:e]
 	}
+}
+
+// WARNING: for little-endian architectures only, or the elem-length has to be 1 byte
+func LittleEndianBasicSeriesHTR(h MerkleFn, p unsafe.Pointer, bytesLen uint64, bytesLimit uint64) [32]byte {
+	leaf := LittleEndianBasicSeriesLeaf(p, bytesLen)
 	leafCount := (bytesLen + 31) >> 5
 	leafLimit := (bytesLimit + 31) >> 5
 	return merkle.Merkleize(h, leafCount, leafLimit, leaf)
@@ -100,12 +150,12 @@ func BigToLittleEndianChunk(data [32]byte, elemSize uint8) (out [32]byte) {
 	return
 }
 
-// counter-part of LittleEndianBasicSeriesHTR
-func BigEndianBasicSeriesHTR(h MerkleFn, p unsafe.Pointer, bytesLen uint64, bytesLimit uint64, elemSize uint8) [32]byte {
+// counter-part of LittleEndianBasicSeriesLeaf
+func BigEndianBasicSeriesLeaf(p unsafe.Pointer, bytesLen uint64, elemSize uint8) func(i uint64) []byte {
 	bytesSh := ptrutil.GetSliceHeader(p, bytesLen)
 	data := *(*[]byte)(unsafe.Pointer(bytesSh))
 
-	leaf := func(i uint64) []byte {
+	return func(i uint64) []byte {
 		s := i << 5
 		e := (i + 1) << 5
 		d := [32]byte{}
@@ -117,6 +167,11 @@ func BigEndianBasicSeriesHTR(h MerkleFn, p unsafe.Pointer, bytesLen uint64, byte
 		d = BigToLittleEndianChunk(d, elemSize)
 		return d[:]
 	}
+}
+
+// counter-part of LittleEndianBasicSeriesHTR
+func BigEndianBasicSeriesHTR(h MerkleFn, p unsafe.Pointer, bytesLen uint64, bytesLimit uint64, elemSize uint8) [32]byte {
+	leaf := BigEndianBasicSeriesLeaf(p, bytesLen, elemSize)
 	leafCount := (bytesLen + 31) >> 5
 	leafLimit := (bytesLimit + 31) >> 5
 	return merkle.Merkleize(h, leafCount, leafLimit, leaf)
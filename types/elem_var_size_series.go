@@ -43,6 +43,28 @@ func EncodeVarSeries(encFn EncoderFn, sizeFn SizeFn, length uint64, elemMemSize
 	return nil
 }
 
+// Like EncodeVarSeries, but with the offsets written as placeholders, and patched once each element is written,
+// for writers that are Patching.
+func EncodePatchedVarSeries(encFn EncoderFn, length uint64, elemMemSize uintptr, eb *EncodingWriter, p unsafe.Pointer) error {
+	start := eb.Written()
+	if err := eb.WriteOffsetPlaceholders(length); err != nil {
+		return err
+	}
+	memOffset := uintptr(0)
+	for i := uint64(0); i < length; i++ {
+		elemPtr := unsafe.Pointer(uintptr(p) + memOffset)
+		memOffset += elemMemSize
+
+		if err := eb.PatchOffset(start+int(i*BYTES_PER_LENGTH_OFFSET), start); err != nil {
+			return err
+		}
+		if err := encFn(eb, elemPtr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func dryCheckVarSeriesFromOffsets(dryCheckFn DryCheckFn, offsets []uint64, dr *DecodingReader) error {
 	for i := 0; i < len(offsets); i++ {
 		currentOffset := dr.Index()
@@ -27,8 +27,21 @@ func DefaultSSZFactory(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
 	case reflect.Uint64:
 		return SSZUint64{}, nil
 	case reflect.Struct:
+		if IsStableContainer(typ) {
+			return NewSSZStableContainer(factory, typ)
+		}
+		if IsProfile(typ) {
+			return NewSSZProfile(factory, typ)
+		}
+		ptrTyp := reflect.PtrTo(typ)
+		if ptrTyp.Implements(unionMeta) {
+			return NewSSZUnion(factory, typ)
+		}
 		return NewSSZContainer(factory, typ)
 	case reflect.Array:
+		if IsUintN(typ) {
+			return GetUintNSSZ(typ)
+		}
 		switch typ.Elem().Kind() {
 		case reflect.Uint8:
 			ptrTyp := reflect.PtrTo(typ)
@@ -40,9 +53,18 @@ func DefaultSSZFactory(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
 		case reflect.Bool, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 			return NewSSZBasicVector(typ)
 		default:
+			if IsUintN(typ.Elem()) {
+				return NewSSZBasicVector(typ)
+			}
 			return NewSSZVector(factory, typ)
 		}
 	case reflect.Slice:
+		if IsProgressiveList(typ) {
+			if typ.Elem().Kind() == reflect.Uint8 && reflect.PtrTo(typ).Implements(progressiveBitlistMeta) {
+				return NewSSZProgressiveBitlist(typ)
+			}
+			return NewSSZProgressiveList(factory, typ)
+		}
 		switch typ.Elem().Kind() {
 		case reflect.Uint8:
 			ptrTyp := reflect.PtrTo(typ)
@@ -54,9 +76,15 @@ func DefaultSSZFactory(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
 		case reflect.Bool, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 			return NewSSZBasicList(typ)
 		default:
+			if IsUintN(typ.Elem()) {
+				return NewSSZBasicList(typ)
+			}
 			return NewSSZList(factory, typ)
 		}
-	// TODO: union, null, uint128, uint256, string
+	case reflect.String:
+		return NewSSZString(typ)
+	case reflect.Interface:
+		return NewSSZIfaceUnion(factory, typ)
 	default:
 		return nil, fmt.Errorf("ssz: type %s cannot be recognized", typ.String())
 	}
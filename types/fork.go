@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"github.com/protolambda/zssz/util/tags"
+	"reflect"
+)
+
+// Tag key for the first fork a field is active in, e.g. `ssz:"since=altair"`
+const SINCE_KEY = "since"
+
+// Tag key for the first fork a field is no longer active in, e.g. `ssz:"until=deneb"`
+const UNTIL_KEY = "until"
+
+// The names of the forks, in order of activation.
+type ForkSchedule []string
+
+// Get the position of the fork in the schedule
+func (s ForkSchedule) Index(fork string) (int, bool) {
+	for i, name := range s {
+		if name == fork {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// The active fork, to select the fields of fork-specific containers with.
+type ForkSelection struct {
+	Schedule ForkSchedule
+	// index of the active fork in the schedule
+	Index int
+}
+
+func NewForkSelection(schedule ForkSchedule, fork string) (*ForkSelection, error) {
+	index, ok := schedule.Index(fork)
+	if !ok {
+		return nil, fmt.Errorf("fork %s is not in the fork schedule", fork)
+	}
+	return &ForkSelection{Schedule: schedule, Index: index}, nil
+}
+
+// If the struct field is active in the selected fork, based on the since and until tag values.
+// Fields without these are always active. Errors for fork-specific fields if no fork is selected (nil selection).
+func (s *ForkSelection) IsActive(f *reflect.StructField) (bool, error) {
+	since, hasSince := tags.GetValue(f, SSZ_TAG, SINCE_KEY)
+	until, hasUntil := tags.GetValue(f, SSZ_TAG, UNTIL_KEY)
+	if !hasSince && !hasUntil {
+		return true, nil
+	}
+	if s == nil {
+		return false, fmt.Errorf("field %s is fork-specific, but no fork is selected", f.Name)
+	}
+	sinceIndex, untilIndex := 0, len(s.Schedule)
+	if hasSince {
+		i, ok := s.Schedule.Index(since)
+		if !ok {
+			return false, fmt.Errorf("field %s is active since unknown fork %s", f.Name, since)
+		}
+		sinceIndex = i
+	}
+	if hasUntil {
+		i, ok := s.Schedule.Index(until)
+		if !ok {
+			return false, fmt.Errorf("field %s is active until unknown fork %s", f.Name, until)
+		}
+		untilIndex = i
+	}
+	if untilIndex <= sinceIndex {
+		return false, fmt.Errorf("field %s is active until fork %s, which is not after fork %s", f.Name, until, since)
+	}
+	return sinceIndex <= s.Index && s.Index < untilIndex, nil
+}
+
+// If the struct field is tagged with a since or until fork
+func IsForkSpecific(f *reflect.StructField) bool {
+	_, hasSince := tags.GetValue(f, SSZ_TAG, SINCE_KEY)
+	_, hasUntil := tags.GetValue(f, SSZ_TAG, UNTIL_KEY)
+	return hasSince || hasUntil
+}
+
+// Factory function that builds containers with the fields of the selected fork,
+// and defers to the fallback for all other types, e.g. DefaultSSZFactory or MarshalerAwareFactoryFn.
+// The containers of structs with custom marshaling methods are wrapped in a SSZMarshaler, to use the methods.
+//
+// Example, to build the definitions of a fork:
+//
+//	fork, err := NewForkSelection(ForkSchedule{"phase0", "altair", "bellatrix"}, "altair")
+//	altairRegistry := NewRegistry(ForkFactoryFn(fork, DefaultSSZFactory))
+func ForkFactoryFn(fork *ForkSelection, fallback ComposableFactoryFn) ComposableFactoryFn {
+	return func(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+		if typ.Kind() == reflect.Struct && !IsStableContainer(typ) && !IsProfile(typ) &&
+			!reflect.PtrTo(typ).Implements(unionMeta) {
+			def, err := NewSSZForkContainer(factory, typ, fork)
+			if err != nil {
+				return nil, err
+			}
+			if HasCustomMarshaling(typ) {
+				return NewSSZMarshalerWithDefault(typ, def)
+			}
+			return def, nil
+		}
+		return fallback(factory, typ)
+	}
+}
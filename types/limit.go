@@ -3,11 +3,29 @@ package types
 import (
 	"fmt"
 	"github.com/protolambda/zssz/lists"
+	"github.com/protolambda/zssz/merkle"
 	"reflect"
 )
 
 var listType = reflect.TypeOf((*lists.List)(nil)).Elem()
 
+var progressiveListType = reflect.TypeOf((*lists.ProgressiveList)(nil)).Elem()
+
+// Progressive lists have no limit, but are bounded by the 4-byte offsets to this amount of bytes.
+const PROGRESSIVE_BYTES_LIMIT = uint64(1) << 32
+
+func IsProgressiveList(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && reflect.PtrTo(typ).Implements(progressiveListType)
+}
+
+// Panics with merkle.ErrOverLimit if the length is over the limit, for HashTreeRootChecked to return.
+// Merkleize only checks the number of chunks: a length over the limit may still fit in the chunks of the limit.
+func checkMerkleLimit(length uint64, limit uint64) {
+	if length > limit {
+		panic(merkle.ErrOverLimit)
+	}
+}
+
 func ReadListLimit(typ reflect.Type) (uint64, error) {
 	ptrTyp := reflect.PtrTo(typ)
 	if !ptrTyp.Implements(listType) {
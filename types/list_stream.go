@@ -0,0 +1,100 @@
+package types
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/enc"
+	"unsafe"
+)
+
+// List definitions, of which the elements can be encoded one at a time, see EncodeElemStream.
+// Lists with a limit implement LimitedListSSZ as well, progressive lists do not.
+type ListSSZ interface {
+	SSZ
+	// The definition of the elements
+	ElemSSZ() SSZ
+}
+
+// List definitions with a limit.
+type LimitedListSSZ interface {
+	ListSSZ
+	// The maximum number of elements
+	Limit() uint64
+}
+
+// Gets a pointer to element i. The element has to stay valid until the next call.
+type ElemPtrFn func(i uint64) (unsafe.Pointer, error)
+
+// Encodes a list of count elements, getting the elements one at a time, instead of from a slice in memory.
+// Variable-size elements are gotten twice, in order: first to write their offsets, then to encode them.
+// The count is checked against the limit of the list, if it has any.
+func EncodeElemStream(eb *EncodingWriter, list ListSSZ, count uint64, elemFn ElemPtrFn) error {
+	if limited, ok := list.(LimitedListSSZ); ok {
+		if limit := limited.Limit(); count > limit {
+			return fmt.Errorf("cannot encode list of %d elements, limit is %d", count, limit)
+		}
+	}
+	elemSSZ := list.ElemSSZ()
+	if elemSSZ.IsFixed() {
+		for i := uint64(0); i < count; i++ {
+			elemPtr, err := elemFn(i)
+			if err != nil {
+				return err
+			}
+			if err := elemSSZ.Encode(eb, elemPtr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	start := uint64(eb.Written())
+	// the sizes of the contents of one element at a time, to keep memory use bounded.
+	sizes := new(SizeCache)
+	// first, write all the offsets, starting after the fixed data.
+	// The elements are sized with RecordSize, to error on invalid elements, where SizeOf would panic.
+	prevOffset := BYTES_PER_LENGTH_OFFSET * count
+	prevSize := uint64(0)
+	for i := uint64(0); i < count; i++ {
+		elemPtr, err := elemFn(i)
+		if err != nil {
+			return err
+		}
+		if prevOffset, err = eb.WriteOffset(prevOffset, prevSize); err != nil {
+			return err
+		}
+		sizes.Reset()
+		if prevSize, err = RecordSize(sizes, elemSSZ, elemPtr); err != nil {
+			return err
+		}
+	}
+	// the end of the last element, relative to the start of the list
+	end := prevOffset + prevSize
+
+	// then write the elements, with the sizes of their contents recorded again, one element at a time.
+	recorded := recordsSizes(elemSSZ)
+	if recorded {
+		prevSizes := eb.Sizes
+		defer func() {
+			eb.Sizes = prevSizes
+		}()
+	}
+	for i := uint64(0); i < count; i++ {
+		elemPtr, err := elemFn(i)
+		if err != nil {
+			return err
+		}
+		if recorded {
+			sizes.Reset()
+			if _, err := elemSSZ.(SizeRecorder).RecordSize(sizes, elemPtr); err != nil {
+				return err
+			}
+			eb.Sizes = sizes
+		}
+		if err := elemSSZ.Encode(eb, elemPtr); err != nil {
+			return err
+		}
+	}
+	if written := uint64(eb.Written()) - start; written != end {
+		return fmt.Errorf("encoded %d bytes, but elements were sized %d bytes, elements changed while encoding", written, end)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/protolambda/zssz/merkle"
+	"sort"
+)
+
+// What a schema change affects. A zero impact is a change without effect on the wire format or the roots.
+type ChangeImpact byte
+
+const (
+	// The encoding, or the set of valid encodings, changes
+	ImpactEncoding ChangeImpact = 1 << iota
+	// The hash-tree-root changes
+	ImpactMerkleization
+	ImpactBoth = ImpactEncoding | ImpactMerkleization
+)
+
+func (c ChangeImpact) String() string {
+	switch c {
+	case 0:
+		return "none"
+	case ImpactEncoding:
+		return "encoding"
+	case ImpactMerkleization:
+		return "merkleization"
+	case ImpactBoth:
+		return "encoding and merkleization"
+	default:
+		return fmt.Sprintf("unknown impact %d", byte(c))
+	}
+}
+
+func (m ChangeMode) String() string {
+	switch m {
+	case Equal:
+		return "equal"
+	case Modified:
+		return "modified"
+	case Added:
+		return "added"
+	case Deleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("unknown mode %d", byte(m))
+	}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s (%s), affects %s", c.Path, c.Description, c.Mode, c.Impact)
+}
+
+// The schema without Go type names, these do not affect the SSZ definition.
+func (s Schema) normalized() Schema {
+	s.GoType = ""
+	if s.Elem != nil {
+		e := s.Elem.normalized()
+		s.Elem = &e
+	}
+	if s.Fields != nil {
+		fields := make([]FieldSchema, len(s.Fields))
+		for i, f := range s.Fields {
+			f.Type = f.Type.normalized()
+			fields[i] = f
+		}
+		s.Fields = fields
+	}
+	if s.Options != nil {
+		options := make([]*Schema, len(s.Options))
+		for i, opt := range s.Options {
+			if opt != nil {
+				o := opt.normalized()
+				options[i] = &o
+			}
+		}
+		s.Options = options
+	}
+	return s
+}
+
+// Deterministic fingerprint of the schema: the SHA-256 hash of its JSON representation, ignoring Go type names.
+func (s Schema) Fingerprint() [32]byte {
+	data, err := json.Marshal(s.normalized())
+	if err != nil {
+		panic(err)
+	}
+	return sha256.Sum256(data)
+}
+
+// Deterministic fingerprint of the SSZ definition, see Schema.Fingerprint
+func Fingerprint(ssz SSZ) [32]byte {
+	return Describe(ssz).Fingerprint()
+}
+
+type LockedType struct {
+	// hex-encoded fingerprint of the schema
+	Fingerprint string `json:"fingerprint"`
+	Schema      Schema `json:"schema"`
+}
+
+// The schemas of named types, to commit and check definitions against, serializable to JSON.
+type Lockfile struct {
+	Types map[string]LockedType `json:"types"`
+}
+
+// Create a lockfile of the named types in the registry
+func (r *Registry) Lockfile() (*Lockfile, error) {
+	lock := &Lockfile{Types: make(map[string]LockedType)}
+	for _, name := range r.Names() {
+		ssz, err := r.GetByName(name)
+		if err != nil {
+			return nil, err
+		}
+		schema := Describe(ssz).normalized()
+		fp := schema.Fingerprint()
+		lock.Types[name] = LockedType{Fingerprint: hex.EncodeToString(fp[:]), Schema: schema}
+	}
+	return lock, nil
+}
+
+// Check the named types in the registry against the lockfile, and list the changes.
+// Types that are new in the registry, or missing from it, are listed as added or deleted.
+func (r *Registry) CheckLockfile(lock *Lockfile) ([]Change, error) {
+	current, err := r.Lockfile()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, name := range r.Names() {
+		cur := current.Types[name]
+		prev, ok := lock.Types[name]
+		if !ok {
+			changes = append(changes, Change{Path: name, Mode: Added, Description: "type added", Impact: ImpactBoth})
+			continue
+		}
+		if prev.Fingerprint == cur.Fingerprint {
+			continue
+		}
+		changes = append(changes, DiffSchemas(name, prev.Schema, cur.Schema)...)
+	}
+	var deleted []string
+	for name := range lock.Types {
+		if _, ok := current.Types[name]; !ok {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		changes = append(changes, Change{Path: name, Mode: Deleted, Description: "type deleted", Impact: ImpactBoth})
+	}
+	return changes, nil
+}
+
+// List the changes from the previous to the current schema, with paths relative to the given path.
+// Changes of the fixed size are listed at the given path as well, as these shift the contents of any parent.
+func DiffSchemas(path string, prev Schema, cur Schema) []Change {
+	d := schemaDiff{}
+	d.diff(path, &prev, &cur)
+	if prev.IsFixed != cur.IsFixed {
+		d.add(path, Modified, ImpactBoth, "changed from %s to %s", sizeDesc(prev.IsFixed), sizeDesc(cur.IsFixed))
+	} else if prev.IsFixed && prev.FixedLen != cur.FixedLen {
+		d.add(path, Modified, ImpactBoth, "fixed size changed from %d to %d bytes", prev.FixedLen, cur.FixedLen)
+	}
+	return d.changes
+}
+
+func sizeDesc(isFixed bool) string {
+	if isFixed {
+		return "fixed-size"
+	}
+	return "variable-size"
+}
+
+type schemaDiff struct {
+	changes []Change
+}
+
+func (d *schemaDiff) add(path string, mode ChangeMode, impact ChangeImpact, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{Path: path, Mode: mode, Description: fmt.Sprintf(format, args...), Impact: impact})
+}
+
+// The number of chunks a list of the given limit is merkleized to
+func chunkLimit(s *Schema) uint64 {
+	switch s.Kind {
+	case KindBitlist:
+		return (s.Limit + 255) >> 8
+	case KindString:
+		return (s.Limit + 31) >> 5
+	}
+	if s.Elem != nil {
+		switch s.Elem.Kind {
+		case KindBoolean, KindUint8, KindUint16, KindUint32, KindUint64, KindUint128, KindUint256:
+			return (s.Limit*s.Elem.FixedLen + 31) >> 5
+		}
+	}
+	return s.Limit
+}
+
+func (d *schemaDiff) diff(path string, prev *Schema, cur *Schema) {
+	if prev.Kind != cur.Kind {
+		d.add(path, Modified, ImpactBoth, "kind changed from %s to %s", prev.Kind, cur.Kind)
+		return
+	}
+	if prev.Length != cur.Length {
+		d.add(path, Modified, ImpactBoth, "length changed from %d to %d", prev.Length, cur.Length)
+	}
+	if prev.Limit != cur.Limit {
+		impact := ImpactEncoding
+		if merkle.GetDepth(chunkLimit(prev)) != merkle.GetDepth(chunkLimit(cur)) {
+			impact = ImpactBoth
+		}
+		d.add(path, Modified, impact, "limit changed from %d to %d", prev.Limit, cur.Limit)
+	}
+	if prev.Capacity != cur.Capacity {
+		d.add(path, Modified, ImpactBoth, "capacity changed from %d to %d", prev.Capacity, cur.Capacity)
+	}
+	if prev.Elem != nil && cur.Elem != nil {
+		d.diff(path+".elem", prev.Elem, cur.Elem)
+	}
+	switch prev.Kind {
+	case KindContainer, KindStableContainer, KindProfile:
+		d.diffFields(path, prev, cur)
+	case KindUnion:
+		d.diffOptions(path, prev.Options, cur.Options)
+	case KindCustom:
+		if prev.IsFixed != cur.IsFixed || prev.FixedLen != cur.FixedLen || prev.MinLen != cur.MinLen || prev.MaxLen != cur.MaxLen {
+			d.add(path, Modified, ImpactBoth, "custom type changed")
+		}
+	}
+}
+
+// Fields are matched by name. Moved fields are reordered, which affects the encoding,
+// and for containers also the merkleization: stable containers and profiles merkleize by field index instead.
+// A field of a container is renamed if its name is gone, and a new name of the identical type takes its position.
+func (d *schemaDiff) diffFields(path string, prev *Schema, cur *Schema) {
+	prevByName := make(map[string]*FieldSchema, len(prev.Fields))
+	for i := range prev.Fields {
+		prevByName[prev.Fields[i].Name] = &prev.Fields[i]
+	}
+	curByName := make(map[string]*FieldSchema, len(cur.Fields))
+	for i := range cur.Fields {
+		curByName[cur.Fields[i].Name] = &cur.Fields[i]
+	}
+	// the previous fields of renamed fields, by current name
+	renamed := make(map[string]*FieldSchema)
+	if prev.Kind == KindContainer {
+		for i := range prev.Fields {
+			if i >= len(cur.Fields) {
+				break
+			}
+			prevF, f := &prev.Fields[i], &cur.Fields[i]
+			if curByName[prevF.Name] == nil && prevByName[f.Name] == nil && prevF.Type.Fingerprint() == f.Type.Fingerprint() {
+				renamed[f.Name] = prevF
+			}
+		}
+	}
+	// the current names of the common fields, in the previous order
+	var prevOrder, curOrder []string
+	for i := range prev.Fields {
+		f := &prev.Fields[i]
+		if curByName[f.Name] != nil {
+			prevOrder = append(prevOrder, f.Name)
+			continue
+		}
+		if i < len(cur.Fields) && renamed[cur.Fields[i].Name] == f {
+			prevOrder = append(prevOrder, cur.Fields[i].Name)
+			continue
+		}
+		d.add(path+"."+f.Name, Deleted, ImpactBoth, "field deleted")
+	}
+	for i := range cur.Fields {
+		f := &cur.Fields[i]
+		fieldPath := path + "." + f.Name
+		prevF := prevByName[f.Name]
+		if prevF == nil {
+			if prevF = renamed[f.Name]; prevF == nil {
+				d.add(fieldPath, Added, ImpactBoth, "field added")
+				continue
+			}
+			d.add(fieldPath, Modified, 0, "field renamed from %s to %s", prevF.Name, f.Name)
+		}
+		curOrder = append(curOrder, f.Name)
+		if prevF.Index != f.Index {
+			// the active fields of stable containers, and their order in the encoding, follow the index
+			impact := ImpactMerkleization
+			if prev.Kind == KindStableContainer {
+				impact = ImpactBoth
+			}
+			d.add(fieldPath, Modified, impact, "index changed from %d to %d", prevF.Index, f.Index)
+		}
+		if prevF.Optional != f.Optional {
+			d.add(fieldPath, Modified, ImpactEncoding, "changed from %s to %s", optionalDesc(prevF.Optional), optionalDesc(f.Optional))
+		}
+		d.diff(fieldPath, &prevF.Type, &f.Type)
+	}
+	for i := range prevOrder {
+		if prevOrder[i] != curOrder[i] {
+			impact := ImpactEncoding
+			if prev.Kind == KindContainer {
+				impact = ImpactBoth
+			}
+			d.add(path, Modified, impact, "fields reordered, field %s moved", prevOrder[i])
+			break
+		}
+	}
+}
+
+func optionalDesc(optional bool) string {
+	if optional {
+		return "optional"
+	}
+	return "required"
+}
+
+func (d *schemaDiff) diffOptions(path string, prev []*Schema, cur []*Schema) {
+	for i := 0; i < len(prev) || i < len(cur); i++ {
+		optPath := fmt.Sprintf("%s.option[%d]", path, i)
+		switch {
+		case i >= len(cur):
+			d.add(optPath, Deleted, ImpactBoth, "union option deleted")
+		case i >= len(prev):
+			d.add(optPath, Added, ImpactEncoding, "union option added")
+		case prev[i] == nil && cur[i] != nil:
+			d.add(optPath, Modified, ImpactBoth, "union option changed from None to %s", cur[i].Kind)
+		case prev[i] != nil && cur[i] == nil:
+			d.add(optPath, Modified, ImpactBoth, "union option changed from %s to None", prev[i].Kind)
+		case prev[i] != nil && cur[i] != nil:
+			d.diff(optPath, prev[i], cur[i])
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	. "github.com/protolambda/zssz/enc"
+	"sync"
+	"unsafe"
+)
+
+// If the series should be encoded in parallel, see EncodingWriter.Parallel.
+func encodesParallel(eb *EncodingWriter, length uint64) bool {
+	return eb.Parallel != nil && length >= eb.Parallel.Threshold && length > 1 && eb.Parallel.WorkerCount() > 1
+}
+
+// A range of elements, encoded by a goroutine
+type seriesPart struct {
+	buf []byte
+	// sizes of the elements, only for var-size elements
+	sizes []uint64
+	err   error
+}
+
+// Encodes ranges of the series with a goroutine each, into separate buffers, and writes them in order.
+// For var-size elements the offsets are written first, from the sizes of the encoded elements.
+// pointer must point to start of the series contents
+func encodeParallelSeries(elemSSZ SSZ, length uint64, elemMemSize uintptr, eb *EncodingWriter, p unsafe.Pointer) error {
+	workers := uint64(eb.Parallel.WorkerCount())
+	if workers > length {
+		workers = length
+	}
+	isVar := !elemSSZ.IsFixed()
+
+	// The recorded sizes of the elements are followed by the sizes of the contents of each element.
+	// Each goroutine takes the sizes of the contents of its elements from a view of the cache.
+	sizes := eb.Sizes
+	first := 0
+	if sizes != nil && isVar && recordsSizes(elemSSZ) {
+		first = sizes.Next()
+		for i := uint64(0); i < length; i++ {
+			if _, ok := sizes.Take(unsafe.Pointer(uintptr(p)+uintptr(i)*elemMemSize), elemSSZ); !ok {
+				// not recorded, the elements are sized while encoding instead
+				sizes = nil
+				break
+			}
+		}
+	} else {
+		sizes = nil
+	}
+	contentsStart := func(i uint64) int {
+		if i == 0 {
+			return first + int(length)
+		}
+		return sizes.End(first + int(i) - 1)
+	}
+
+	parts := make([]seriesPart, workers)
+	var wg sync.WaitGroup
+	for w := uint64(0); w < workers; w++ {
+		from, to := length*w/workers, length*(w+1)/workers
+		pw := NewSliceEncodingWriter(nil)
+		if eb.Patching() {
+			// slice writers can always patch
+			_ = pw.EnablePatching()
+		}
+		if sizes != nil {
+			pw.Sizes = sizes.View(contentsStart(from))
+		}
+		wg.Add(1)
+		go func(part *seriesPart, pw *EncodingWriter, from uint64, to uint64) {
+			defer wg.Done()
+			if isVar {
+				part.sizes = make([]uint64, 0, to-from)
+			}
+			for i := from; i < to; i++ {
+				elemPtr := unsafe.Pointer(uintptr(p) + uintptr(i)*elemMemSize)
+				start := pw.Written()
+				if err := elemSSZ.Encode(pw, elemPtr); err != nil {
+					part.err = err
+					return
+				}
+				if isVar {
+					part.sizes = append(part.sizes, uint64(pw.Written()-start))
+				}
+			}
+			part.buf = pw.Bytes()
+		}(&parts[w], pw, from, to)
+	}
+	wg.Wait()
+	for i := range parts {
+		if err := parts[i].err; err != nil {
+			return err
+		}
+	}
+	if sizes != nil {
+		// skip the sizes of the contents of the elements
+		sizes.Seek(sizes.End(first + int(length) - 1))
+	}
+	if isVar {
+		// the previous offset, to calculate a new offset from, starting after the fixed data.
+		prevOffset := BYTES_PER_LENGTH_OFFSET * length
+		// span of the previous var-size element
+		prevSize := uint64(0)
+		for i := range parts {
+			for _, size := range parts[i].sizes {
+				if offset, err := eb.WriteOffset(prevOffset, prevSize); err != nil {
+					return err
+				} else {
+					prevOffset = offset
+				}
+				prevSize = size
+			}
+		}
+	}
+	for i := range parts {
+		if err := eb.Write(parts[i].buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
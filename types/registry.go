@@ -0,0 +1,122 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// A factory function that can be composed with other factories, like DefaultSSZFactory.
+// The given factory is used for child types.
+type ComposableFactoryFn func(factory SSZFactoryFn, typ reflect.Type) (SSZ, error)
+
+// A concurrency-safe registry of SSZ definitions, memoized per type. Types can be registered by name.
+// Each registry builds definitions with its own factory function, for child types the registry is used.
+type Registry struct {
+	factoryFn ComposableFactoryFn
+	// guards building, reading is guarded by cacheLock
+	buildLock sync.Mutex
+	cacheLock sync.RWMutex
+	cache     map[reflect.Type]SSZ
+	// types currently being built, to detect self-referential types
+	building map[reflect.Type]struct{}
+	names    map[string]reflect.Type
+}
+
+// The registry used by GetSSZ, with DefaultSSZFactory
+var DefaultRegistry = NewRegistry(DefaultSSZFactory)
+
+func NewRegistry(factoryFn ComposableFactoryFn) *Registry {
+	return &Registry{
+		factoryFn: factoryFn,
+		cache:     make(map[reflect.Type]SSZ),
+		building:  make(map[reflect.Type]struct{}),
+		names:     make(map[string]reflect.Type),
+	}
+}
+
+func (r *Registry) cached(typ reflect.Type) (SSZ, bool) {
+	r.cacheLock.RLock()
+	defer r.cacheLock.RUnlock()
+	res, ok := r.cache[typ]
+	return res, ok
+}
+
+// Get the SSZ definition of the type, building and caching it if it is not cached yet.
+// Errors for types that cannot be built, including self-referential types.
+func (r *Registry) Get(typ reflect.Type) (SSZ, error) {
+	if res, ok := r.cached(typ); ok {
+		return res, nil
+	}
+	r.buildLock.Lock()
+	defer r.buildLock.Unlock()
+	return r.get(typ)
+}
+
+// The factory function of the registry, used to build child types with. Only to be used while building.
+func (r *Registry) get(typ reflect.Type) (SSZ, error) {
+	if res, ok := r.cached(typ); ok {
+		return res, nil
+	}
+	if _, ok := r.building[typ]; ok {
+		return nil, fmt.Errorf("type %s is self-referential", typ.String())
+	}
+	r.building[typ] = struct{}{}
+	res, err := r.factoryFn(r.get, typ)
+	delete(r.building, typ)
+	if err != nil {
+		return nil, err
+	}
+	r.cacheLock.Lock()
+	r.cache[typ] = res
+	r.cacheLock.Unlock()
+	return res, nil
+}
+
+// Register the type under the given name, and build its SSZ definition.
+// Errors if the name is registered for a different type already, or if the definition cannot be built.
+func (r *Registry) Register(name string, typ reflect.Type) (SSZ, error) {
+	r.buildLock.Lock()
+	defer r.buildLock.Unlock()
+	if prev, ok := r.names[name]; ok && prev != typ {
+		return nil, fmt.Errorf("name %s is registered for type %s already", name, prev.String())
+	}
+	res, err := r.get(typ)
+	if err != nil {
+		return nil, err
+	}
+	r.cacheLock.Lock()
+	r.names[name] = typ
+	r.cacheLock.Unlock()
+	return res, nil
+}
+
+// Get the type registered under the given name
+func (r *Registry) TypeByName(name string) (typ reflect.Type, ok bool) {
+	r.cacheLock.RLock()
+	defer r.cacheLock.RUnlock()
+	typ, ok = r.names[name]
+	return
+}
+
+// Get the SSZ definition of the type registered under the given name
+func (r *Registry) GetByName(name string) (SSZ, error) {
+	typ, ok := r.TypeByName(name)
+	if !ok {
+		return nil, fmt.Errorf("no type registered with name %s", name)
+	}
+	return r.Get(typ)
+}
+
+// The registered names, sorted
+func (r *Registry) Names() []string {
+	r.cacheLock.RLock()
+	defer r.cacheLock.RUnlock()
+	out := make([]string, 0, len(r.names))
+	for name := range r.names {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,179 @@
+package types
+
+import "fmt"
+
+// Kinds of schemas
+const (
+	KindBoolean            = "boolean"
+	KindUint8              = "uint8"
+	KindUint16             = "uint16"
+	KindUint32             = "uint32"
+	KindUint64             = "uint64"
+	KindUint128            = "uint128"
+	KindUint256            = "uint256"
+	KindVector             = "vector"
+	KindList               = "list"
+	KindBitvector          = "bitvector"
+	KindBitlist            = "bitlist"
+	KindContainer          = "container"
+	KindUnion              = "union"
+	KindProgressiveList    = "progressive_list"
+	KindProgressiveBitlist = "progressive_bitlist"
+	KindStableContainer    = "stable_container"
+	KindProfile            = "profile"
+	// UTF-8 string, encoded like a list of bytes
+	KindString = "string"
+	// UTF-8 string, zero-padded to a vector of bytes
+	KindStringN = "string_n"
+	// custom SSZ definition, that does not implement SchemaDescriber
+	KindCustom = "custom"
+)
+
+// A machine-readable description of a SSZ type, serializable to JSON.
+// Only the properties relevant to the kind are set.
+type Schema struct {
+	Kind string `json:"kind"`
+	// Go type of custom SSZ definitions, or of the value of union options
+	GoType string `json:"go_type,omitempty"`
+	// number of elements of vectors, bits of bitvectors, bytes of fixed-length strings
+	Length uint64 `json:"length,omitempty"`
+	// maximum number of elements of lists, bits of bitlists, bytes of strings
+	Limit uint64 `json:"limit,omitempty"`
+	// number of fields of stable containers and profiles, for merkleization
+	Capacity uint64 `json:"capacity,omitempty"`
+	// element type of vectors and lists
+	Elem *Schema `json:"elem,omitempty"`
+	// fields of containers, stable containers and profiles
+	Fields []FieldSchema `json:"fields,omitempty"`
+	// union options, indexed by selector. Nil for the None option.
+	Options []*Schema `json:"options,omitempty"`
+	IsFixed bool      `json:"is_fixed"`
+	// size of the fixed part, i.e. the size of fixed-size types.
+	FixedLen uint64 `json:"fixed_len"`
+	MinLen   uint64 `json:"min_len"`
+	MaxLen   uint64 `json:"max_len"`
+}
+
+type FieldSchema struct {
+	Name string `json:"name"`
+	// index of the field in the stable container, only for stable containers and profiles
+	Index uint64 `json:"index,omitempty"`
+	// if the field may be absent, only for stable containers and profiles
+	Optional bool   `json:"optional,omitempty"`
+	Type     Schema `json:"type"`
+}
+
+// Custom SSZ definitions can describe their schema, children can be described with the given function.
+type SchemaDescriber interface {
+	Schema(describe func(SSZ) Schema) Schema
+}
+
+func uintKind(byteLen uint64) string {
+	return fmt.Sprintf("uint%d", byteLen*8)
+}
+
+func describeStableFields(fields []StableField) []FieldSchema {
+	out := make([]FieldSchema, len(fields))
+	for i := range fields {
+		f := &fields[i]
+		out[i] = FieldSchema{Name: f.name, Index: f.index, Optional: f.prefixBit >= 0, Type: Describe(f.ssz)}
+	}
+	return out
+}
+
+func describeUnionOptions(v *unionOptions) []*Schema {
+	out := make([]*Schema, len(v.options))
+	for i := range v.options {
+		opt := &v.options[i]
+		if opt.ssz == nil {
+			continue
+		}
+		s := Describe(opt.ssz)
+		s.GoType = opt.typ.String()
+		out[i] = &s
+	}
+	return out
+}
+
+// Describe the SSZ definition. Pointers and custom marshaling are transparent, the described type is the same.
+// Custom SSZ definitions that do not implement SchemaDescriber are described as KindCustom.
+func Describe(ssz SSZ) Schema {
+	s := Schema{
+		IsFixed:  ssz.IsFixed(),
+		FixedLen: ssz.FixedLen(),
+		MinLen:   ssz.MinLen(),
+		MaxLen:   ssz.MaxLen(),
+	}
+	elem := func(elemSSZ SSZ) *Schema {
+		e := Describe(elemSSZ)
+		return &e
+	}
+	switch v := ssz.(type) {
+	case SchemaDescriber:
+		return v.Schema(Describe)
+	case *SSZPtr:
+		return Describe(v.elemSSZ)
+	case *SSZMarshaler:
+		return Describe(v.SSZ)
+	case *SSZFixedSlice:
+		return Describe(v.vecSSZ)
+	case SSZBool:
+		s.Kind = KindBoolean
+	case SSZUint8:
+		s.Kind = KindUint8
+	case SSZUint16:
+		s.Kind = KindUint16
+	case SSZUint32:
+		s.Kind = KindUint32
+	case SSZUint64:
+		s.Kind = KindUint64
+	case SSZUint128:
+		s.Kind = KindUint128
+	case SSZUint256:
+		s.Kind = KindUint256
+	case *SSZBigUint:
+		s.Kind = uintKind(v.byteLen)
+	case *SSZBytesN:
+		s.Kind, s.Length, s.Elem = KindVector, v.length, elem(SSZUint8{})
+	case *SSZBasicVector:
+		s.Kind, s.Length, s.Elem = KindVector, v.length, elem(v.elemSSZ)
+	case *SSZVector:
+		s.Kind, s.Length, s.Elem = KindVector, v.length, elem(v.elemSSZ)
+	case *SSZBytes:
+		s.Kind, s.Limit, s.Elem = KindList, v.limit, elem(SSZUint8{})
+	case *SSZBasicList:
+		s.Kind, s.Limit, s.Elem = KindList, v.limit, elem(v.elemSSZ)
+	case *SSZList:
+		s.Kind, s.Limit, s.Elem = KindList, v.limit, elem(v.elemSSZ)
+	case *SSZBitvector:
+		s.Kind, s.Length = KindBitvector, v.bitLen
+	case *SSZBitlist:
+		s.Kind, s.Limit = KindBitlist, v.bitLimit
+	case *SSZProgressiveList:
+		s.Kind, s.Elem = KindProgressiveList, elem(v.elemSSZ)
+	case *SSZProgressiveBitlist:
+		s.Kind = KindProgressiveBitlist
+	case *SSZString:
+		s.Kind, s.Limit = KindString, v.limit
+	case *SSZStringN:
+		s.Kind, s.Length = KindStringN, v.length
+	case *SSZContainer:
+		s.Kind = KindContainer
+		s.Fields = make([]FieldSchema, len(v.Fields))
+		for i := range v.Fields {
+			f := &v.Fields[i]
+			s.Fields[i] = FieldSchema{Name: f.pureName, Type: Describe(f.ssz)}
+		}
+	case *SSZStableContainer:
+		s.Kind, s.Capacity, s.Fields = KindStableContainer, v.capacity, describeStableFields(v.Fields)
+	case *SSZProfile:
+		s.Kind, s.Capacity, s.Fields = KindProfile, v.capacity, describeStableFields(v.Fields)
+	case *SSZUnion:
+		s.Kind, s.Options = KindUnion, describeUnionOptions(&v.unionOptions)
+	case *SSZIfaceUnion:
+		s.Kind, s.Options = KindUnion, describeUnionOptions(&v.unionOptions)
+	default:
+		s.Kind, s.GoType = KindCustom, fmt.Sprintf("%T", ssz)
+	}
+	return s
+}
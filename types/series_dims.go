@@ -0,0 +1,167 @@
+package types
+
+import (
+	"fmt"
+	"github.com/protolambda/zssz/util/tags"
+	"reflect"
+)
+
+// Struct field tag with the limits of each (nested) list, outer dimension first. E.g. `ssz-max:"1024,32"`
+const SSZ_MAX_TAG = "ssz-max"
+
+// Struct field tag with the lengths of each (nested) vector, outer dimension first. E.g. `ssz-size:"?,48"`
+const SSZ_SIZE_TAG = "ssz-size"
+
+// Flag to make the inner-most []byte dimension a bitlist, with the ssz-max limit in bits.
+const BITLIST_FLAG = "bitlist"
+
+// The limits and sizes of the dimensions of a (nested) series type, declared with struct field tags.
+type SeriesDims struct {
+	Max     []tags.Dimension
+	Size    []tags.Dimension
+	Bitlist bool
+}
+
+// Read the series dimensions of a struct field. Returns nil if the field has no dimension tags.
+func ReadSeriesDims(f *reflect.StructField) (*SeriesDims, error) {
+	max, err := tags.GetDimensions(f, SSZ_MAX_TAG)
+	if err != nil {
+		return nil, err
+	}
+	size, err := tags.GetDimensions(f, SSZ_SIZE_TAG)
+	if err != nil {
+		return nil, err
+	}
+	bitlist := tags.HasFlag(f, SSZ_TAG, BITLIST_FLAG)
+	if max == nil && size == nil && !bitlist {
+		return nil, nil
+	}
+	return &SeriesDims{Max: max, Size: size, Bitlist: bitlist}, nil
+}
+
+func (d *SeriesDims) maxAt(depth int) tags.Dimension {
+	if depth < len(d.Max) {
+		return d.Max[depth]
+	}
+	return tags.Dimension{}
+}
+
+func (d *SeriesDims) sizeAt(depth int) tags.Dimension {
+	if depth < len(d.Size) {
+		return d.Size[depth]
+	}
+	return tags.Dimension{}
+}
+
+// If any limit or size is declared at the given depth or deeper.
+func (d *SeriesDims) definedFrom(depth int) bool {
+	for i := depth; i < len(d.Max); i++ {
+		if d.Max[i].Defined {
+			return true
+		}
+	}
+	for i := depth; i < len(d.Size); i++ {
+		if d.Size[i].Defined {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the SSZ definition of a struct field with the given type,
+// taking limits and sizes declared in the struct field tags into account.
+func GetFieldSSZ(factory SSZFactoryFn, f *reflect.StructField, typ reflect.Type) (SSZ, error) {
+	dims, err := ReadSeriesDims(f)
+	if err != nil {
+		return nil, err
+	}
+	if dims == nil {
+		return factory(typ)
+	}
+	res, err := NewSSZWithDims(factory, typ, dims, 0)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %v", f.Name, err)
+	}
+	return res, nil
+}
+
+// Get the SSZ definition of the type at the given depth of a (nested) series type.
+// Types without dimensions declared at this depth or deeper are left to the factory.
+func NewSSZWithDims(factory SSZFactoryFn, typ reflect.Type, dims *SeriesDims, depth int) (SSZ, error) {
+	if !dims.definedFrom(depth) && !dims.Bitlist {
+		return factory(typ)
+	}
+	// the element type is the next dimension, other types are left to the factory.
+	elemFactory := func(elemTyp reflect.Type) (SSZ, error) {
+		if typ.Kind() != reflect.Ptr && elemTyp == typ.Elem() {
+			return NewSSZWithDims(factory, elemTyp, dims, depth+1)
+		}
+		return factory(elemTyp)
+	}
+	max, size := dims.maxAt(depth), dims.sizeAt(depth)
+	switch typ.Kind() {
+	case reflect.Ptr:
+		// pointers do not count as a dimension
+		return NewSSZPtr(func(elemTyp reflect.Type) (SSZ, error) {
+			if elemTyp == typ.Elem() {
+				return NewSSZWithDims(factory, elemTyp, dims, depth)
+			}
+			return factory(elemTyp)
+		}, typ)
+	case reflect.Array:
+		if max.Defined {
+			return nil, fmt.Errorf("dimension %d is an array, it cannot have a limit", depth)
+		}
+		if size.Defined && size.Value != uint64(typ.Len()) {
+			return nil, fmt.Errorf("dimension %d is an array of length %d, but size %d was declared", depth, typ.Len(), size.Value)
+		}
+		return DefaultSSZFactory(elemFactory, typ)
+	case reflect.Slice:
+		elemKind := typ.Elem().Kind()
+		innerMost := !dims.definedFrom(depth + 1)
+		if dims.Bitlist && innerMost && elemKind == reflect.Uint8 {
+			if size.Defined || !max.Defined {
+				return nil, fmt.Errorf("dimension %d is a bitlist, it must have a limit and no size", depth)
+			}
+			return NewSSZBitlistWithLimit(typ, max.Value)
+		}
+		if size.Defined {
+			if max.Defined && max.Value != size.Value {
+				return nil, fmt.Errorf("dimension %d has size %d, but a different limit %d", depth, size.Value, max.Value)
+			}
+			return NewSSZFixedSlice(elemFactory, typ, size.Value)
+		}
+		if !max.Defined {
+			// no limit in the tags, try the Limit() method of the type itself
+			return DefaultSSZFactory(elemFactory, typ)
+		}
+		isBasicElem := elemKind == reflect.Uint8 || elemKind == reflect.Bool || elemKind == reflect.Uint16 ||
+			elemKind == reflect.Uint32 || elemKind == reflect.Uint64 || IsUintN(typ.Elem())
+		if isBasicElem {
+			if !innerMost {
+				return nil, fmt.Errorf("dimension %d has basic elements, but deeper dimensions were declared", depth)
+			}
+			if elemKind == reflect.Uint8 {
+				return NewSSZBytesWithLimit(typ, max.Value)
+			}
+			return NewSSZBasicListWithLimit(typ, max.Value)
+		}
+		return NewSSZListWithLimit(elemFactory, typ, max.Value)
+	case reflect.String:
+		if dims.definedFrom(depth + 1) {
+			return nil, fmt.Errorf("dimension %d is a string, but deeper dimensions were declared", depth)
+		}
+		if size.Defined {
+			if max.Defined && max.Value != size.Value {
+				return nil, fmt.Errorf("dimension %d has size %d, but a different limit %d", depth, size.Value, max.Value)
+			}
+			return NewSSZStringN(typ, size.Value)
+		}
+		if !max.Defined {
+			return NewSSZString(typ)
+		}
+		return NewSSZStringWithLimit(typ, max.Value)
+	default:
+		return nil, fmt.Errorf("dimension %d has type %s, but limits or sizes can only be declared for slices, arrays and strings", depth, typ.String())
+	}
+}
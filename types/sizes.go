@@ -0,0 +1,91 @@
+package types
+
+import (
+	. "github.com/protolambda/zssz/enc"
+	"unsafe"
+)
+
+// SSZ definitions of values with variable-size contents can record the sizes of the contents while sizing the value.
+// Encoding then uses the recorded sizes to write offsets with, instead of sizing the contents at every level of nesting.
+type SizeRecorder interface {
+	// Size the value, and record the sizes of its variable-size contents, in the order Encode asks for them.
+	RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error)
+}
+
+// If the sizes of values of the definition are recorded.
+// Other values, like byte lists, are cheap to size again, and are not worth recording.
+func recordsSizes(ssz SSZ) bool {
+	if ssz.IsFixed() {
+		return false
+	}
+	_, ok := ssz.(SizeRecorder)
+	return ok
+}
+
+// Size the value, and record the sizes of its contents, if the definition is a SizeRecorder.
+func RecordSize(c *SizeCache, ssz SSZ, p unsafe.Pointer) (uint64, error) {
+	if ssz.IsFixed() {
+		return ssz.FixedLen(), nil
+	}
+	if r, ok := ssz.(SizeRecorder); ok {
+		return r.RecordSize(c, p)
+	}
+	return ssz.SizeOf(p), nil
+}
+
+// The size of a variable-size value to write an offset for: as recorded in the size cache of the writer,
+// or sized if not recorded.
+func EncodedSizeOf(eb *EncodingWriter, ssz SSZ, p unsafe.Pointer) uint64 {
+	if eb.Sizes != nil && recordsSizes(ssz) {
+		if size, ok := eb.Sizes.Take(p, ssz); ok {
+			return size
+		}
+	}
+	return ssz.SizeOf(p)
+}
+
+// pointer must point to start of the series contents
+func recordVarSeriesSizes(c *SizeCache, elemSSZ SSZ, length uint64, elemMemSize uintptr, p unsafe.Pointer) (uint64, error) {
+	out := BYTES_PER_LENGTH_OFFSET * length
+	memOffset := uintptr(0)
+	if !recordsSizes(elemSSZ) {
+		for i := uint64(0); i < length; i++ {
+			out += elemSSZ.SizeOf(unsafe.Pointer(uintptr(p) + memOffset))
+			memOffset += elemMemSize
+		}
+		return out, nil
+	}
+	start := c.Reserve(int(length))
+	for i := uint64(0); i < length; i++ {
+		elemPtr := unsafe.Pointer(uintptr(p) + memOffset)
+		memOffset += elemMemSize
+		size, err := elemSSZ.(SizeRecorder).RecordSize(c, elemPtr)
+		if err != nil {
+			return 0, err
+		}
+		c.Set(start+int(i), elemPtr, elemSSZ, size)
+		out += size
+	}
+	return out, nil
+}
+
+// Encode the series, with the sizes of the elements as recorded in the size cache of the writer, if any.
+// pointer must point to start of the series contents
+func encodeRecordedVarSeries(elemSSZ SSZ, length uint64, elemMemSize uintptr, eb *EncodingWriter, p unsafe.Pointer) error {
+	if encodesParallel(eb, length) {
+		return encodeParallelSeries(elemSSZ, length, elemMemSize, eb, p)
+	}
+	if eb.Patching() {
+		return EncodePatchedVarSeries(elemSSZ.Encode, length, elemMemSize, eb, p)
+	}
+	if eb.Sizes == nil || !recordsSizes(elemSSZ) {
+		return EncodeVarSeries(elemSSZ.Encode, elemSSZ.SizeOf, length, elemMemSize, eb, p)
+	}
+	sizeFn := func(elemPtr unsafe.Pointer) uint64 {
+		if size, ok := eb.Sizes.Take(elemPtr, elemSSZ); ok {
+			return size
+		}
+		return elemSSZ.SizeOf(elemPtr)
+	}
+	return EncodeVarSeries(elemSSZ.Encode, sizeFn, length, elemMemSize, eb, p)
+}
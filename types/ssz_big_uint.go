@@ -0,0 +1,149 @@
+package types
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"math/big"
+	"reflect"
+	"unsafe"
+)
+
+var bigIntTyp = reflect.TypeOf(big.Int{})
+
+// Opt-in adapter to represent a SSZ uint128 or uint256 as a big.Int.
+// The memory is expected to be a big.Int (use a *big.Int field to get the SSZPtr behavior around it).
+// Note that this is not a basic type when used as series element: big.Int values are not packed in chunks.
+// Use the uints package for packed series.
+type SSZBigUint struct {
+	byteLen uint64
+}
+
+func NewSSZBigUint(typ reflect.Type, byteLen uint64) (*SSZBigUint, error) {
+	if typ != bigIntTyp {
+		return nil, fmt.Errorf("typ %s is not a big.Int", typ.String())
+	}
+	if byteLen != 16 && byteLen != 32 {
+		return nil, fmt.Errorf("big uint of %d bytes is not supported, expected 16 or 32", byteLen)
+	}
+	return &SSZBigUint{byteLen: byteLen}, nil
+}
+
+// Creates a factory function that recognizes big.Int as a uint of the given byte length (16 or 32),
+// and defers to DefaultSSZFactory for all other types.
+// Series of big.Int values are not allowed for uint128, as these need to be packed. Use uints.Uint128 instead.
+// Example:
+//
+//	func MyFactory(typ reflect.Type) (SSZ, error) {
+//		return bigUint256FactoryFn(MyFactory, typ)
+//	}
+//
+//	var bigUint256FactoryFn = BigUintFactoryFn(32)
+func BigUintFactoryFn(byteLen uint64) func(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+	return func(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+		if typ == bigIntTyp {
+			return NewSSZBigUint(typ, byteLen)
+		}
+		if byteLen < 32 && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			if elemTyp := typ.Elem(); elemTyp == bigIntTyp || (elemTyp.Kind() == reflect.Ptr && elemTyp.Elem() == bigIntTyp) {
+				return nil, fmt.Errorf("series %s of big.Int elements cannot be packed as %d bytes uints", typ.String(), byteLen)
+			}
+		}
+		return DefaultSSZFactory(factory, typ)
+	}
+}
+
+func (v *SSZBigUint) FuzzMinLen() uint64 {
+	return v.byteLen
+}
+
+func (v *SSZBigUint) FuzzMaxLen() uint64 {
+	return v.byteLen
+}
+
+func (v *SSZBigUint) MinLen() uint64 {
+	return v.byteLen
+}
+
+func (v *SSZBigUint) MaxLen() uint64 {
+	return v.byteLen
+}
+
+func (v *SSZBigUint) FixedLen() uint64 {
+	return v.byteLen
+}
+
+func (v *SSZBigUint) IsFixed() bool {
+	return true
+}
+
+func (v *SSZBigUint) SizeOf(p unsafe.Pointer) uint64 {
+	return v.byteLen
+}
+
+// Writes the little-endian representation of the integer into the scratch space, and returns it.
+func (v *SSZBigUint) littleEndian(x *big.Int, scratch []byte) ([]byte, error) {
+	if x.Sign() < 0 {
+		return nil, fmt.Errorf("cannot encode negative integer %s as uint", x.String())
+	}
+	if bitLen := uint64(x.BitLen()); bitLen > v.byteLen<<3 {
+		return nil, fmt.Errorf("integer of %d bits does not fit in %d bytes uint", bitLen, v.byteLen)
+	}
+	out := scratch[:v.byteLen]
+	for i := range out {
+		out[i] = 0
+	}
+	// big-endian to little-endian
+	be := x.Bytes()
+	for i, b := range be {
+		out[len(be)-1-i] = b
+	}
+	return out, nil
+}
+
+func (v *SSZBigUint) Validate(p unsafe.Pointer) error {
+	var tmp [32]byte
+	_, err := v.littleEndian((*big.Int)(p), tmp[:])
+	return err
+}
+
+func (v *SSZBigUint) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	data, err := v.littleEndian((*big.Int)(p), eb.Scratch[:])
+	if err != nil {
+		return err
+	}
+	return eb.Write(data)
+}
+
+func (v *SSZBigUint) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	var tmp [32]byte
+	data := tmp[:v.byteLen]
+	if _, err := dr.Read(data); err != nil {
+		return err
+	}
+	// little-endian to big-endian
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	(*big.Int)(p).SetBytes(data)
+	return nil
+}
+
+func (v *SSZBigUint) DryCheck(dr *DecodingReader) error {
+	_, err := dr.Skip(v.byteLen)
+	return err
+}
+
+func (v *SSZBigUint) HashTreeRoot(h MerkleFn, p unsafe.Pointer) (out [32]byte) {
+	if _, err := v.littleEndian((*big.Int)(p), out[:]); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (v *SSZBigUint) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	w.WriteIndent(indent)
+	w.Write((*big.Int)(p).String())
+}
@@ -22,16 +22,20 @@ type SSZBitlist struct {
 var bitlistMeta = reflect.TypeOf((*bitfields.BitlistMeta)(nil)).Elem()
 
 func NewSSZBitlist(typ reflect.Type) (*SSZBitlist, error) {
+	bitLimit, err := ReadListLimit(typ)
+	if err != nil {
+		return nil, err
+	}
+	return NewSSZBitlistWithLimit(typ, bitLimit)
+}
+
+func NewSSZBitlistWithLimit(typ reflect.Type, bitLimit uint64) (*SSZBitlist, error) {
 	if typ.Kind() != reflect.Slice {
 		return nil, fmt.Errorf("typ is not a dynamic-length bytes slice (bitlist requirement)")
 	}
 	if typ.Elem().Kind() != reflect.Uint8 {
 		return nil, fmt.Errorf("typ is not a bytes slice (bitlist requirement)")
 	}
-	bitLimit, err := ReadListLimit(typ)
-	if err != nil {
-		return nil, err
-	}
 	byteLimit := (bitLimit + 7) >> 3
 	res := &SSZBitlist{
 		bitLimit:  bitLimit,
@@ -78,9 +82,18 @@ func (v *SSZBitlist) SizeOf(p unsafe.Pointer) uint64 {
 	return uint64(sh.Len)
 }
 
+func (v *SSZBitlist) Validate(p unsafe.Pointer) error {
+	sh := ptrutil.ReadSliceHeader(p)
+	data := *(*[]byte)(unsafe.Pointer(sh))
+	return bitfields.BitlistCheck(data, v.bitLimit)
+}
+
 func (v *SSZBitlist) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	sh := ptrutil.ReadSliceHeader(p)
 	data := *(*[]byte)(unsafe.Pointer(sh))
+	if err := bitfields.BitlistCheck(data, v.bitLimit); err != nil {
+		return fmt.Errorf("cannot encode bitlist: %v", err)
+	}
 	return eb.Write(data)
 }
 
@@ -138,13 +151,12 @@ func (v *SSZBitlist) DryCheck(dr *DecodingReader) error {
 	return bitfields.BitlistCheckLastByte(last, v.bitLimit-((span-1)<<3))
 }
 
-func (v *SSZBitlist) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
-	sh := ptrutil.ReadSliceHeader(p)
-	data := *(*[]byte)(unsafe.Pointer(sh))
-	bitLen := bitfields.BitlistLen(data)
+// Get the leaves of the bitlist data to merkleize, excluding the delimiting bit.
+func BitlistLeaves(data []byte) (leaf func(i uint64) []byte, leafCount uint64, bitLen uint64) {
+	bitLen = bitfields.BitlistLen(data)
 	byteLen := (bitLen + 7) >> 3
-	leafCount := (byteLen + 31) >> 5
-	leaf := func(i uint64) []byte {
+	leafCount = (byteLen + 31) >> 5
+	leaf = func(i uint64) []byte {
 		s := i << 5
 		e := (i + 1) << 5
 		// pad the data
@@ -165,6 +177,14 @@ func (v *SSZBitlist) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
 		// i.e. as sole bit in next (ignored) chunk of data.
 		return data[s:e]
 	}
+	return
+}
+
+func (v *SSZBitlist) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	sh := ptrutil.ReadSliceHeader(p)
+	data := *(*[]byte)(unsafe.Pointer(sh))
+	leaf, leafCount, bitLen := BitlistLeaves(data)
+	checkMerkleLimit(bitLen, v.bitLimit)
 	return h.MixIn(merkle.Merkleize(h, leafCount, v.leafLimit, leaf), bitLen)
 }
 
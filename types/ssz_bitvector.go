@@ -74,6 +74,12 @@ func (v *SSZBitvector) SizeOf(p unsafe.Pointer) uint64 {
 	return v.byteLen
 }
 
+func (v *SSZBitvector) Validate(p unsafe.Pointer) error {
+	sh := ptrutil.GetSliceHeader(p, v.byteLen)
+	data := *(*[]byte)(unsafe.Pointer(sh))
+	return bitfields.BitvectorCheck(data, v.bitLen)
+}
+
 func (v *SSZBitvector) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	sh := ptrutil.GetSliceHeader(p, v.byteLen)
 	data := *(*[]byte)(unsafe.Pointer(sh))
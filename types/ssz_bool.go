@@ -39,6 +39,13 @@ func (v SSZBool) SizeOf(p unsafe.Pointer) uint64 {
 	return 1
 }
 
+func (v SSZBool) Validate(p unsafe.Pointer) error {
+	if b := *(*byte)(p); b > 1 {
+		return fmt.Errorf("bool value %d is invalid", b)
+	}
+	return nil
+}
+
 func (v SSZBool) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	return eb.WriteByte(*(*byte)(p))
 }
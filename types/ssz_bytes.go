@@ -17,16 +17,20 @@ type SSZBytes struct {
 }
 
 func NewSSZBytes(typ reflect.Type) (*SSZBytes, error) {
+	limit, err := ReadListLimit(typ)
+	if err != nil {
+		return nil, err
+	}
+	return NewSSZBytesWithLimit(typ, limit)
+}
+
+func NewSSZBytesWithLimit(typ reflect.Type, limit uint64) (*SSZBytes, error) {
 	if typ.Kind() != reflect.Slice {
 		return nil, fmt.Errorf("typ is not a dynamic-length bytes slice")
 	}
 	if typ.Elem().Kind() != reflect.Uint8 {
 		return nil, fmt.Errorf("typ is not a bytes slice")
 	}
-	limit, err := ReadListLimit(typ)
-	if err != nil {
-		return nil, err
-	}
 	return &SSZBytes{limit: limit}, nil
 }
 
@@ -59,8 +63,18 @@ func (v *SSZBytes) SizeOf(p unsafe.Pointer) uint64 {
 	return uint64(sh.Len)
 }
 
+func (v *SSZBytes) Validate(p unsafe.Pointer) error {
+	if length := uint64(ptrutil.ReadSliceHeader(p).Len); length > v.limit {
+		return fmt.Errorf("got %d bytes, expected no more than %d bytes", length, v.limit)
+	}
+	return nil
+}
+
 func (v *SSZBytes) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	sh := ptrutil.ReadSliceHeader(p)
+	if uint64(sh.Len) > v.limit {
+		return fmt.Errorf("cannot encode %d bytes, limit is %d", sh.Len, v.limit)
+	}
 	data := *(*[]byte)(unsafe.Pointer(sh))
 	return eb.Write(data)
 }
@@ -100,6 +114,7 @@ func (v *SSZBytes) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
 	sh := ptrutil.ReadSliceHeader(p)
 	data := *(*[]byte)(unsafe.Pointer(sh))
 	dataLen := uint64(len(data))
+	checkMerkleLimit(dataLen, v.limit)
 	leafCount := (dataLen + 31) >> 5
 	leafLimit := (v.limit + 31) >> 5
 	leaf := func(i uint64) []byte {
@@ -56,6 +56,10 @@ func (v *SSZBytesN) SizeOf(p unsafe.Pointer) uint64 {
 	return v.length
 }
 
+func (v *SSZBytesN) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
 func (v *SSZBytesN) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	sh := ptrutil.GetSliceHeader(p, v.length)
 	data := *(*[]byte)(unsafe.Pointer(sh))
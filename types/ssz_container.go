@@ -7,8 +7,10 @@ import (
 	. "github.com/protolambda/zssz/htr"
 	"github.com/protolambda/zssz/merkle"
 	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/util/ptrutil"
 	"github.com/protolambda/zssz/util/tags"
 	"reflect"
+	"strings"
 	"unsafe"
 )
 
@@ -24,11 +26,39 @@ func (fn FieldPtrFn) WrapOffset(memOffset uintptr) FieldPtrFn {
 	}
 }
 
+// Wraps the pointer function to be applied to the struct pointed to by the pointer at the given memory offset.
+// A nil pointer is handled as a pointer to the given zero value.
+func (fn FieldPtrFn) WrapPtrDeref(memOffset uintptr, zeroPtr unsafe.Pointer) FieldPtrFn {
+	return func(p unsafe.Pointer) unsafe.Pointer {
+		innerPtr := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(p) + memOffset))
+		if innerPtr == nil {
+			return fn(zeroPtr)
+		}
+		return fn(innerPtr)
+	}
+}
+
+// Wraps the pointer function to be applied to the struct pointed to by the pointer at the given memory offset.
+// A nil pointer is replaced with a pointer to a newly allocated struct of the given type.
+func (fn FieldPtrFn) WrapPtrAlloc(memOffset uintptr, elemTyp reflect.Type) FieldPtrFn {
+	return func(p unsafe.Pointer) unsafe.Pointer {
+		ptrPtr := unsafe.Pointer(uintptr(p) + memOffset)
+		innerPtr := *(*unsafe.Pointer)(ptrPtr)
+		if innerPtr == nil {
+			innerPtr = ptrutil.AllocateSpace(ptrPtr, elemTyp)
+		}
+		return fn(innerPtr)
+	}
+}
+
 type ContainerField struct {
 	ssz      SSZ
 	name     string
 	pureName string
-	ptrFn    FieldPtrFn
+	// pointer to the field, for reading
+	ptrFn FieldPtrFn
+	// pointer to the field, for decoding into. Allocates squashed pointer structs if necessary.
+	decPtrFn FieldPtrFn
 	isFixed  bool
 }
 
@@ -38,6 +68,20 @@ func (c *ContainerField) Wrap(name string, memOffset uintptr) ContainerField {
 		name:     name + ">" + c.name,
 		pureName: c.name,
 		ptrFn:    c.ptrFn.WrapOffset(memOffset),
+		decPtrFn: c.decPtrFn.WrapOffset(memOffset),
+		isFixed:  c.ssz.IsFixed(),
+	}
+}
+
+// Wrap the field, to be squashed into a struct through a pointer at the given memory offset.
+// A nil pointer is read as zero value, and is allocated when decoding.
+func (c *ContainerField) WrapPtr(name string, memOffset uintptr, elemTyp reflect.Type, zeroPtr unsafe.Pointer) ContainerField {
+	return ContainerField{
+		ssz:      c.ssz,
+		name:     name + ">" + c.name,
+		pureName: c.name,
+		ptrFn:    c.ptrFn.WrapPtrDeref(memOffset, zeroPtr),
+		decPtrFn: c.decPtrFn.WrapPtrAlloc(memOffset, elemTyp),
 		isFixed:  c.ssz.IsFixed(),
 	}
 }
@@ -72,11 +116,17 @@ func (v *SSZContainer) SquashFields() []ContainerField {
 // 0 fields (nil) if struct field is ignored
 // 1 field for normal struct fields
 // 0 or more fields when a struct field is squashed (recursively adding to the total field collection)
-func getFields(factory SSZFactoryFn, f *reflect.StructField) (out []ContainerField, err error) {
+// 0 fields (nil) if struct field is not active in the selected fork
+func getFields(factory SSZFactoryFn, f *reflect.StructField, fork *ForkSelection) (out []ContainerField, err error) {
 	if tags.HasFlag(f, SSZ_TAG, OMIT_FLAG) {
 		return nil, nil
 	}
-	fieldSSZ, err := factory(f.Type)
+	if active, err := fork.IsActive(f); err != nil {
+		return nil, err
+	} else if !active {
+		return nil, nil
+	}
+	fieldSSZ, err := GetFieldSSZ(factory, f, f.Type)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +140,16 @@ func getFields(factory SSZFactoryFn, f *reflect.StructField) (out []ContainerFie
 			}
 			return out, nil
 		}
+		// squash the struct behind the pointer. Nil pointers are always treated as zero value.
+		if ptrSSZ, ok := fieldSSZ.(*SSZPtr); ok {
+			if squashable, ok := ptrSSZ.elemSSZ.(SquashableFields); ok {
+				zeroPtr := unsafe.Pointer(reflect.New(ptrSSZ.elemTyp).Pointer())
+				for _, sq := range squashable.SquashFields() {
+					out = append(out, sq.WrapPtr(f.Name, f.Offset, ptrSSZ.elemTyp, zeroPtr))
+				}
+				return out, nil
+			}
+		}
 		// anonymous fields can be handled as normal fields. Only error when it was tagged to be squashed.
 		if forceSquash {
 			return nil, fmt.Errorf("could not squash field %s", f.Name)
@@ -98,11 +158,16 @@ func getFields(factory SSZFactoryFn, f *reflect.StructField) (out []ContainerFie
 
 	out = append(out, ContainerField{
 		ssz: fieldSSZ, pureName: f.Name, name: f.Name,
-		ptrFn: GetOffsetPtrFn(f.Offset), isFixed: fieldSSZ.IsFixed()})
+		ptrFn: GetOffsetPtrFn(f.Offset), decPtrFn: GetOffsetPtrFn(f.Offset), isFixed: fieldSSZ.IsFixed()})
 	return
 }
 
 func NewSSZContainer(factory SSZFactoryFn, typ reflect.Type) (*SSZContainer, error) {
+	return NewSSZForkContainer(factory, typ, nil)
+}
+
+// Creates a container with the fields of the selected fork. See ForkSelection.IsActive
+func NewSSZForkContainer(factory SSZFactoryFn, typ reflect.Type, fork *ForkSelection) (*SSZContainer, error) {
 	if typ.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("typ is not a struct")
 	}
@@ -111,7 +176,7 @@ func NewSSZContainer(factory SSZFactoryFn, typ reflect.Type) (*SSZContainer, err
 		// get the Go struct field
 		sField := typ.Field(i)
 		// For this field, get the SSZ field(s). There may be more if the Go field is squashed.
-		fields, err := getFields(factory, &sField)
+		fields, err := getFields(factory, &sField, fork)
 		if err != nil {
 			return nil, err
 		}
@@ -173,6 +238,50 @@ func (v *SSZContainer) SizeOf(p unsafe.Pointer) uint64 {
 	return out
 }
 
+func (v *SSZContainer) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	if v.isFixedLen {
+		return v.fixedLen, nil
+	}
+	recordCount := 0
+	for i := range v.Fields {
+		if recordsSizes(v.Fields[i].ssz) {
+			recordCount++
+		}
+	}
+	j := c.Reserve(recordCount)
+	out := v.fixedLen
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if f.isFixed {
+			continue
+		}
+		fieldPtr := f.ptrFn(p)
+		if !recordsSizes(f.ssz) {
+			out += f.ssz.SizeOf(fieldPtr)
+			continue
+		}
+		size, err := f.ssz.(SizeRecorder).RecordSize(c, fieldPtr)
+		if err != nil {
+			return 0, err
+		}
+		c.Set(j, fieldPtr, f.ssz, size)
+		j++
+		out += size
+	}
+	return out, nil
+}
+
+func (v *SSZContainer) Validate(p unsafe.Pointer) error {
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if err := ValidateValue(f.ssz, f.ptrFn(p)); err != nil {
+			// squashed fields are named after the path through the squashed structs
+			return wrapPath(strings.Replace(f.name, ">", ".", -1), err)
+		}
+	}
+	return nil
+}
+
 func (v *SSZContainer) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	// hot-path for common case of fixed-size container
 	if v.isFixedLen {
@@ -184,6 +293,9 @@ func (v *SSZContainer) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 		}
 		return nil
 	}
+	if eb.Patching() {
+		return v.encodePatched(eb, p)
+	}
 	// the previous offset, to calculate a new offset from, starting after the fixed data.
 	prevOffset := v.fixedLen
 	// span of the previous var-size element
@@ -200,7 +312,7 @@ func (v *SSZContainer) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 			} else {
 				prevOffset = offset
 			}
-			prevSize = f.ssz.SizeOf(f.ptrFn(p))
+			prevSize = EncodedSizeOf(eb, f.ssz, f.ptrFn(p))
 		}
 	}
 	// Only iterate over and write dynamic parts if we need to.
@@ -217,6 +329,38 @@ func (v *SSZContainer) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	return nil
 }
 
+// Encode the fixed part with offset placeholders, and patch each offset before writing the dynamic part it points to.
+func (v *SSZContainer) encodePatched(eb *EncodingWriter, p unsafe.Pointer) error {
+	start := eb.Written()
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if f.isFixed {
+			if err := f.ssz.Encode(eb, f.ptrFn(p)); err != nil {
+				return err
+			}
+		} else if err := eb.WriteOffsetPlaceholders(1); err != nil {
+			return err
+		}
+	}
+	// position of the offset of the next var-size field
+	pos := start
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if f.isFixed {
+			pos += int(f.ssz.FixedLen())
+			continue
+		}
+		if err := eb.PatchOffset(pos, start); err != nil {
+			return err
+		}
+		pos += BYTES_PER_LENGTH_OFFSET
+		if err := f.ssz.Encode(eb, f.ptrFn(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (v *SSZContainer) decodeVarSizeFuzzmode(dr *DecodingReader, p unsafe.Pointer) error {
 	lengthLeftOver := v.fuzzMinLen
 
@@ -233,7 +377,7 @@ func (v *SSZContainer) decodeVarSizeFuzzmode(dr *DecodingReader, p unsafe.Pointe
 			return err
 		}
 		scoped.EnableFuzzMode()
-		if err := f.ssz.Decode(scoped, f.ptrFn(p)); err != nil {
+		if err := f.ssz.Decode(scoped, f.decPtrFn(p)); err != nil {
 			return err
 		}
 		dr.UpdateIndexFromScoped(scoped)
@@ -319,13 +463,13 @@ func (v *SSZContainer) processFixedPart(dr *DecodingReader, fieldHandler func(f
 
 func (v *SSZContainer) decodeVarSize(dr *DecodingReader, p unsafe.Pointer) error {
 	offsets, err := v.processFixedPart(dr, func(f *ContainerField) error {
-		return f.ssz.Decode(dr, f.ptrFn(p))
+		return f.ssz.Decode(dr, f.decPtrFn(p))
 	})
 	if err != nil {
 		return err
 	}
 	return v.decodeDynamicPart(dr, offsets, func(scopedDr *DecodingReader, f *ContainerField) error {
-		return f.ssz.Decode(scopedDr, f.ptrFn(p))
+		return f.ssz.Decode(scopedDr, f.decPtrFn(p))
 	})
 }
 
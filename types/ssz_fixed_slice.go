@@ -0,0 +1,145 @@
+package types
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"reflect"
+	"unsafe"
+)
+
+// A slice with a fixed length, serialized and merkleized as a vector.
+// The slice contents are laid out like an array, so the vector type of the equivalent array is used.
+type SSZFixedSlice struct {
+	vecSSZ SSZ
+	length uint64
+	alloc  ptrutil.SliceAllocationFn
+	// pointer to zeroed contents, used for empty slices
+	zeroPtr unsafe.Pointer
+}
+
+func NewSSZFixedSlice(factory SSZFactoryFn, typ reflect.Type, length uint64) (*SSZFixedSlice, error) {
+	if typ.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("typ is not a slice")
+	}
+	if length == 0 {
+		return nil, fmt.Errorf("fixed-length slice must have a non-zero length")
+	}
+	arrTyp := reflect.ArrayOf(int(length), typ.Elem())
+	vecSSZ, err := DefaultSSZFactory(factory, arrTyp)
+	if err != nil {
+		return nil, err
+	}
+	return &SSZFixedSlice{
+		vecSSZ:  vecSSZ,
+		length:  length,
+		alloc:   ptrutil.MakeSliceAllocFn(typ),
+		zeroPtr: unsafe.Pointer(reflect.New(arrTyp).Pointer()),
+	}, nil
+}
+
+// Get the pointer to the contents. Empty slices are treated as zeroed contents.
+// Errors if the slice has any other length than the fixed length.
+func (v *SSZFixedSlice) contents(p unsafe.Pointer) (unsafe.Pointer, error) {
+	sh := ptrutil.ReadSliceHeader(p)
+	length := uint64(sh.Len)
+	if length == v.length {
+		return sh.Data, nil
+	}
+	if length == 0 {
+		return v.zeroPtr, nil
+	}
+	return nil, fmt.Errorf("fixed-length slice must have %d elements, but has %d", v.length, length)
+}
+
+func (v *SSZFixedSlice) FuzzMinLen() uint64 {
+	return v.vecSSZ.FuzzMinLen()
+}
+
+func (v *SSZFixedSlice) FuzzMaxLen() uint64 {
+	return v.vecSSZ.FuzzMaxLen()
+}
+
+func (v *SSZFixedSlice) MinLen() uint64 {
+	return v.vecSSZ.MinLen()
+}
+
+func (v *SSZFixedSlice) MaxLen() uint64 {
+	return v.vecSSZ.MaxLen()
+}
+
+func (v *SSZFixedSlice) FixedLen() uint64 {
+	return v.vecSSZ.FixedLen()
+}
+
+func (v *SSZFixedSlice) IsFixed() bool {
+	return v.vecSSZ.IsFixed()
+}
+
+func (v *SSZFixedSlice) SizeOf(p unsafe.Pointer) uint64 {
+	if v.vecSSZ.IsFixed() {
+		return v.vecSSZ.FixedLen()
+	}
+	contentsPtr, err := v.contents(p)
+	if err != nil {
+		panic(err)
+	}
+	return v.vecSSZ.SizeOf(contentsPtr)
+}
+
+func (v *SSZFixedSlice) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	if v.vecSSZ.IsFixed() {
+		return v.vecSSZ.FixedLen(), nil
+	}
+	contentsPtr, err := v.contents(p)
+	if err != nil {
+		return 0, err
+	}
+	return RecordSize(c, v.vecSSZ, contentsPtr)
+}
+
+func (v *SSZFixedSlice) Validate(p unsafe.Pointer) error {
+	contentsPtr, err := v.contents(p)
+	if err != nil {
+		return err
+	}
+	return ValidateValue(v.vecSSZ, contentsPtr)
+}
+
+func (v *SSZFixedSlice) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	contentsPtr, err := v.contents(p)
+	if err != nil {
+		return err
+	}
+	return v.vecSSZ.Encode(eb, contentsPtr)
+}
+
+func (v *SSZFixedSlice) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	contentsPtr := v.alloc.MutateLenOrAllocNew(p, v.length)
+	return v.vecSSZ.Decode(dr, contentsPtr)
+}
+
+func (v *SSZFixedSlice) DryCheck(dr *DecodingReader) error {
+	return v.vecSSZ.DryCheck(dr)
+}
+
+func (v *SSZFixedSlice) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	contentsPtr, err := v.contents(p)
+	if err != nil {
+		panic(err)
+	}
+	return v.vecSSZ.HashTreeRoot(h, contentsPtr)
+}
+
+func (v *SSZFixedSlice) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	contentsPtr, err := v.contents(p)
+	if err != nil {
+		w.WriteIndent(indent)
+		w.Write(fmt.Sprintf("invalid (%v)", err))
+		return
+	}
+	v.vecSSZ.Pretty(indent, w, contentsPtr)
+}
@@ -23,13 +23,17 @@ type SSZList struct {
 }
 
 func NewSSZList(factory SSZFactoryFn, typ reflect.Type) (*SSZList, error) {
-	if typ.Kind() != reflect.Slice {
-		return nil, fmt.Errorf("typ %v is not a dynamic-length array", typ)
-	}
 	limit, err := ReadListLimit(typ)
 	if err != nil {
 		return nil, err
 	}
+	return NewSSZListWithLimit(factory, typ, limit)
+}
+
+func NewSSZListWithLimit(factory SSZFactoryFn, typ reflect.Type, limit uint64) (*SSZList, error) {
+	if typ.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("typ %v is not a dynamic-length array", typ)
+	}
 
 	elemTyp := typ.Elem()
 
@@ -43,7 +47,7 @@ func NewSSZList(factory SSZFactoryFn, typ reflect.Type) (*SSZList, error) {
 		byteLimit = limit * elemSSZ.FixedLen()
 	} else {
 		fixedElemSize = BYTES_PER_LENGTH_OFFSET
-		byteLimit = limit * elemSSZ.MaxLen()
+		byteLimit = limit * (BYTES_PER_LENGTH_OFFSET + elemSSZ.MaxLen())
 	}
 	res := &SSZList{
 		alloc:         ptrutil.MakeSliceAllocFn(typ),
@@ -57,6 +61,14 @@ func NewSSZList(factory SSZFactoryFn, typ reflect.Type) (*SSZList, error) {
 	return res, nil
 }
 
+func (v *SSZList) ElemSSZ() SSZ {
+	return v.elemSSZ
+}
+
+func (v *SSZList) Limit() uint64 {
+	return v.limit
+}
+
 func (v *SSZList) FuzzMinLen() uint64 {
 	return 8
 }
@@ -97,12 +109,34 @@ func (v *SSZList) SizeOf(p unsafe.Pointer) uint64 {
 	}
 }
 
+func (v *SSZList) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	sh := ptrutil.ReadSliceHeader(p)
+	if v.elemSSZ.IsFixed() {
+		return uint64(sh.Len) * v.fixedElemSize, nil
+	}
+	return recordVarSeriesSizes(c, v.elemSSZ, uint64(sh.Len), v.elemMemSize, sh.Data)
+}
+
+func (v *SSZList) Validate(p unsafe.Pointer) error {
+	sh := ptrutil.ReadSliceHeader(p)
+	if length := uint64(sh.Len); length > v.limit {
+		return fmt.Errorf("got %d elements, expected no more than %d elements", length, v.limit)
+	}
+	return validateSeries(v.elemSSZ, uint64(sh.Len), v.elemMemSize, sh.Data)
+}
+
 func (v *SSZList) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	sh := ptrutil.ReadSliceHeader(p)
+	if uint64(sh.Len) > v.limit {
+		return fmt.Errorf("cannot encode list of %d elements, limit is %d", sh.Len, v.limit)
+	}
 	if v.elemSSZ.IsFixed() {
+		if encodesParallel(eb, uint64(sh.Len)) {
+			return encodeParallelSeries(v.elemSSZ, uint64(sh.Len), v.elemMemSize, eb, sh.Data)
+		}
 		return EncodeFixedSeries(v.elemSSZ.Encode, uint64(sh.Len), v.elemMemSize, eb, sh.Data)
 	} else {
-		return EncodeVarSeries(v.elemSSZ.Encode, v.elemSSZ.SizeOf, uint64(sh.Len), v.elemMemSize, eb, sh.Data)
+		return encodeRecordedVarSeries(v.elemSSZ, uint64(sh.Len), v.elemMemSize, eb, sh.Data)
 	}
 }
 
@@ -21,17 +21,21 @@ type SSZBasicList struct {
 }
 
 func NewSSZBasicList(typ reflect.Type) (*SSZBasicList, error) {
-	if typ.Kind() != reflect.Slice {
-		return nil, fmt.Errorf("typ is not a dynamic-length array")
-	}
 	limit, err := ReadListLimit(typ)
 	if err != nil {
 		return nil, err
 	}
+	return NewSSZBasicListWithLimit(typ, limit)
+}
+
+func NewSSZBasicListWithLimit(typ reflect.Type, limit uint64) (*SSZBasicList, error) {
+	if typ.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("typ is not a dynamic-length array")
+	}
 
 	elemTyp := typ.Elem()
 	elemKind := elemTyp.Kind()
-	elemSSZ, err := GetBasicSSZElemType(elemKind)
+	elemSSZ, err := GetBasicSSZElemTypeOf(elemTyp)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +53,14 @@ func NewSSZBasicList(typ reflect.Type) (*SSZBasicList, error) {
 	return res, nil
 }
 
+func (v *SSZBasicList) ElemSSZ() SSZ {
+	return v.elemSSZ
+}
+
+func (v *SSZBasicList) Limit() uint64 {
+	return v.limit
+}
+
 func (v *SSZBasicList) FuzzMinLen() uint64 {
 	return 8
 }
@@ -78,8 +90,22 @@ func (v *SSZBasicList) SizeOf(p unsafe.Pointer) uint64 {
 	return uint64(sh.Len) * v.elemSSZ.FixedLen()
 }
 
+func (v *SSZBasicList) Validate(p unsafe.Pointer) error {
+	sh := ptrutil.ReadSliceHeader(p)
+	if length := uint64(sh.Len); length > v.limit {
+		return fmt.Errorf("got %d elements, expected no more than %d elements", length, v.limit)
+	}
+	if v.elemKind == reflect.Bool {
+		return validateBools(uint64(sh.Len), sh.Data)
+	}
+	return nil
+}
+
 func (v *SSZBasicList) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	sh := ptrutil.ReadSliceHeader(p)
+	if uint64(sh.Len) > v.limit {
+		return fmt.Errorf("cannot encode list of %d elements, limit is %d", sh.Len, v.limit)
+	}
 
 	// we can just write the data as-is in a few contexts:
 	// - if we're in a little endian architecture
@@ -149,13 +175,14 @@ func (v *SSZBasicList) DryCheck(dr *DecodingReader) error {
 
 func (v *SSZBasicList) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
 	sh := ptrutil.ReadSliceHeader(p)
+	checkMerkleLimit(uint64(sh.Len), v.limit)
 
 	bytesLen := uint64(sh.Len) * v.elemSSZ.FixedLen()
 	bytesLimit := v.limit * v.elemSSZ.FixedLen()
 	if endianness.IsLittleEndian || v.elemSSZ.FixedLen() == 1 {
 		return h.MixIn(LittleEndianBasicSeriesHTR(h, sh.Data, bytesLen, bytesLimit), uint64(sh.Len))
 	} else {
-		return h.MixIn(BigEndianBasicSeriesHTR(h, sh.Data, bytesLen, bytesLimit, uint8(v.elemSSZ.FixedLen())), uint64(sh.Len))
+		return h.MixIn(BigEndianBasicSeriesHTR(h, sh.Data, bytesLen, bytesLimit, basicWordSize(v.elemSSZ.FixedLen())), uint64(sh.Len))
 	}
 }
 
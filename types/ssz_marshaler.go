@@ -0,0 +1,252 @@
+package types
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"reflect"
+	"unsafe"
+)
+
+// Custom encoding, compatible with the fastssz method set.
+type Marshaler interface {
+	SizeSSZ() int
+	MarshalSSZTo(buf []byte) ([]byte, error)
+}
+
+// Custom decoding, compatible with the fastssz method set.
+type Unmarshaler interface {
+	UnmarshalSSZ(buf []byte) error
+}
+
+// Custom hash-tree-root, compatible with the fastssz method set.
+// The hash function used with zssz is ignored for custom hash-tree-roots.
+type HashRoot interface {
+	HashTreeRoot() ([32]byte, error)
+}
+
+// The root of a hasher, after passing it to a HashTreeRootWith method, compatible with the fastssz hasher.
+type HasherRoot interface {
+	HashRoot() ([32]byte, error)
+}
+
+// Creates a hasher to pass to HashTreeRootWith methods, e.g. func() interface{} { return ssz.NewHasher() } for fastssz.
+// The hasher type is a dependency of the custom methods, not of zssz: the methods are matched by shape instead.
+type NewHasherFn func() interface{}
+
+var (
+	marshalerTyp   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerTyp = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	hashRootTyp    = reflect.TypeOf((*HashRoot)(nil)).Elem()
+	hasherRootTyp  = reflect.TypeOf((*HasherRoot)(nil)).Elem()
+	errorTyp       = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// The marshaler aware SSZ factory: uses the custom methods of types where available.
+func MarshalerAwareFactory(typ reflect.Type) (SSZ, error) {
+	return MarshalerAwareFactoryFn(MarshalerAwareFactory, typ)
+}
+
+// Factory function that wraps types with custom marshaling methods (pointer-receivers) in a SSZMarshaler,
+// and defers to DefaultSSZFactory for all other types.
+// The interfaces are only checked when building the SSZ definition.
+func MarshalerAwareFactoryFn(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+	if HasCustomMarshaling(typ) {
+		return NewSSZMarshaler(factory, typ)
+	}
+	return DefaultSSZFactory(factory, typ)
+}
+
+// Like MarshalerAwareFactoryFn, but also uses HashTreeRootWith(hasher) error methods,
+// with a new hasher of newHasher per call. HashTreeRootWith is preferred over HashTreeRoot.
+func MarshalerAwareFactoryFnWithHasher(newHasher NewHasherFn) func(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+	return func(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+		hashRootWith, err := getHashTreeRootWith(typ, newHasher)
+		if err != nil {
+			return nil, err
+		}
+		if !HasCustomMarshaling(typ) && !hashRootWith.IsValid() {
+			return DefaultSSZFactory(factory, typ)
+		}
+		def, err := DefaultSSZFactory(factory, typ)
+		if err != nil {
+			return nil, fmt.Errorf("type %s with custom SSZ methods has no default SSZ definition: %v", typ.String(), err)
+		}
+		res := newSSZMarshaler(typ, def)
+		if hashRootWith.IsValid() {
+			res.typ, res.hashRootWith, res.newHasher = typ, hashRootWith, newHasher
+		}
+		return res, nil
+	}
+}
+
+// Gets the HashTreeRootWith method of the pointer type, if it takes the hasher of newHasher, and returns an error.
+// The zero value if there is no such method.
+func getHashTreeRootWith(typ reflect.Type, newHasher NewHasherFn) (reflect.Value, error) {
+	hasherTyp := reflect.TypeOf(newHasher())
+	if hasherTyp == nil || !hasherTyp.Implements(hasherRootTyp) {
+		return reflect.Value{}, fmt.Errorf("hasher %v has no HashRoot() ([32]byte, error) method", hasherTyp)
+	}
+	m, ok := reflect.PtrTo(typ).MethodByName("HashTreeRootWith")
+	// the receiver is the first argument of the method function
+	if !ok || m.Type.NumIn() != 2 || !hasherTyp.AssignableTo(m.Type.In(1)) ||
+		m.Type.NumOut() != 1 || m.Type.Out(0) != errorTyp {
+		return reflect.Value{}, nil
+	}
+	return m.Func, nil
+}
+
+// If the pointer type implements any of Marshaler, Unmarshaler or HashRoot
+func HasCustomMarshaling(typ reflect.Type) bool {
+	ptrTyp := reflect.PtrTo(typ)
+	return ptrTyp.Implements(marshalerTyp) || ptrTyp.Implements(unmarshalerTyp) || ptrTyp.Implements(hashRootTyp)
+}
+
+// Passed to the definitions by HashTreeRootChecked, to return errors of custom hash-tree-root methods with.
+// Outside of HashTreeRootChecked, the default definition is used if a custom method errors.
+type CheckedMerkleFn struct {
+	MerkleFn
+}
+
+// Proxies SSZ behavior to the custom methods of the type, where available.
+// The default SSZ definition of the type is used for the other behavior,
+// and for the static length information, dry-checks, fuzzing and pretty printing.
+type SSZMarshaler struct {
+	SSZ
+	// type words of the interfaces holding a pointer to the type, nil if not implemented.
+	marshalerTypeWord   unsafe.Pointer
+	unmarshalerTypeWord unsafe.Pointer
+	hashRootTypeWord    unsafe.Pointer
+	// HashTreeRootWith method function, and the hasher to pass to it, invalid if not used.
+	typ          reflect.Type
+	hashRootWith reflect.Value
+	newHasher    NewHasherFn
+}
+
+func NewSSZMarshaler(factory SSZFactoryFn, typ reflect.Type) (*SSZMarshaler, error) {
+	if !HasCustomMarshaling(typ) {
+		return nil, fmt.Errorf("*typ (pointer type) has no custom SSZ methods")
+	}
+	def, err := DefaultSSZFactory(factory, typ)
+	if err != nil {
+		return nil, fmt.Errorf("type %s with custom SSZ methods has no default SSZ definition: %v", typ.String(), err)
+	}
+	return NewSSZMarshalerWithDefault(typ, def)
+}
+
+// Like NewSSZMarshaler, but with the given default definition of the type, e.g. a fork container.
+func NewSSZMarshalerWithDefault(typ reflect.Type, def SSZ) (*SSZMarshaler, error) {
+	if !HasCustomMarshaling(typ) {
+		return nil, fmt.Errorf("*typ (pointer type) has no custom SSZ methods")
+	}
+	return newSSZMarshaler(typ, def), nil
+}
+
+func newSSZMarshaler(typ reflect.Type, def SSZ) *SSZMarshaler {
+	res := &SSZMarshaler{SSZ: def}
+	ptrTyp := reflect.PtrTo(typ)
+	if ptrTyp.Implements(marshalerTyp) {
+		res.marshalerTypeWord = ifaceTypeWord(marshalerTyp, ptrTyp)
+	}
+	if ptrTyp.Implements(unmarshalerTyp) {
+		res.unmarshalerTypeWord = ifaceTypeWord(unmarshalerTyp, ptrTyp)
+	}
+	if ptrTyp.Implements(hashRootTyp) {
+		res.hashRootTypeWord = ifaceTypeWord(hashRootTyp, ptrTyp)
+	}
+	return res
+}
+
+func (v *SSZMarshaler) SizeOf(p unsafe.Pointer) uint64 {
+	if v.marshalerTypeWord == nil {
+		return v.SSZ.SizeOf(p)
+	}
+	var m Marshaler
+	ptrutil.SetIface(unsafe.Pointer(&m), v.marshalerTypeWord, p)
+	return uint64(m.SizeSSZ())
+}
+
+func (v *SSZMarshaler) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	if v.marshalerTypeWord == nil {
+		return RecordSize(c, v.SSZ, p)
+	}
+	return v.SizeOf(p), nil
+}
+
+func (v *SSZMarshaler) Validate(p unsafe.Pointer) error {
+	return ValidateValue(v.SSZ, p)
+}
+
+func (v *SSZMarshaler) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	if v.marshalerTypeWord == nil {
+		return v.SSZ.Encode(eb, p)
+	}
+	var m Marshaler
+	ptrutil.SetIface(unsafe.Pointer(&m), v.marshalerTypeWord, p)
+	data, err := m.MarshalSSZTo(make([]byte, 0, m.SizeSSZ()))
+	if err != nil {
+		return err
+	}
+	return eb.Write(data)
+}
+
+func (v *SSZMarshaler) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	// fuzzing inputs are not valid SSZ, the default definition handles them.
+	if v.unmarshalerTypeWord == nil || dr.IsFuzzMode() {
+		return v.SSZ.Decode(dr, p)
+	}
+	length := dr.GetBytesSpan()
+	if v.SSZ.IsFixed() {
+		length = v.SSZ.FixedLen()
+	}
+	data := make([]byte, length)
+	if _, err := dr.Read(data); err != nil {
+		return err
+	}
+	var u Unmarshaler
+	ptrutil.SetIface(unsafe.Pointer(&u), v.unmarshalerTypeWord, p)
+	return u.UnmarshalSSZ(data)
+}
+
+func (v *SSZMarshaler) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	var root [32]byte
+	var err error
+	if v.hashRootWith.IsValid() {
+		root, err = v.customHashTreeRootWith(p)
+	} else if v.hashRootTypeWord != nil {
+		var hr HashRoot
+		ptrutil.SetIface(unsafe.Pointer(&hr), v.hashRootTypeWord, p)
+		root, err = hr.HashTreeRoot()
+	} else {
+		return v.SSZ.HashTreeRoot(h, p)
+	}
+	if err != nil {
+		// HashTreeRootChecked recovers the error, to return it
+		if _, checked := h.(CheckedMerkleFn); checked {
+			panic(err)
+		}
+		// the custom methods are a fast path, the default definition is used for values these cannot handle
+		return v.SSZ.HashTreeRoot(h, p)
+	}
+	return root
+}
+
+func (v *SSZMarshaler) customHashTreeRootWith(p unsafe.Pointer) ([32]byte, error) {
+	hasher := v.newHasher()
+	out := v.hashRootWith.Call([]reflect.Value{reflect.NewAt(v.typ, p), reflect.ValueOf(hasher)})
+	if err, _ := out[0].Interface().(error); err != nil {
+		return [32]byte{}, err
+	}
+	return hasher.(HasherRoot).HashRoot()
+}
+
+func (v *SSZMarshaler) DryCheck(dr *DecodingReader) error {
+	return v.SSZ.DryCheck(dr)
+}
+
+func (v *SSZMarshaler) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	v.SSZ.Pretty(indent, w, p)
+}
@@ -0,0 +1,167 @@
+package types
+
+import (
+	"fmt"
+	"github.com/protolambda/zssz/bitfields"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+	"github.com/protolambda/zssz/util/endianness"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"reflect"
+	"unsafe"
+)
+
+var progressiveBitlistMeta = reflect.TypeOf((*bitfields.ProgressiveBitlistMeta)(nil)).Elem()
+
+// Multiplies a and b, but returns max instead if the result is larger than max, or overflows.
+func cappedMul(a uint64, b uint64, max uint64) uint64 {
+	if a != 0 && b > max/a {
+		return max
+	}
+	return a * b
+}
+
+// A list without limit, merkleized with the progressive subtree layout of EIP-7916.
+// The list definition it wraps is used for everything but merkleization.
+type SSZProgressiveList struct {
+	SSZ
+	elemSSZ     SSZ
+	elemMemSize uintptr
+	// if the elements are basic types, packed in chunks
+	isBasic    bool
+	maxLen     uint64
+	fuzzMaxLen uint64
+}
+
+func NewSSZProgressiveList(factory SSZFactoryFn, typ reflect.Type) (*SSZProgressiveList, error) {
+	if !IsProgressiveList(typ) {
+		return nil, fmt.Errorf("typ is not a progressive list")
+	}
+	elemTyp := typ.Elem()
+	res := &SSZProgressiveList{elemMemSize: elemTyp.Size()}
+	switch elemTyp.Kind() {
+	case reflect.Uint8:
+		inner, err := NewSSZBytesWithLimit(typ, PROGRESSIVE_BYTES_LIMIT)
+		if err != nil {
+			return nil, err
+		}
+		res.SSZ, res.elemSSZ, res.isBasic = inner, SSZUint8{}, true
+	case reflect.Bool, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		elemSSZ, err := GetBasicSSZElemType(elemTyp.Kind())
+		if err != nil {
+			return nil, err
+		}
+		inner, err := NewSSZBasicListWithLimit(typ, PROGRESSIVE_BYTES_LIMIT/elemSSZ.FixedLen())
+		if err != nil {
+			return nil, err
+		}
+		res.SSZ, res.elemSSZ, res.isBasic = inner, elemSSZ, true
+	default:
+		if IsUintN(elemTyp) {
+			elemSSZ, err := GetUintNSSZ(elemTyp)
+			if err != nil {
+				return nil, err
+			}
+			inner, err := NewSSZBasicListWithLimit(typ, PROGRESSIVE_BYTES_LIMIT/elemSSZ.FixedLen())
+			if err != nil {
+				return nil, err
+			}
+			res.SSZ, res.elemSSZ, res.isBasic = inner, elemSSZ, true
+		} else {
+			elemSSZ, err := factory(elemTyp)
+			if err != nil {
+				return nil, err
+			}
+			fixedElemSize := uint64(BYTES_PER_LENGTH_OFFSET)
+			if elemSSZ.IsFixed() {
+				fixedElemSize = elemSSZ.FixedLen()
+			}
+			if fixedElemSize == 0 {
+				return nil, fmt.Errorf("cannot create progressive list of 0-length elements")
+			}
+			inner, err := NewSSZListWithLimit(factory, typ, PROGRESSIVE_BYTES_LIMIT/fixedElemSize)
+			if err != nil {
+				return nil, err
+			}
+			res.SSZ, res.elemSSZ = inner, elemSSZ
+		}
+	}
+	limit := PROGRESSIVE_BYTES_LIMIT / BYTES_PER_LENGTH_OFFSET
+	elemMaxLen := res.elemSSZ.MaxLen()
+	if res.elemSSZ.IsFixed() {
+		limit = PROGRESSIVE_BYTES_LIMIT / res.elemSSZ.FixedLen()
+	} else {
+		elemMaxLen += BYTES_PER_LENGTH_OFFSET
+	}
+	res.maxLen = cappedMul(limit, elemMaxLen, PROGRESSIVE_BYTES_LIMIT)
+	res.fuzzMaxLen = 8 + cappedMul(limit, res.elemSSZ.FuzzMaxLen(), PROGRESSIVE_BYTES_LIMIT)
+	return res, nil
+}
+
+func (v *SSZProgressiveList) ElemSSZ() SSZ {
+	return v.elemSSZ
+}
+
+func (v *SSZProgressiveList) MaxLen() uint64 {
+	return v.maxLen
+}
+
+func (v *SSZProgressiveList) FuzzMaxLen() uint64 {
+	return v.fuzzMaxLen
+}
+
+func (v *SSZProgressiveList) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	return RecordSize(c, v.SSZ, p)
+}
+
+func (v *SSZProgressiveList) Validate(p unsafe.Pointer) error {
+	return ValidateValue(v.SSZ, p)
+}
+
+func (v *SSZProgressiveList) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	sh := ptrutil.ReadSliceHeader(p)
+	length := uint64(sh.Len)
+	if v.isBasic {
+		elemSize := v.elemSSZ.FixedLen()
+		bytesLen := length * elemSize
+		var leaf func(i uint64) []byte
+		if endianness.IsLittleEndian || elemSize == 1 {
+			leaf = LittleEndianBasicSeriesLeaf(sh.Data, bytesLen)
+		} else {
+			leaf = BigEndianBasicSeriesLeaf(sh.Data, bytesLen, basicWordSize(elemSize))
+		}
+		return h.MixIn(merkle.MerkleizeProgressive(h, (bytesLen+31)>>5, leaf), length)
+	}
+	elemHtr := v.elemSSZ.HashTreeRoot
+	elemSize := v.elemMemSize
+	leaf := func(i uint64) []byte {
+		r := elemHtr(h, unsafe.Pointer(uintptr(sh.Data)+(elemSize*uintptr(i))))
+		return r[:]
+	}
+	return h.MixIn(merkle.MerkleizeProgressive(h, length, leaf), length)
+}
+
+// A bitlist without limit, merkleized with the progressive subtree layout of EIP-7916.
+type SSZProgressiveBitlist struct {
+	*SSZBitlist
+}
+
+func NewSSZProgressiveBitlist(typ reflect.Type) (*SSZProgressiveBitlist, error) {
+	if !IsProgressiveList(typ) || !reflect.PtrTo(typ).Implements(progressiveBitlistMeta) {
+		return nil, fmt.Errorf("typ is not a progressive bitlist")
+	}
+	// the delimiting bit takes space too, the limit in bits is just short of the full bytes limit.
+	inner, err := NewSSZBitlistWithLimit(typ, (PROGRESSIVE_BYTES_LIMIT-1)<<3)
+	if err != nil {
+		return nil, err
+	}
+	return &SSZProgressiveBitlist{SSZBitlist: inner}, nil
+}
+
+func (v *SSZProgressiveBitlist) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	sh := ptrutil.ReadSliceHeader(p)
+	data := *(*[]byte)(unsafe.Pointer(sh))
+	leaf, leafCount, bitLen := BitlistLeaves(data)
+	return h.MixIn(merkle.MerkleizeProgressive(h, leafCount, leaf), bitLen)
+}
@@ -12,13 +12,32 @@ import (
 	"unsafe"
 )
 
+// The policy for handling nil pointers when encoding, sizing and hashing.
+// Decoding always allocates a new value for nil pointers.
+type NilPtrPolicy byte
+
+const (
+	// Treat a nil pointer as a pointer to the zero value of the element type.
+	NilPtrAsZero NilPtrPolicy = iota
+	// Return an error for nil pointers. SizeOf and HashTreeRoot panic with the error instead.
+	NilPtrAsError
+)
+
 // proxies SSZ behavior to the SSZ type of the object being pointed to.
 type SSZPtr struct {
-	elemSSZ SSZ
-	alloc   ptrutil.AllocationFn
+	elemSSZ   SSZ
+	elemTyp   reflect.Type
+	alloc     ptrutil.AllocationFn
+	nilPolicy NilPtrPolicy
+	// pointer to a zero value of the element type, to handle nil pointers with.
+	zeroPtr unsafe.Pointer
 }
 
 func NewSSZPtr(factory SSZFactoryFn, typ reflect.Type) (*SSZPtr, error) {
+	return NewSSZPtrWithNilPolicy(factory, typ, NilPtrAsZero)
+}
+
+func NewSSZPtrWithNilPolicy(factory SSZFactoryFn, typ reflect.Type, nilPolicy NilPtrPolicy) (*SSZPtr, error) {
 	if typ.Kind() != reflect.Ptr {
 		return nil, fmt.Errorf("typ is not a pointer")
 	}
@@ -30,7 +49,32 @@ func NewSSZPtr(factory SSZFactoryFn, typ reflect.Type) (*SSZPtr, error) {
 	alloc := func(p unsafe.Pointer) unsafe.Pointer {
 		return ptrutil.AllocateSpace(p, elemTyp)
 	}
-	return &SSZPtr{elemSSZ: elemSSZ, alloc: alloc}, nil
+	res := &SSZPtr{elemSSZ: elemSSZ, elemTyp: elemTyp, alloc: alloc, nilPolicy: nilPolicy}
+	if nilPolicy == NilPtrAsZero {
+		res.zeroPtr = unsafe.Pointer(reflect.New(elemTyp).Pointer())
+	}
+	return res, nil
+}
+
+// Factory function that builds pointer definitions that error on nil pointers,
+// and defers to DefaultSSZFactory for all other types.
+func StrictNilPtrFactoryFn(factory SSZFactoryFn, typ reflect.Type) (SSZ, error) {
+	if typ.Kind() == reflect.Ptr {
+		return NewSSZPtrWithNilPolicy(factory, typ, NilPtrAsError)
+	}
+	return DefaultSSZFactory(factory, typ)
+}
+
+// Get the pointer to the contents, handling nil pointers with the nil policy.
+func (v *SSZPtr) innerPtr(p unsafe.Pointer) (unsafe.Pointer, error) {
+	innerPtr := *(*unsafe.Pointer)(p)
+	if innerPtr != nil {
+		return innerPtr, nil
+	}
+	if v.nilPolicy == NilPtrAsZero {
+		return v.zeroPtr, nil
+	}
+	return nil, fmt.Errorf("nil pointer to %s is not allowed", v.elemTyp.String())
 }
 
 func (v *SSZPtr) FuzzMinLen() uint64 {
@@ -58,12 +102,34 @@ func (v *SSZPtr) IsFixed() bool {
 }
 
 func (v *SSZPtr) SizeOf(p unsafe.Pointer) uint64 {
-	innerPtr := unsafe.Pointer(*(*uintptr)(p))
+	innerPtr, err := v.innerPtr(p)
+	if err != nil {
+		panic(err)
+	}
 	return v.elemSSZ.SizeOf(innerPtr)
 }
 
+func (v *SSZPtr) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	innerPtr, err := v.innerPtr(p)
+	if err != nil {
+		return 0, err
+	}
+	return RecordSize(c, v.elemSSZ, innerPtr)
+}
+
+func (v *SSZPtr) Validate(p unsafe.Pointer) error {
+	innerPtr, err := v.innerPtr(p)
+	if err != nil {
+		return err
+	}
+	return ValidateValue(v.elemSSZ, innerPtr)
+}
+
 func (v *SSZPtr) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
-	innerPtr := unsafe.Pointer(*(*uintptr)(p))
+	innerPtr, err := v.innerPtr(p)
+	if err != nil {
+		return err
+	}
 	return v.elemSSZ.Encode(eb, innerPtr)
 }
 
@@ -71,11 +137,11 @@ func (v *SSZPtr) Decode(dr *DecodingReader, p unsafe.Pointer) error {
 	if p == unsafe.Pointer(nil) {
 		return errors.New("cannot decode into nil pointer")
 	}
-	if *(*uintptr)(p) == uintptr(0) {
+	if innerPtr := *(*unsafe.Pointer)(p); innerPtr == nil {
 		contentsPtr := v.alloc(p)
 		return v.elemSSZ.Decode(dr, contentsPtr)
 	} else {
-		return v.elemSSZ.Decode(dr, unsafe.Pointer(*(*uintptr)(p)))
+		return v.elemSSZ.Decode(dr, innerPtr)
 	}
 }
 
@@ -84,12 +150,15 @@ func (v *SSZPtr) DryCheck(dr *DecodingReader) error {
 }
 
 func (v *SSZPtr) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
-	innerPtr := unsafe.Pointer(*(*uintptr)(p))
+	innerPtr, err := v.innerPtr(p)
+	if err != nil {
+		panic(err)
+	}
 	return v.elemSSZ.HashTreeRoot(h, innerPtr)
 }
 
 func (v *SSZPtr) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
-	innerPtr := unsafe.Pointer(*(*uintptr)(p))
+	innerPtr := *(*unsafe.Pointer)(p)
 	if innerPtr == nil {
 		w.WriteIndent(indent)
 		w.Write("null")
@@ -0,0 +1,716 @@
+package types
+
+import (
+	"fmt"
+	"github.com/protolambda/zssz/bitfields"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/stable"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"github.com/protolambda/zssz/util/tags"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+const CAPACITY_KEY = "capacity"
+const PROFILE_FLAG = "profile"
+const REQUIRED_FLAG = "required"
+
+var stableContainerMarker = reflect.TypeOf(stable.Container{})
+
+// Checks if the struct type is a stable container: the first field is a stable.Container marker.
+func IsStableContainer(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && typ.NumField() > 0 && typ.Field(0).Type == stableContainerMarker
+}
+
+// Checks if the struct type is a profile: the first field is a zero-length array of the base type, tagged as profile.
+func IsProfile(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ.NumField() == 0 {
+		return false
+	}
+	marker := typ.Field(0)
+	return marker.Type.Kind() == reflect.Array && marker.Type.Len() == 0 && tags.HasFlag(&marker, SSZ_TAG, PROFILE_FLAG)
+}
+
+type StableField struct {
+	// SSZ of the field contents, not the pointer to it.
+	ssz  SSZ
+	name string
+	// index of the field in the stable container, for merkleization
+	index uint64
+	// bit in the serialized active fields prefix, -1 if the field is always present
+	prefixBit int
+	// if the Go field is a pointer to the contents
+	isPtr bool
+	ptrFn FieldPtrFn
+	alloc ptrutil.AllocationFn
+	// zero contents, for nil pointers of required fields
+	zeroPtr unsafe.Pointer
+}
+
+// Get the pointer to the contents of the field, if present
+func (f *StableField) contents(p unsafe.Pointer) (present bool, contentsPtr unsafe.Pointer) {
+	fieldPtr := f.ptrFn(p)
+	if !f.isPtr {
+		return true, fieldPtr
+	}
+	if innerPtr := *(*unsafe.Pointer)(fieldPtr); innerPtr != nil {
+		return true, innerPtr
+	}
+	if f.prefixBit < 0 {
+		return true, f.zeroPtr
+	}
+	return false, nil
+}
+
+// Prepare the field for decoding: allocate contents if present, clear the pointer if absent.
+func (f *StableField) prepare(p unsafe.Pointer, present bool) unsafe.Pointer {
+	fieldPtr := f.ptrFn(p)
+	if !f.isPtr {
+		return fieldPtr
+	}
+	if !present {
+		*(*unsafe.Pointer)(fieldPtr) = nil
+		return nil
+	}
+	if innerPtr := *(*unsafe.Pointer)(fieldPtr); innerPtr != nil {
+		return innerPtr
+	}
+	return f.alloc(fieldPtr)
+}
+
+// Shared implementation of EIP-7495 stable containers and profiles.
+// Serialized as an active fields bitvector prefix (if any optional fields), followed by the present fields,
+// like a container. Merkleized as the fields merkleized to capacity, mixed with the active fields root.
+type SSZStableContainer struct {
+	Fields   []StableField
+	capacity uint64
+	// maps index in the stable container to index in Fields, or -1 if no such field
+	fieldByIndex []int
+	prefixBits   uint64
+	prefixLen    uint64
+	// mask of prefix bits that correspond to fields
+	knownPrefix []byte
+	isFixedLen  bool
+	fixedLen    uint64
+	minLen      uint64
+	maxLen      uint64
+	fuzzMinLen  uint64
+	fuzzMaxLen  uint64
+}
+
+// A profile of a stable container, restricting it to a subset of required and optional fields.
+type SSZProfile struct {
+	SSZStableContainer
+}
+
+func newStableField(factory SSZFactoryFn, sField *reflect.StructField, isPtr bool) (StableField, error) {
+	// stable containers evolve with optional fields and a fixed capacity, not with forks
+	if IsForkSpecific(sField) {
+		return StableField{}, fmt.Errorf("stable container field %s cannot be fork-specific", sField.Name)
+	}
+	contentsTyp := sField.Type
+	if isPtr {
+		contentsTyp = contentsTyp.Elem()
+	}
+	contentsSSZ, err := GetFieldSSZ(factory, sField, contentsTyp)
+	if err != nil {
+		return StableField{}, err
+	}
+	return StableField{
+		ssz:   contentsSSZ,
+		name:  sField.Name,
+		isPtr: isPtr,
+		ptrFn: GetOffsetPtrFn(sField.Offset),
+		alloc: func(p unsafe.Pointer) unsafe.Pointer {
+			return ptrutil.AllocateSpace(p, contentsTyp)
+		},
+	}, nil
+}
+
+func NewSSZStableContainer(factory SSZFactoryFn, typ reflect.Type) (*SSZStableContainer, error) {
+	if !IsStableContainer(typ) {
+		return nil, fmt.Errorf("typ is not a struct with a stable container marker as first field")
+	}
+	marker := typ.Field(0)
+	capacityStr, ok := tags.GetValue(&marker, SSZ_TAG, CAPACITY_KEY)
+	if !ok {
+		return nil, fmt.Errorf("stable container marker is missing a capacity tag")
+	}
+	capacity, err := strconv.ParseUint(capacityStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("stable container capacity '%s' is invalid: %v", capacityStr, err)
+	}
+	if capacity == 0 {
+		return nil, fmt.Errorf("stable container capacity must not be 0")
+	}
+	var fields []StableField
+	for i, c := 1, typ.NumField(); i < c; i++ {
+		sField := typ.Field(i)
+		if tags.HasFlag(&sField, SSZ_TAG, OMIT_FLAG) {
+			continue
+		}
+		if sField.Type.Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("stable container field %s must be a pointer (optional field)", sField.Name)
+		}
+		field, err := newStableField(factory, &sField, true)
+		if err != nil {
+			return nil, err
+		}
+		field.index = uint64(len(fields))
+		field.prefixBit = int(field.index)
+		fields = append(fields, field)
+	}
+	if uint64(len(fields)) > capacity {
+		return nil, fmt.Errorf("stable container has %d fields, exceeding capacity %d", len(fields), capacity)
+	}
+	return initStableContainer(fields, capacity, capacity)
+}
+
+func NewSSZProfile(factory SSZFactoryFn, typ reflect.Type) (*SSZProfile, error) {
+	if !IsProfile(typ) {
+		return nil, fmt.Errorf("typ is not a struct with a profile marker as first field")
+	}
+	baseTyp := typ.Field(0).Type.Elem()
+	base, err := NewSSZStableContainer(factory, baseTyp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile base: %v", err)
+	}
+	var fields []StableField
+	optionalCount := 0
+	for i, c := 1, typ.NumField(); i < c; i++ {
+		sField := typ.Field(i)
+		if tags.HasFlag(&sField, SSZ_TAG, OMIT_FLAG) {
+			continue
+		}
+		if IsForkSpecific(&sField) {
+			return nil, fmt.Errorf("profile field %s cannot be fork-specific", sField.Name)
+		}
+		var baseField *StableField
+		for j := range base.Fields {
+			if base.Fields[j].name == sField.Name {
+				baseField = &base.Fields[j]
+				break
+			}
+		}
+		if baseField == nil {
+			return nil, fmt.Errorf("profile field %s is not a field of base %s", sField.Name, baseTyp.String())
+		}
+		if len(fields) > 0 && fields[len(fields)-1].index >= baseField.index {
+			return nil, fmt.Errorf("profile field %s is not in the same order as in base %s", sField.Name, baseTyp.String())
+		}
+		baseGoField, _ := baseTyp.FieldByName(sField.Name)
+		field := *baseField
+		field.ptrFn = GetOffsetPtrFn(sField.Offset)
+		switch sField.Type {
+		case baseGoField.Type:
+			if tags.HasFlag(&sField, SSZ_TAG, REQUIRED_FLAG) {
+				field.prefixBit = -1
+				field.zeroPtr = unsafe.Pointer(reflect.New(baseGoField.Type.Elem()).Pointer())
+			} else {
+				field.prefixBit = optionalCount
+				optionalCount++
+			}
+		case baseGoField.Type.Elem():
+			field.isPtr = false
+			field.prefixBit = -1
+		default:
+			return nil, fmt.Errorf("profile field %s has type %s, incompatible with base type %s",
+				sField.Name, sField.Type.String(), baseGoField.Type.String())
+		}
+		fields = append(fields, field)
+	}
+	res, err := initStableContainer(fields, base.capacity, uint64(optionalCount))
+	if err != nil {
+		return nil, err
+	}
+	return &SSZProfile{SSZStableContainer: *res}, nil
+}
+
+func initStableContainer(fields []StableField, capacity uint64, prefixBits uint64) (*SSZStableContainer, error) {
+	res := &SSZStableContainer{
+		Fields:     fields,
+		capacity:   capacity,
+		prefixBits: prefixBits,
+		prefixLen:  (prefixBits + 7) >> 3,
+	}
+	res.knownPrefix = make([]byte, res.prefixLen)
+	if len(fields) > 0 {
+		res.fieldByIndex = make([]int, fields[len(fields)-1].index+1)
+		for i := range res.fieldByIndex {
+			res.fieldByIndex[i] = -1
+		}
+	}
+	res.fixedLen = res.prefixLen
+	res.minLen = res.prefixLen
+	res.maxLen = res.prefixLen
+	res.fuzzMinLen = res.prefixLen
+	res.fuzzMaxLen = res.prefixLen
+	res.isFixedLen = res.prefixLen == 0
+	for i := range fields {
+		f := &fields[i]
+		res.fieldByIndex[f.index] = i
+		if f.prefixBit >= 0 {
+			bitfields.SetBit(res.knownPrefix, uint64(f.prefixBit), true)
+		}
+		var fixed, min, max uint64
+		if f.ssz.IsFixed() {
+			fixed, min, max = f.ssz.FixedLen(), f.ssz.MinLen(), f.ssz.MaxLen()
+			if fixed != min || fixed != max {
+				return nil, fmt.Errorf("fixed-size field ('%s') in stable container has invalid min/max length", f.name)
+			}
+		} else {
+			fixed = BYTES_PER_LENGTH_OFFSET
+			min = BYTES_PER_LENGTH_OFFSET + f.ssz.MinLen()
+			max = BYTES_PER_LENGTH_OFFSET + f.ssz.MaxLen()
+			res.isFixedLen = false
+		}
+		// optional fields may be absent, and do not count towards the fixed or minimum length
+		if f.prefixBit < 0 {
+			res.fixedLen += fixed
+			res.minLen += min
+		}
+		res.maxLen += max
+		res.fuzzMinLen += f.ssz.FuzzMinLen()
+		res.fuzzMaxLen += f.ssz.FuzzMaxLen()
+	}
+	return res, nil
+}
+
+func (v *SSZStableContainer) FuzzMinLen() uint64 {
+	return v.fuzzMinLen
+}
+
+func (v *SSZStableContainer) FuzzMaxLen() uint64 {
+	return v.fuzzMaxLen
+}
+
+func (v *SSZStableContainer) MinLen() uint64 {
+	return v.minLen
+}
+
+func (v *SSZStableContainer) MaxLen() uint64 {
+	return v.maxLen
+}
+
+// The length of the fixed-size part, with only the required fields present.
+func (v *SSZStableContainer) FixedLen() uint64 {
+	return v.fixedLen
+}
+
+func (v *SSZStableContainer) IsFixed() bool {
+	return v.isFixedLen
+}
+
+func (v *SSZStableContainer) SizeOf(p unsafe.Pointer) uint64 {
+	out := v.prefixLen
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if present, contentsPtr := f.contents(p); present {
+			if f.ssz.IsFixed() {
+				out += f.ssz.FixedLen()
+			} else {
+				out += BYTES_PER_LENGTH_OFFSET + f.ssz.SizeOf(contentsPtr)
+			}
+		}
+	}
+	return out
+}
+
+func (v *SSZStableContainer) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	recordCount := 0
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if present, _ := f.contents(p); present && recordsSizes(f.ssz) {
+			recordCount++
+		}
+	}
+	j := c.Reserve(recordCount)
+	out := v.prefixLen
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		present, contentsPtr := f.contents(p)
+		if !present {
+			continue
+		}
+		if f.ssz.IsFixed() {
+			out += f.ssz.FixedLen()
+			continue
+		}
+		if !recordsSizes(f.ssz) {
+			out += BYTES_PER_LENGTH_OFFSET + f.ssz.SizeOf(contentsPtr)
+			continue
+		}
+		size, err := f.ssz.(SizeRecorder).RecordSize(c, contentsPtr)
+		if err != nil {
+			return 0, err
+		}
+		c.Set(j, contentsPtr, f.ssz, size)
+		j++
+		out += BYTES_PER_LENGTH_OFFSET + size
+	}
+	return out, nil
+}
+
+func (v *SSZStableContainer) Validate(p unsafe.Pointer) error {
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if present, contentsPtr := f.contents(p); present {
+			if err := ValidateValue(f.ssz, contentsPtr); err != nil {
+				return wrapPath(f.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *SSZStableContainer) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	// write the active fields prefix, and get the size of the fixed part of the present fields.
+	fixedSize := uint64(0)
+	if v.prefixLen > 0 {
+		prefix := make([]byte, v.prefixLen)
+		for i := range v.Fields {
+			f := &v.Fields[i]
+			if present, _ := f.contents(p); present {
+				if f.prefixBit >= 0 {
+					bitfields.SetBit(prefix, uint64(f.prefixBit), true)
+				}
+			}
+		}
+		if err := eb.Write(prefix); err != nil {
+			return err
+		}
+	}
+	if eb.Patching() {
+		return v.encodePatched(eb, p)
+	}
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if present, _ := f.contents(p); present {
+			if f.ssz.IsFixed() {
+				fixedSize += f.ssz.FixedLen()
+			} else {
+				fixedSize += BYTES_PER_LENGTH_OFFSET
+			}
+		}
+	}
+	// the previous offset, to calculate a new offset from, starting after the fixed data.
+	prevOffset := fixedSize
+	// span of the previous var-size element
+	prevSize := uint64(0)
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		present, contentsPtr := f.contents(p)
+		if !present {
+			continue
+		}
+		if f.ssz.IsFixed() {
+			if err := f.ssz.Encode(eb, contentsPtr); err != nil {
+				return err
+			}
+		} else {
+			if offset, err := eb.WriteOffset(prevOffset, prevSize); err != nil {
+				return err
+			} else {
+				prevOffset = offset
+			}
+			prevSize = EncodedSizeOf(eb, f.ssz, contentsPtr)
+		}
+	}
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if f.ssz.IsFixed() {
+			continue
+		}
+		if present, contentsPtr := f.contents(p); present {
+			if err := f.ssz.Encode(eb, contentsPtr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Encode the present fields after the prefix, with offset placeholders,
+// and patch each offset before writing the dynamic part it points to.
+func (v *SSZStableContainer) encodePatched(eb *EncodingWriter, p unsafe.Pointer) error {
+	start := eb.Written()
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		present, contentsPtr := f.contents(p)
+		if !present {
+			continue
+		}
+		if f.ssz.IsFixed() {
+			if err := f.ssz.Encode(eb, contentsPtr); err != nil {
+				return err
+			}
+		} else if err := eb.WriteOffsetPlaceholders(1); err != nil {
+			return err
+		}
+	}
+	// position of the offset of the next var-size field
+	pos := start
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		present, contentsPtr := f.contents(p)
+		if !present {
+			continue
+		}
+		if f.ssz.IsFixed() {
+			pos += int(f.ssz.FixedLen())
+			continue
+		}
+		if err := eb.PatchOffset(pos, start); err != nil {
+			return err
+		}
+		pos += BYTES_PER_LENGTH_OFFSET
+		if err := f.ssz.Encode(eb, contentsPtr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the active fields prefix, and returns which fields are present.
+func (v *SSZStableContainer) readPrefix(dr *DecodingReader) ([]bool, error) {
+	active := make([]bool, len(v.Fields))
+	prefix := make([]byte, v.prefixLen)
+	if v.prefixLen > 0 {
+		if _, err := dr.Read(prefix); err != nil {
+			return nil, err
+		}
+		if dr.IsFuzzMode() {
+			// just make it correct where necessary
+			for i := range prefix {
+				prefix[i] &= v.knownPrefix[i]
+			}
+		} else {
+			for i := range prefix {
+				if unknown := prefix[i] &^ v.knownPrefix[i]; unknown != 0 {
+					return nil, fmt.Errorf("active fields prefix byte %d has bits 0b%08b that do not correspond to a field", i, unknown)
+				}
+			}
+		}
+	}
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		active[i] = f.prefixBit < 0 || bitfields.GetBit(prefix, uint64(f.prefixBit))
+	}
+	return active, nil
+}
+
+func (v *SSZStableContainer) decodeFuzzmode(dr *DecodingReader, p unsafe.Pointer) error {
+	active, err := v.readPrefix(dr)
+	if err != nil {
+		return err
+	}
+	lengthLeftOver := uint64(0)
+	for i := range v.Fields {
+		if active[i] {
+			lengthLeftOver += v.Fields[i].ssz.FuzzMinLen()
+		}
+	}
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		contentsPtr := f.prepare(p, active[i])
+		if !active[i] {
+			continue
+		}
+		lengthLeftOver -= f.ssz.FuzzMinLen()
+		span := dr.GetBytesSpan()
+		if span < lengthLeftOver {
+			return fmt.Errorf("under estimated length requirements for fuzzing input, not enough data available to fuzz")
+		}
+		available := span - lengthLeftOver
+
+		scoped, err := dr.Scope(available)
+		if err != nil {
+			return err
+		}
+		scoped.EnableFuzzMode()
+		if err := f.ssz.Decode(scoped, contentsPtr); err != nil {
+			return err
+		}
+		dr.UpdateIndexFromScoped(scoped)
+	}
+	return nil
+}
+
+// Processes the present fields after the prefix: the fixed part (incl. offsets) and the dynamic part.
+func (v *SSZStableContainer) processFields(dr *DecodingReader, active []bool, fieldHandler func(dr *DecodingReader, i int) error) error {
+	if v.isFixedLen {
+		// no offsets, no need to scope the contents
+		for i := range v.Fields {
+			if err := fieldHandler(dr, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// offsets are relative to the start of the fields, after the prefix.
+	body, err := dr.Scope(dr.GetBytesSpan())
+	if err != nil {
+		return err
+	}
+	fixedSize := uint64(0)
+	for i := range v.Fields {
+		if !active[i] {
+			continue
+		}
+		if f := &v.Fields[i]; f.ssz.IsFixed() {
+			fixedSize += f.ssz.FixedLen()
+		} else {
+			fixedSize += BYTES_PER_LENGTH_OFFSET
+		}
+	}
+	if fixedSize > body.Max() {
+		return fmt.Errorf("stable container fixed part of %d bytes does not fit in %d bytes", fixedSize, body.Max())
+	}
+	var offsets []uint64
+	var dynFields []int
+	for i := range v.Fields {
+		if !active[i] {
+			continue
+		}
+		f := &v.Fields[i]
+		if f.ssz.IsFixed() {
+			if err := fieldHandler(body, i); err != nil {
+				return err
+			}
+		} else {
+			offset, err := body.ReadOffset()
+			if err != nil {
+				return err
+			}
+			offsets = append(offsets, offset)
+			dynFields = append(dynFields, i)
+		}
+	}
+	if pivotIndex := body.Index(); pivotIndex != fixedSize {
+		return fmt.Errorf("expected to read to %d bytes for fixed part of stable container, got to %d", fixedSize, pivotIndex)
+	}
+	for j, i := range dynFields {
+		currentOffset := offsets[j]
+		if realOffset := body.Index(); currentOffset != realOffset {
+			return fmt.Errorf("expected to be at %d bytes, but currently at %d", currentOffset, realOffset)
+		}
+		// calculate the scope based on next offset, and max. value of this scope for the last value
+		var scope uint64
+		if next := j + 1; next < len(offsets) {
+			if nextOffset := offsets[next]; nextOffset >= currentOffset {
+				scope = nextOffset - currentOffset
+			} else {
+				return fmt.Errorf("offset %d for field %s is invalid", j, v.Fields[i].name)
+			}
+		} else {
+			scope = body.Max() - currentOffset
+		}
+		scoped, err := body.Scope(scope)
+		if err != nil {
+			return err
+		}
+		if err := fieldHandler(scoped, i); err != nil {
+			return err
+		}
+		body.UpdateIndexFromScoped(scoped)
+	}
+	if i, m := body.Index(), body.Max(); i != m {
+		return fmt.Errorf("expected to finish reading the stable container scope to max %d, got to %d", m, i)
+	}
+	dr.UpdateIndexFromScoped(body)
+	return nil
+}
+
+func (v *SSZStableContainer) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	if dr.IsFuzzMode() {
+		return v.decodeFuzzmode(dr, p)
+	}
+	active, err := v.readPrefix(dr)
+	if err != nil {
+		return err
+	}
+	contents := make([]unsafe.Pointer, len(v.Fields))
+	for i := range v.Fields {
+		contents[i] = v.Fields[i].prepare(p, active[i])
+	}
+	return v.processFields(dr, active, func(dr *DecodingReader, i int) error {
+		return v.Fields[i].ssz.Decode(dr, contents[i])
+	})
+}
+
+func (v *SSZStableContainer) DryCheck(dr *DecodingReader) error {
+	active, err := v.readPrefix(dr)
+	if err != nil {
+		return err
+	}
+	return v.processFields(dr, active, func(dr *DecodingReader, i int) error {
+		return v.Fields[i].ssz.DryCheck(dr)
+	})
+}
+
+func (v *SSZStableContainer) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	activeFields := make([]byte, (v.capacity+7)>>3)
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		if present, _ := f.contents(p); present {
+			bitfields.SetBit(activeFields, f.index, true)
+		}
+	}
+	leaf := func(i uint64) []byte {
+		if fi := v.fieldByIndex[i]; fi >= 0 {
+			f := &v.Fields[fi]
+			if present, contentsPtr := f.contents(p); present {
+				r := f.ssz.HashTreeRoot(h, contentsPtr)
+				return r[:]
+			}
+		}
+		return ZeroHashes[0][:]
+	}
+	fieldsRoot := merkle.Merkleize(h, uint64(len(v.fieldByIndex)), v.capacity, leaf)
+
+	// the active fields are hashed like a Bitvector[capacity]
+	byteLen := uint64(len(activeFields))
+	activeLeaf := func(i uint64) []byte {
+		s := i << 5
+		e := (i + 1) << 5
+		// pad the data
+		if e > byteLen {
+			x := [32]byte{}
+			copy(x[:], activeFields[s:byteLen])
+			return x[:]
+		}
+		return activeFields[s:e]
+	}
+	leafCount := (byteLen + 31) >> 5
+	activeRoot := merkle.Merkleize(h, leafCount, leafCount, activeLeaf)
+	return h.Combi(fieldsRoot, activeRoot)
+}
+
+func (v *SSZStableContainer) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	w.WriteIndent(indent)
+	w.Write("{\n")
+	for i := range v.Fields {
+		f := &v.Fields[i]
+		w.WriteIndent(indent + 1)
+		w.Write(f.name)
+		w.Write(":\n")
+		if present, contentsPtr := f.contents(p); present {
+			f.ssz.Pretty(indent+3, w, contentsPtr)
+		} else {
+			w.WriteIndent(indent + 3)
+			w.Write("null")
+		}
+		if i == len(v.Fields)-1 {
+			w.Write("\n")
+		} else {
+			w.Write(",\n")
+		}
+	}
+	w.WriteIndent(indent)
+	w.Write("}")
+}
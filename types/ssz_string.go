@@ -0,0 +1,332 @@
+package types
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/merkle"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"reflect"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Get the longest valid UTF-8 prefix of the data, used to make fuzzing inputs valid.
+func validUTF8Prefix(data []byte) []byte {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return data[:i]
+		}
+		i += size
+	}
+	return data
+}
+
+// Strings are checked for valid UTF-8 in chunks of this size when dry-checking, instead of all at once.
+const utf8CheckChunkSize = 1 << 10
+
+// Check that the next length bytes are valid UTF-8, without allocating for all of them.
+// A rune that is split over two chunks is carried over to the next chunk.
+func dryCheckUTF8(dr *DecodingReader, length uint64) error {
+	var buf [utf8CheckChunkSize + utf8.UTFMax]byte
+	carry := 0
+	for length > 0 {
+		n := uint64(utf8CheckChunkSize)
+		if n > length {
+			n = length
+		}
+		data := buf[:carry+int(n)]
+		if _, err := dr.Read(data[carry:]); err != nil {
+			return err
+		}
+		length -= n
+		i := 0
+		for i < len(data) {
+			// the rest of an incomplete rune is in the next chunk
+			if length > 0 && !utf8.FullRune(data[i:]) {
+				break
+			}
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size <= 1 {
+				return fmt.Errorf("string is not valid UTF-8")
+			}
+			i += size
+		}
+		carry = copy(buf[:], data[i:])
+	}
+	return nil
+}
+
+// A UTF-8 string, encoded and merkleized like a bytes list.
+type SSZString struct {
+	limit uint64
+}
+
+func NewSSZString(typ reflect.Type) (*SSZString, error) {
+	limit, err := ReadListLimit(typ)
+	if err != nil {
+		return nil, fmt.Errorf("string requires a limit: %v", err)
+	}
+	return NewSSZStringWithLimit(typ, limit)
+}
+
+func NewSSZStringWithLimit(typ reflect.Type, limit uint64) (*SSZString, error) {
+	if typ.Kind() != reflect.String {
+		return nil, fmt.Errorf("typ is not a string")
+	}
+	return &SSZString{limit: limit}, nil
+}
+
+func (v *SSZString) FuzzMinLen() uint64 {
+	return 8
+}
+
+func (v *SSZString) FuzzMaxLen() uint64 {
+	return 8 + v.limit
+}
+
+func (v *SSZString) MinLen() uint64 {
+	return 0
+}
+
+func (v *SSZString) MaxLen() uint64 {
+	return v.limit
+}
+
+func (v *SSZString) FixedLen() uint64 {
+	return 0
+}
+
+func (v *SSZString) IsFixed() bool {
+	return false
+}
+
+func (v *SSZString) SizeOf(p unsafe.Pointer) uint64 {
+	return uint64(ptrutil.ReadStringHeader(p).Len)
+}
+
+func (v *SSZString) Validate(p unsafe.Pointer) error {
+	data := ptrutil.ReadStringBytes(p)
+	if length := uint64(len(data)); length > v.limit {
+		return fmt.Errorf("got %d bytes, expected no more than %d bytes", length, v.limit)
+	}
+	if !utf8.Valid(data) {
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	return nil
+}
+
+func (v *SSZString) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	data := ptrutil.ReadStringBytes(p)
+	if uint64(len(data)) > v.limit {
+		return fmt.Errorf("cannot encode string of %d bytes, limit is %d", len(data), v.limit)
+	}
+	return eb.Write(data)
+}
+
+func (v *SSZString) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	var length uint64
+	if dr.IsFuzzMode() {
+		x, err := dr.ReadUint64()
+		if err != nil {
+			return err
+		}
+		span := dr.GetBytesSpan()
+		if span > v.limit {
+			span = v.limit
+		}
+		if span != 0 {
+			length = x % span
+		}
+	} else {
+		length = dr.GetBytesSpan()
+	}
+	if length > v.limit {
+		return fmt.Errorf("got %d bytes, expected no more than %d bytes", length, v.limit)
+	}
+	data := make([]byte, length)
+	if _, err := dr.Read(data); err != nil {
+		return err
+	}
+	if dr.IsFuzzMode() {
+		data = validUTF8Prefix(data)
+	} else if !utf8.Valid(data) {
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	ptrutil.SetStringBytes(p, data)
+	return nil
+}
+
+func (v *SSZString) DryCheck(dr *DecodingReader) error {
+	length := dr.GetBytesSpan()
+	if length > v.limit {
+		return fmt.Errorf("got %d bytes, expected no more than %d bytes", length, v.limit)
+	}
+	return dryCheckUTF8(dr, length)
+}
+
+func (v *SSZString) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	data := ptrutil.ReadStringBytes(p)
+	dataLen := uint64(len(data))
+	checkMerkleLimit(dataLen, v.limit)
+	leafCount := (dataLen + 31) >> 5
+	leafLimit := (v.limit + 31) >> 5
+	leaf := func(i uint64) []byte {
+		s := i << 5
+		e := (i + 1) << 5
+		// pad the data
+		if e > dataLen {
+			x := [32]byte{}
+			copy(x[:], data[s:dataLen])
+			return x[:]
+		}
+		return data[s:e]
+	}
+	return h.MixIn(merkle.Merkleize(h, leafCount, leafLimit, leaf), dataLen)
+}
+
+func (v *SSZString) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	w.WriteIndent(indent)
+	w.Write(fmt.Sprintf("%q", *(*string)(p)))
+}
+
+// A UTF-8 string of fixed length, encoded and merkleized like a bytes vector.
+// Shorter strings are padded with zero bytes, trailing zero bytes are removed when decoding.
+// Strings that end with a zero byte are invalid, as these would not decode to the same string.
+type SSZStringN struct {
+	length uint64
+}
+
+func NewSSZStringN(typ reflect.Type, length uint64) (*SSZStringN, error) {
+	if typ.Kind() != reflect.String {
+		return nil, fmt.Errorf("typ is not a string")
+	}
+	if length == 0 {
+		return nil, fmt.Errorf("fixed-length string must have a non-zero length")
+	}
+	return &SSZStringN{length: length}, nil
+}
+
+// Get the string bytes, padded to the fixed length.
+func (v *SSZStringN) padded(p unsafe.Pointer) ([]byte, error) {
+	data := ptrutil.ReadStringBytes(p)
+	if uint64(len(data)) > v.length {
+		return nil, fmt.Errorf("string of %d bytes is longer than fixed length %d", len(data), v.length)
+	}
+	if len(data) > 0 && data[len(data)-1] == 0 {
+		return nil, fmt.Errorf("string ends with a zero byte, which is removed as padding when decoding")
+	}
+	out := make([]byte, v.length)
+	copy(out, data)
+	return out, nil
+}
+
+// Remove the zero padding
+func (v *SSZStringN) unpadded(data []byte) []byte {
+	end := len(data)
+	for end > 0 && data[end-1] == 0 {
+		end--
+	}
+	return data[:end]
+}
+
+func (v *SSZStringN) FuzzMinLen() uint64 {
+	return v.length
+}
+
+func (v *SSZStringN) FuzzMaxLen() uint64 {
+	return v.length
+}
+
+func (v *SSZStringN) MinLen() uint64 {
+	return v.length
+}
+
+func (v *SSZStringN) MaxLen() uint64 {
+	return v.length
+}
+
+func (v *SSZStringN) FixedLen() uint64 {
+	return v.length
+}
+
+func (v *SSZStringN) IsFixed() bool {
+	return true
+}
+
+func (v *SSZStringN) SizeOf(p unsafe.Pointer) uint64 {
+	return v.length
+}
+
+func (v *SSZStringN) Validate(p unsafe.Pointer) error {
+	data, err := v.padded(p)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(v.unpadded(data)) {
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	return nil
+}
+
+func (v *SSZStringN) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	data, err := v.padded(p)
+	if err != nil {
+		return err
+	}
+	return eb.Write(data)
+}
+
+func (v *SSZStringN) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	data := make([]byte, v.length)
+	if _, err := dr.Read(data); err != nil {
+		return err
+	}
+	data = v.unpadded(data)
+	if dr.IsFuzzMode() {
+		data = validUTF8Prefix(data)
+	} else if !utf8.Valid(data) {
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	ptrutil.SetStringBytes(p, data)
+	return nil
+}
+
+func (v *SSZStringN) DryCheck(dr *DecodingReader) error {
+	data := make([]byte, v.length)
+	if _, err := dr.Read(data); err != nil {
+		return err
+	}
+	if !utf8.Valid(v.unpadded(data)) {
+		return fmt.Errorf("string is not valid UTF-8")
+	}
+	return nil
+}
+
+func (v *SSZStringN) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	data, err := v.padded(p)
+	if err != nil {
+		panic(err)
+	}
+	leafCount := (v.length + 31) >> 5
+	leaf := func(i uint64) []byte {
+		s := i << 5
+		e := (i + 1) << 5
+		// pad the data
+		if e > v.length {
+			x := [32]byte{}
+			copy(x[:], data[s:v.length])
+			return x[:]
+		}
+		return data[s:e]
+	}
+	return merkle.Merkleize(h, leafCount, leafCount, leaf)
+}
+
+func (v *SSZStringN) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	w.WriteIndent(indent)
+	w.Write(fmt.Sprintf("%q", *(*string)(p)))
+}
@@ -25,6 +25,7 @@ type Change struct {
 	Path        string
 	Mode        ChangeMode
 	Description string
+	Impact      ChangeImpact
 }
 
 type SSZFuzzInfo interface {
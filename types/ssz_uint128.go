@@ -0,0 +1,85 @@
+package types
+
+import (
+	"encoding/binary"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/uints"
+	"unsafe"
+)
+
+// Memory is expected to be 2 uint64 limbs, least significant limb first.
+type SSZUint128 struct{}
+
+func (t SSZUint128) FuzzMinLen() uint64 {
+	return 16
+}
+
+func (t SSZUint128) FuzzMaxLen() uint64 {
+	return 16
+}
+
+func (t SSZUint128) MinLen() uint64 {
+	return 16
+}
+
+func (t SSZUint128) MaxLen() uint64 {
+	return 16
+}
+
+func (t SSZUint128) FixedLen() uint64 {
+	return 16
+}
+
+func (t SSZUint128) IsFixed() bool {
+	return true
+}
+
+func (t SSZUint128) SizeOf(p unsafe.Pointer) uint64 {
+	return 16
+}
+
+func (t SSZUint128) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
+func (t SSZUint128) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	limbs := (*[2]uint64)(p)
+	for i := 0; i < 2; i++ {
+		binary.LittleEndian.PutUint64(eb.Scratch[i<<3:(i+1)<<3], limbs[i])
+	}
+	return eb.Write(eb.Scratch[0:16])
+}
+
+func (t SSZUint128) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	limbs := (*[2]uint64)(p)
+	for i := 0; i < 2; i++ {
+		v, err := dr.ReadUint64()
+		if err != nil {
+			return err
+		}
+		limbs[i] = v
+	}
+	return nil
+}
+
+func (t SSZUint128) DryCheck(dr *DecodingReader) error {
+	_, err := dr.Skip(16)
+	return err
+}
+
+func (t SSZUint128) HashTreeRoot(h MerkleFn, p unsafe.Pointer) (out [32]byte) {
+	limbs := (*[2]uint64)(p)
+	for i := 0; i < 2; i++ {
+		binary.LittleEndian.PutUint64(out[i<<3:(i+1)<<3], limbs[i])
+	}
+	return
+}
+
+func (t SSZUint128) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	w.WriteIndent(indent)
+	limbs := (*[2]uint64)(p)
+	w.Write(uints.LimbsToBig(limbs[:]).String())
+}
@@ -40,6 +40,10 @@ func (t SSZUint16) SizeOf(p unsafe.Pointer) uint64 {
 	return 2
 }
 
+func (t SSZUint16) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
 func (t SSZUint16) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	binary.LittleEndian.PutUint16(eb.Scratch[0:2], *(*uint16)(p))
 	return eb.Write(eb.Scratch[0:2])
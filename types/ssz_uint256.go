@@ -0,0 +1,85 @@
+package types
+
+import (
+	"encoding/binary"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/uints"
+	"unsafe"
+)
+
+// Memory is expected to be 4 uint64 limbs, least significant limb first.
+type SSZUint256 struct{}
+
+func (t SSZUint256) FuzzMinLen() uint64 {
+	return 32
+}
+
+func (t SSZUint256) FuzzMaxLen() uint64 {
+	return 32
+}
+
+func (t SSZUint256) MinLen() uint64 {
+	return 32
+}
+
+func (t SSZUint256) MaxLen() uint64 {
+	return 32
+}
+
+func (t SSZUint256) FixedLen() uint64 {
+	return 32
+}
+
+func (t SSZUint256) IsFixed() bool {
+	return true
+}
+
+func (t SSZUint256) SizeOf(p unsafe.Pointer) uint64 {
+	return 32
+}
+
+func (t SSZUint256) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
+func (t SSZUint256) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	limbs := (*[4]uint64)(p)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(eb.Scratch[i<<3:(i+1)<<3], limbs[i])
+	}
+	return eb.Write(eb.Scratch[0:32])
+}
+
+func (t SSZUint256) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	limbs := (*[4]uint64)(p)
+	for i := 0; i < 4; i++ {
+		v, err := dr.ReadUint64()
+		if err != nil {
+			return err
+		}
+		limbs[i] = v
+	}
+	return nil
+}
+
+func (t SSZUint256) DryCheck(dr *DecodingReader) error {
+	_, err := dr.Skip(32)
+	return err
+}
+
+func (t SSZUint256) HashTreeRoot(h MerkleFn, p unsafe.Pointer) (out [32]byte) {
+	limbs := (*[4]uint64)(p)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(out[i<<3:(i+1)<<3], limbs[i])
+	}
+	return
+}
+
+func (t SSZUint256) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	w.WriteIndent(indent)
+	limbs := (*[4]uint64)(p)
+	w.Write(uints.LimbsToBig(limbs[:]).String())
+}
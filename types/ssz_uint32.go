@@ -40,6 +40,10 @@ func (t SSZUint32) SizeOf(p unsafe.Pointer) uint64 {
 	return 4
 }
 
+func (t SSZUint32) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
 func (t SSZUint32) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	binary.LittleEndian.PutUint32(eb.Scratch[0:4], *(*uint32)(p))
 	return eb.Write(eb.Scratch[0:4])
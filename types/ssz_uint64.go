@@ -40,6 +40,10 @@ func (t SSZUint64) SizeOf(p unsafe.Pointer) uint64 {
 	return 8
 }
 
+func (t SSZUint64) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
 func (t SSZUint64) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	binary.LittleEndian.PutUint64(eb.Scratch[0:8], *(*uint64)(p))
 	return eb.Write(eb.Scratch[0:8])
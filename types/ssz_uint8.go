@@ -39,6 +39,10 @@ func (t SSZUint8) SizeOf(p unsafe.Pointer) uint64 {
 	return 1
 }
 
+func (t SSZUint8) Validate(p unsafe.Pointer) error {
+	return nil
+}
+
 func (t SSZUint8) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	return eb.WriteByte(*(*byte)(p))
 }
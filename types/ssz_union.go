@@ -0,0 +1,486 @@
+package types
+
+import (
+	"fmt"
+	. "github.com/protolambda/zssz/dec"
+	. "github.com/protolambda/zssz/enc"
+	. "github.com/protolambda/zssz/htr"
+	. "github.com/protolambda/zssz/pretty"
+	"github.com/protolambda/zssz/unions"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"reflect"
+	"unsafe"
+)
+
+// The maximum amount of union options, the selector is limited to 127.
+const MAX_UNION_OPTIONS = 128
+
+var unionMeta = reflect.TypeOf((*unions.UnionMeta)(nil)).Elem()
+
+// Get the type word that an interface of the given type has when holding a value of the value type.
+func ifaceTypeWord(ifaceTyp reflect.Type, valueTyp reflect.Type) unsafe.Pointer {
+	holder := reflect.New(ifaceTyp)
+	holder.Elem().Set(reflect.Zero(valueTyp))
+	return ptrutil.ReadIfaceType(unsafe.Pointer(holder.Pointer()))
+}
+
+type unionOption struct {
+	// nil for the None option
+	ssz SSZ
+	// the Go type of the option value (not the pointer to it)
+	typ reflect.Type
+	// the interface type word, when holding a pointer to the option value
+	typeWord unsafe.Pointer
+}
+
+// The options of a union, shared between union structs and interface unions.
+type unionOptions struct {
+	options    []unionOption
+	minLen     uint64
+	maxLen     uint64
+	fuzzMinLen uint64
+	fuzzMaxLen uint64
+}
+
+// Build the union options, for union values of the given interface type.
+func newUnionOptions(factory SSZFactoryFn, valueTyp reflect.Type, optionPtrs []interface{}) (unionOptions, error) {
+	if len(optionPtrs) == 0 {
+		return unionOptions{}, fmt.Errorf("union must have at least one option")
+	}
+	if len(optionPtrs) > MAX_UNION_OPTIONS {
+		return unionOptions{}, fmt.Errorf("union has %d options, expected no more than %d", len(optionPtrs), MAX_UNION_OPTIONS)
+	}
+	res := unionOptions{
+		options: make([]unionOption, len(optionPtrs)),
+		minLen:  ^uint64(0),
+	}
+	for i, optPtr := range optionPtrs {
+		if optPtr == nil {
+			if i != 0 {
+				return unionOptions{}, fmt.Errorf("union option %d is None, only allowed at selector 0", i)
+			}
+			if len(optionPtrs) == 1 {
+				return unionOptions{}, fmt.Errorf("union with None as only option is not allowed")
+			}
+			res.minLen = 0
+			continue
+		}
+		optPtrTyp := reflect.TypeOf(optPtr)
+		if optPtrTyp.Kind() != reflect.Ptr {
+			return unionOptions{}, fmt.Errorf("union option %d is not a typed pointer, but %s", i, optPtrTyp.String())
+		}
+		if !optPtrTyp.Implements(valueTyp) {
+			return unionOptions{}, fmt.Errorf("union option %d type %s does not fit in union value type %s",
+				i, optPtrTyp.String(), valueTyp.String())
+		}
+		optTyp := optPtrTyp.Elem()
+		optSSZ, err := factory(optTyp)
+		if err != nil {
+			return unionOptions{}, err
+		}
+		typeWord := ifaceTypeWord(valueTyp, optPtrTyp)
+		for j := 0; j < i; j++ {
+			if res.options[j].typeWord == typeWord {
+				return unionOptions{}, fmt.Errorf("union option %d has the same type as option %d", i, j)
+			}
+		}
+
+		res.options[i] = unionOption{ssz: optSSZ, typ: optTyp, typeWord: typeWord}
+		if min := optSSZ.MinLen(); min < res.minLen {
+			res.minLen = min
+		}
+		if max := optSSZ.MaxLen(); max > res.maxLen {
+			res.maxLen = max
+		}
+		if fuzzMin := optSSZ.FuzzMinLen(); fuzzMin > res.fuzzMinLen {
+			res.fuzzMinLen = fuzzMin
+		}
+		if fuzzMax := optSSZ.FuzzMaxLen(); fuzzMax > res.fuzzMaxLen {
+			res.fuzzMaxLen = fuzzMax
+		}
+	}
+	// account for the selector byte
+	res.minLen += 1
+	res.maxLen += 1
+	res.fuzzMinLen += 1
+	res.fuzzMaxLen += 1
+	return res, nil
+}
+
+// Get the option of the selector, and the pointer to the contents of the option value in the interface.
+// Errors if the selector is invalid, or does not match the value.
+func (v *unionOptions) checkValue(selector uint8, valuePtr unsafe.Pointer) (opt *unionOption, contentsPtr unsafe.Pointer, err error) {
+	if uint64(selector) >= uint64(len(v.options)) {
+		return nil, nil, fmt.Errorf("union selector %d is invalid, union has %d options", selector, len(v.options))
+	}
+	opt = &v.options[selector]
+	if opt.ssz == nil {
+		if ptrutil.ReadIfaceData(valuePtr) != nil {
+			return nil, nil, fmt.Errorf("union selector %d is None, but value is not nil", selector)
+		}
+		return opt, nil, nil
+	}
+	if ptrutil.ReadIfaceType(valuePtr) != opt.typeWord {
+		return nil, nil, fmt.Errorf("union value does not match selected option %d of type %s", selector, opt.typ.String())
+	}
+	contentsPtr = ptrutil.ReadIfaceData(valuePtr)
+	if contentsPtr == nil {
+		return nil, nil, fmt.Errorf("union value of selected option %d is a nil pointer", selector)
+	}
+	return opt, contentsPtr, nil
+}
+
+func (v *unionOptions) FuzzMinLen() uint64 {
+	return v.fuzzMinLen
+}
+
+func (v *unionOptions) FuzzMaxLen() uint64 {
+	return v.fuzzMaxLen
+}
+
+func (v *unionOptions) MinLen() uint64 {
+	return v.minLen
+}
+
+func (v *unionOptions) MaxLen() uint64 {
+	return v.maxLen
+}
+
+func (v *unionOptions) FixedLen() uint64 {
+	return 0
+}
+
+func (v *unionOptions) IsFixed() bool {
+	return false
+}
+
+func (v *unionOptions) sizeOf(opt *unionOption, contentsPtr unsafe.Pointer) uint64 {
+	if opt.ssz == nil {
+		return 1
+	}
+	return 1 + opt.ssz.SizeOf(contentsPtr)
+}
+
+func (v *unionOptions) recordSize(c *SizeCache, opt *unionOption, contentsPtr unsafe.Pointer) (uint64, error) {
+	if opt.ssz == nil {
+		return 1, nil
+	}
+	size, err := RecordSize(c, opt.ssz, contentsPtr)
+	return 1 + size, err
+}
+
+func (v *unionOptions) encode(eb *EncodingWriter, selector uint8, opt *unionOption, contentsPtr unsafe.Pointer) error {
+	if err := eb.WriteByte(selector); err != nil {
+		return err
+	}
+	if opt.ssz == nil {
+		return nil
+	}
+	return opt.ssz.Encode(eb, contentsPtr)
+}
+
+// Read the selector, and decode the selected option into the interface value
+func (v *unionOptions) decode(dr *DecodingReader, valuePtr unsafe.Pointer) (uint8, error) {
+	selector, err := dr.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(selector) >= uint64(len(v.options)) {
+		if dr.IsFuzzMode() {
+			selector = uint8(uint64(selector) % uint64(len(v.options)))
+		} else {
+			return 0, fmt.Errorf("union selector %d is invalid, union has %d options", selector, len(v.options))
+		}
+	}
+	opt := &v.options[selector]
+	if opt.ssz == nil {
+		ptrutil.ClearIface(valuePtr)
+		if !dr.IsFuzzMode() {
+			if span := dr.GetBytesSpan(); span != 0 {
+				return 0, fmt.Errorf("union None value must be empty, but got %d bytes", span)
+			}
+		}
+		return selector, nil
+	}
+	var contentsPtr unsafe.Pointer
+	if ptrutil.ReadIfaceType(valuePtr) == opt.typeWord && ptrutil.ReadIfaceData(valuePtr) != nil {
+		// re-use the existing value if it has the right type already
+		contentsPtr = ptrutil.ReadIfaceData(valuePtr)
+	} else {
+		contentsPtr = ptrutil.AllocateIfaceSpace(valuePtr, opt.typeWord, opt.typ)
+	}
+	scoped, err := dr.Scope(dr.GetBytesSpan())
+	if err != nil {
+		return 0, err
+	}
+	if dr.IsFuzzMode() {
+		scoped.EnableFuzzMode()
+	} else if opt.ssz.IsFixed() && scoped.Max() != opt.ssz.FixedLen() {
+		return 0, fmt.Errorf("union option %d is fixed-size %d bytes, but got %d bytes", selector, opt.ssz.FixedLen(), scoped.Max())
+	}
+	if err := opt.ssz.Decode(scoped, contentsPtr); err != nil {
+		return 0, err
+	}
+	dr.UpdateIndexFromScoped(scoped)
+	return selector, nil
+}
+
+func (v *unionOptions) DryCheck(dr *DecodingReader) error {
+	selector, err := dr.ReadByte()
+	if err != nil {
+		return err
+	}
+	if uint64(selector) >= uint64(len(v.options)) {
+		return fmt.Errorf("union selector %d is invalid, union has %d options", selector, len(v.options))
+	}
+	opt := &v.options[selector]
+	span := dr.GetBytesSpan()
+	if opt.ssz == nil {
+		if span != 0 {
+			return fmt.Errorf("union None value must be empty, but got %d bytes", span)
+		}
+		return nil
+	}
+	if opt.ssz.IsFixed() && span != opt.ssz.FixedLen() {
+		return fmt.Errorf("union option %d is fixed-size %d bytes, but got %d bytes", selector, opt.ssz.FixedLen(), span)
+	}
+	scoped, err := dr.Scope(span)
+	if err != nil {
+		return err
+	}
+	if err := opt.ssz.DryCheck(scoped); err != nil {
+		return err
+	}
+	dr.UpdateIndexFromScoped(scoped)
+	return nil
+}
+
+func (v *unionOptions) hashTreeRoot(h MerkleFn, selector uint8, opt *unionOption, contentsPtr unsafe.Pointer) [32]byte {
+	// mix_in_selector: the None option has a zero root.
+	var root [32]byte
+	if opt.ssz != nil {
+		root = opt.ssz.HashTreeRoot(h, contentsPtr)
+	}
+	return h.MixIn(root, uint64(selector))
+}
+
+func (v *unionOptions) pretty(indent uint32, w *PrettyWriter, selector uint8, opt *unionOption, contentsPtr unsafe.Pointer, err error) {
+	w.WriteIndent(indent)
+	w.Write("{\n")
+	w.WriteIndent(indent + 1)
+	w.Write(fmt.Sprintf("selector: %d,\n", selector))
+	if err == nil && opt.ssz != nil {
+		w.WriteIndent(indent + 1)
+		w.Write(fmt.Sprintf("type: %s,\n", opt.typ.String()))
+	}
+	w.WriteIndent(indent + 1)
+	w.Write("value:\n")
+	if err != nil {
+		w.WriteIndent(indent + 3)
+		w.Write(fmt.Sprintf("invalid (%v)", err))
+	} else if opt.ssz == nil {
+		w.WriteIndent(indent + 3)
+		w.Write("null")
+	} else {
+		opt.ssz.Pretty(indent+3, w, contentsPtr)
+	}
+	w.Write("\n")
+	w.WriteIndent(indent)
+	w.Write("}")
+}
+
+// A union struct, with a selector and an interface value, see unions.Union
+type SSZUnion struct {
+	unionOptions
+	selectorOffset uintptr
+	valueOffset    uintptr
+}
+
+func NewSSZUnion(factory SSZFactoryFn, typ reflect.Type) (*SSZUnion, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("typ is not a struct (union requirement)")
+	}
+	ptrTyp := reflect.PtrTo(typ)
+	if !ptrTyp.Implements(unionMeta) {
+		return nil, fmt.Errorf("*typ (pointer type) is not a union")
+	}
+	if typ.NumField() != 2 {
+		return nil, fmt.Errorf("union struct must have 2 fields: a selector and a value")
+	}
+	selectorField, valueField := typ.Field(0), typ.Field(1)
+	if selectorField.Type.Kind() != reflect.Uint8 {
+		return nil, fmt.Errorf("union selector field %s is not a uint8", selectorField.Name)
+	}
+	if valueField.Type.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("union value field %s is not an interface", valueField.Name)
+	}
+	typedNil := reflect.New(ptrTyp).Elem().Interface().(unions.UnionMeta)
+	options, err := newUnionOptions(factory, valueField.Type, typedNil.UnionOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &SSZUnion{
+		unionOptions:   options,
+		selectorOffset: selectorField.Offset,
+		valueOffset:    valueField.Offset,
+	}, nil
+}
+
+// Get the selected option, and the pointer to the contents of the option value.
+// Errors if the selector is invalid, or does not match the value.
+func (v *SSZUnion) selected(p unsafe.Pointer) (selector uint8, opt *unionOption, contentsPtr unsafe.Pointer, err error) {
+	selector = *(*uint8)(unsafe.Pointer(uintptr(p) + v.selectorOffset))
+	opt, contentsPtr, err = v.checkValue(selector, unsafe.Pointer(uintptr(p)+v.valueOffset))
+	return
+}
+
+func (v *SSZUnion) SizeOf(p unsafe.Pointer) uint64 {
+	_, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		panic(err)
+	}
+	return v.sizeOf(opt, contentsPtr)
+}
+
+func (v *SSZUnion) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	_, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		return 0, err
+	}
+	return v.recordSize(c, opt, contentsPtr)
+}
+
+func (v *SSZUnion) Validate(p unsafe.Pointer) error {
+	_, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		return err
+	}
+	if opt.ssz == nil {
+		return nil
+	}
+	return ValidateValue(opt.ssz, contentsPtr)
+}
+
+func (v *SSZUnion) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	selector, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		return err
+	}
+	return v.encode(eb, selector, opt, contentsPtr)
+}
+
+func (v *SSZUnion) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	selector, err := v.decode(dr, unsafe.Pointer(uintptr(p)+v.valueOffset))
+	if err != nil {
+		return err
+	}
+	*(*uint8)(unsafe.Pointer(uintptr(p) + v.selectorOffset)) = selector
+	return nil
+}
+
+func (v *SSZUnion) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	selector, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		panic(err)
+	}
+	return v.hashTreeRoot(h, selector, opt, contentsPtr)
+}
+
+func (v *SSZUnion) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	selector, opt, contentsPtr, err := v.selected(p)
+	v.pretty(indent, w, selector, opt, contentsPtr, err)
+}
+
+// An interface value, encoded as union. The selector is determined by the type of the value,
+// with the options registered for the interface type, see unions.RegisterInterface
+type SSZIfaceUnion struct {
+	unionOptions
+}
+
+func NewSSZIfaceUnion(factory SSZFactoryFn, typ reflect.Type) (*SSZIfaceUnion, error) {
+	if typ.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("typ is not an interface")
+	}
+	optionPtrs, ok := unions.GetInterfaceOptions(typ)
+	if !ok {
+		return nil, fmt.Errorf("interface type %s has no registered union options", typ.String())
+	}
+	options, err := newUnionOptions(factory, typ, optionPtrs)
+	if err != nil {
+		return nil, err
+	}
+	return &SSZIfaceUnion{unionOptions: options}, nil
+}
+
+// Get the selector of the interface value, the selected option, and the pointer to the contents of the value.
+// Errors if the type of the value is not one of the options.
+func (v *SSZIfaceUnion) selected(p unsafe.Pointer) (selector uint8, opt *unionOption, contentsPtr unsafe.Pointer, err error) {
+	typeWord := ptrutil.ReadIfaceType(p)
+	if typeWord == nil {
+		if v.options[0].ssz == nil {
+			return 0, &v.options[0], nil, nil
+		}
+		return 0, nil, nil, fmt.Errorf("nil interface value, but union has no None option")
+	}
+	for i := range v.options {
+		if v.options[i].ssz != nil && v.options[i].typeWord == typeWord {
+			selector = uint8(i)
+			opt, contentsPtr, err = v.checkValue(selector, p)
+			return
+		}
+	}
+	return 0, nil, nil, fmt.Errorf("interface value type is not a registered union option")
+}
+
+func (v *SSZIfaceUnion) SizeOf(p unsafe.Pointer) uint64 {
+	_, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		panic(err)
+	}
+	return v.sizeOf(opt, contentsPtr)
+}
+
+func (v *SSZIfaceUnion) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	_, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		return 0, err
+	}
+	return v.recordSize(c, opt, contentsPtr)
+}
+
+func (v *SSZIfaceUnion) Validate(p unsafe.Pointer) error {
+	_, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		return err
+	}
+	if opt.ssz == nil {
+		return nil
+	}
+	return ValidateValue(opt.ssz, contentsPtr)
+}
+
+func (v *SSZIfaceUnion) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
+	selector, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		return err
+	}
+	return v.encode(eb, selector, opt, contentsPtr)
+}
+
+func (v *SSZIfaceUnion) Decode(dr *DecodingReader, p unsafe.Pointer) error {
+	_, err := v.decode(dr, p)
+	return err
+}
+
+func (v *SSZIfaceUnion) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
+	selector, opt, contentsPtr, err := v.selected(p)
+	if err != nil {
+		panic(err)
+	}
+	return v.hashTreeRoot(h, selector, opt, contentsPtr)
+}
+
+func (v *SSZIfaceUnion) Pretty(indent uint32, w *PrettyWriter, p unsafe.Pointer) {
+	selector, opt, contentsPtr, err := v.selected(p)
+	v.pretty(indent, w, selector, opt, contentsPtr, err)
+}
@@ -101,11 +101,25 @@ func (v *SSZVector) SizeOf(p unsafe.Pointer) uint64 {
 	}
 }
 
+func (v *SSZVector) RecordSize(c *SizeCache, p unsafe.Pointer) (uint64, error) {
+	if v.IsFixed() {
+		return v.fixedLen, nil
+	}
+	return recordVarSeriesSizes(c, v.elemSSZ, v.length, v.elemMemSize, p)
+}
+
+func (v *SSZVector) Validate(p unsafe.Pointer) error {
+	return validateSeries(v.elemSSZ, v.length, v.elemMemSize, p)
+}
+
 func (v *SSZVector) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	if v.IsFixed() {
+		if encodesParallel(eb, v.length) {
+			return encodeParallelSeries(v.elemSSZ, v.length, v.elemMemSize, eb, p)
+		}
 		return EncodeFixedSeries(v.elemSSZ.Encode, v.length, v.elemMemSize, eb, p)
 	} else {
-		return EncodeVarSeries(v.elemSSZ.Encode, v.elemSSZ.SizeOf, v.length, v.elemMemSize, eb, p)
+		return encodeRecordedVarSeries(v.elemSSZ, v.length, v.elemMemSize, eb, p)
 	}
 }
 
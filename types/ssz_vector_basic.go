@@ -24,7 +24,7 @@ func NewSSZBasicVector(typ reflect.Type) (*SSZBasicVector, error) {
 	}
 	elemTyp := typ.Elem()
 	elemKind := elemTyp.Kind()
-	elemSSZ, err := GetBasicSSZElemType(elemKind)
+	elemSSZ, err := GetBasicSSZElemTypeOf(elemTyp)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +70,13 @@ func (v *SSZBasicVector) SizeOf(p unsafe.Pointer) uint64 {
 	return v.byteLen
 }
 
+func (v *SSZBasicVector) Validate(p unsafe.Pointer) error {
+	if v.elemKind == reflect.Bool {
+		return validateBools(v.length, p)
+	}
+	return nil
+}
+
 func (v *SSZBasicVector) Encode(eb *EncodingWriter, p unsafe.Pointer) error {
 	// we can just write the data as-is in a few contexts:
 	// - if we're in a little endian architecture
@@ -97,7 +104,7 @@ func (v *SSZBasicVector) HashTreeRoot(h MerkleFn, p unsafe.Pointer) [32]byte {
 	if endianness.IsLittleEndian || v.elemSSZ.FixedLen() == 1 {
 		return LittleEndianBasicSeriesHTR(h, p, v.byteLen, v.byteLen)
 	} else {
-		return BigEndianBasicSeriesHTR(h, p, v.byteLen, v.byteLen, uint8(v.elemSSZ.FixedLen()))
+		return BigEndianBasicSeriesHTR(h, p, v.byteLen, v.byteLen, basicWordSize(v.elemSSZ.FixedLen()))
 	}
 }
 
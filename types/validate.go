@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// SSZ definitions can check values in memory, for what encoding or hashing would otherwise fail on,
+// or what a peer would reject: lists over their limit, invalid bitfields, bools other than 0 or 1, nil pointers, etc.
+type Validator interface {
+	Validate(p unsafe.Pointer) error
+}
+
+// Validate the value, if the definition is a Validator.
+func ValidateValue(ssz SSZ, p unsafe.Pointer) error {
+	if v, ok := ssz.(Validator); ok {
+		return v.Validate(p)
+	}
+	return nil
+}
+
+// An error of an invalid value, with the path to the value, e.g. "Body.Attestations[3].AggregationBits".
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Prefix the path of the error with the given path, to annotate an error of a value in a field or element.
+func wrapPath(prefix string, err error) error {
+	if err == nil {
+		return nil
+	}
+	e, ok := err.(*ValidationError)
+	if !ok {
+		return &ValidationError{Path: prefix, Err: err}
+	}
+	switch {
+	case e.Path == "":
+		return &ValidationError{Path: prefix, Err: e.Err}
+	case e.Path[0] == '[':
+		return &ValidationError{Path: prefix + e.Path, Err: e.Err}
+	default:
+		return &ValidationError{Path: prefix + "." + e.Path, Err: e.Err}
+	}
+}
+
+// pointer must point to start of the series contents
+func validateSeries(elemSSZ SSZ, length uint64, elemMemSize uintptr, p unsafe.Pointer) error {
+	if _, ok := elemSSZ.(Validator); !ok {
+		return nil
+	}
+	memOffset := uintptr(0)
+	for i := uint64(0); i < length; i++ {
+		elemPtr := unsafe.Pointer(uintptr(p) + memOffset)
+		memOffset += elemMemSize
+		if err := ValidateValue(elemSSZ, elemPtr); err != nil {
+			return wrapPath(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+	return nil
+}
+
+// pointer must point to start of the bools
+func validateBools(length uint64, p unsafe.Pointer) error {
+	for i := uint64(0); i < length; i++ {
+		if b := *(*byte)(unsafe.Pointer(uintptr(p) + uintptr(i))); b > 1 {
+			return wrapPath(fmt.Sprintf("[%d]", i), fmt.Errorf("bool value %d is invalid", b))
+		}
+	}
+	return nil
+}
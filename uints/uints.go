@@ -0,0 +1,64 @@
+package uints
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Types implementing this with a pointer-receiver are recognized as SSZ uint128 or uint256.
+// The Go type must be an array of uint64 limbs, least significant limb first:
+// [2]uint64 for 16 bytes (uint128), [4]uint64 for 32 bytes (uint256).
+type UintMeta interface {
+	// Length of the uint in bytes, 16 or 32
+	UintByteLen() uint64
+}
+
+type Uint128 [2]uint64
+
+func (*Uint128) UintByteLen() uint64 { return 16 }
+
+func (v *Uint128) Big() *big.Int { return LimbsToBig(v[:]) }
+
+func (v *Uint128) SetBig(x *big.Int) error { return BigToLimbs(x, v[:]) }
+
+func (v Uint128) String() string { return LimbsToBig(v[:]).String() }
+
+type Uint256 [4]uint64
+
+func (*Uint256) UintByteLen() uint64 { return 32 }
+
+func (v *Uint256) Big() *big.Int { return LimbsToBig(v[:]) }
+
+func (v *Uint256) SetBig(x *big.Int) error { return BigToLimbs(x, v[:]) }
+
+func (v Uint256) String() string { return LimbsToBig(v[:]).String() }
+
+// Converts uint64 limbs, least significant limb first, to a big integer.
+func LimbsToBig(limbs []uint64) *big.Int {
+	out := new(big.Int)
+	tmp := new(big.Int)
+	for i := len(limbs) - 1; i >= 0; i-- {
+		out.Lsh(out, 64)
+		out.Or(out, tmp.SetUint64(limbs[i]))
+	}
+	return out
+}
+
+// Converts a big integer to uint64 limbs, least significant limb first.
+// Errors if the integer is negative, or does not fit in the limbs.
+func BigToLimbs(x *big.Int, limbs []uint64) error {
+	if x.Sign() < 0 {
+		return fmt.Errorf("cannot convert negative integer %s to uint", x.String())
+	}
+	if bitLen := x.BitLen(); bitLen > len(limbs)*64 {
+		return fmt.Errorf("integer of %d bits does not fit in %d bits uint", bitLen, len(limbs)*64)
+	}
+	tmp := new(big.Int).Set(x)
+	mask := new(big.Int).SetUint64(^uint64(0))
+	word := new(big.Int)
+	for i := range limbs {
+		limbs[i] = word.And(tmp, mask).Uint64()
+		tmp.Rsh(tmp, 64)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package unions
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	ifaceOptionsLock sync.RWMutex
+	ifaceOptions     = make(map[reflect.Type][]interface{})
+)
+
+// Register the union options of an interface type, to encode fields of the interface type as union.
+// The interface type is given as typed nil pointer, e.g. (*MyInterface)(nil).
+// The options are listed like UnionOptions: typed nil pointers that implement the interface, indexed by selector.
+// An untyped nil option is the None option (a nil interface value), and is only allowed at selector 0.
+//
+// Registration is expected to happen before the SSZ definitions of types using the interface are built,
+// e.g. in an init function. Panics if the interface type is invalid, or if it was registered already.
+func RegisterInterface(ifacePtr interface{}, options ...interface{}) {
+	ptrTyp := reflect.TypeOf(ifacePtr)
+	if ptrTyp == nil || ptrTyp.Kind() != reflect.Ptr || ptrTyp.Elem().Kind() != reflect.Interface {
+		panic(fmt.Errorf("expected typed nil pointer to interface type, got %v", ptrTyp))
+	}
+	ifaceTyp := ptrTyp.Elem()
+	ifaceOptionsLock.Lock()
+	defer ifaceOptionsLock.Unlock()
+	if _, ok := ifaceOptions[ifaceTyp]; ok {
+		panic(fmt.Errorf("interface type %s is registered already", ifaceTyp.String()))
+	}
+	ifaceOptions[ifaceTyp] = append([]interface{}(nil), options...)
+}
+
+// Get the union options registered for the interface type, if any.
+func GetInterfaceOptions(ifaceTyp reflect.Type) (options []interface{}, ok bool) {
+	ifaceOptionsLock.RLock()
+	defer ifaceOptionsLock.RUnlock()
+	options, ok = ifaceOptions[ifaceTyp]
+	return
+}
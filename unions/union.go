@@ -0,0 +1,26 @@
+package unions
+
+// For structs to be a valid SSZ union, they need to list the types of the union options.
+// The options are returned as typed nil pointers, e.g. (*Foo)(nil), indexed by selector.
+// An untyped nil option is the None option, and is only allowed at selector 0.
+//
+// The struct itself is expected to have the memory layout of Union:
+// a uint8 selector, followed by an interface value, holding a pointer to the selected option value.
+type UnionMeta interface {
+	UnionOptions() []interface{}
+}
+
+// The memory layout of a SSZ union. Declare union types as a named type of this,
+// and add a pointer-receiver UnionOptions method to it. Example:
+//
+//	type MyUnion unions.Union
+//
+//	func (*MyUnion) UnionOptions() []interface{} {
+//		return []interface{}{nil, (*Foo)(nil), (*Bar)(nil)}
+//	}
+//
+// The Value is nil for the None option, and a *Foo for selector 1, a *Bar for selector 2.
+type Union struct {
+	Selector uint8
+	Value    interface{}
+}
@@ -73,3 +73,16 @@ func AllocateSpace(p unsafe.Pointer, typ reflect.Type) unsafe.Pointer {
 	runtime.KeepAlive(&v)
 	return ptr
 }
+
+// Allocates space for a value of the given type, and binds a pointer to it to the interface at p.
+// The type word must be the type word of the interface when holding a pointer of the given type (i.e. *typ).
+// Returns a pointer to the contents. The allocated space is zeroed out.
+func AllocateIfaceSpace(p unsafe.Pointer, typeWord unsafe.Pointer, typ reflect.Type) unsafe.Pointer {
+	v := reflect.New(typ)
+	ptr := unsafe.Pointer(v.Pointer())
+	x := (*iface)(p)
+	x.Type = typeWord
+	x.Data = ptr
+	runtime.KeepAlive(&v)
+	return ptr
+}
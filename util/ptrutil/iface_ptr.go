@@ -10,3 +10,29 @@ func IfacePtrToPtr(val *interface{}) unsafe.Pointer {
 	p := unsafe.Pointer(val)
 	return (*iface)(p).Data
 }
+
+// Reads the type word of the interface at the given pointer.
+// For empty interfaces this is the dynamic type, for non-empty interfaces the itab.
+func ReadIfaceType(p unsafe.Pointer) unsafe.Pointer {
+	return (*iface)(p).Type
+}
+
+// Reads the data word of the interface at the given pointer.
+// For pointer values in an interface, this is the pointer itself.
+func ReadIfaceData(p unsafe.Pointer) unsafe.Pointer {
+	return (*iface)(p).Data
+}
+
+// Sets the interface at the given pointer to nil
+func ClearIface(p unsafe.Pointer) {
+	x := (*iface)(p)
+	x.Type = nil
+	x.Data = nil
+}
+
+// Sets the interface at the given pointer to hold the given data word, with the given type word.
+func SetIface(p unsafe.Pointer, typeWord unsafe.Pointer, data unsafe.Pointer) {
+	x := (*iface)(p)
+	x.Type = typeWord
+	x.Data = data
+}
@@ -0,0 +1,27 @@
+package ptrutil
+
+import (
+	"unsafe"
+)
+
+type StringHeader struct {
+	Data unsafe.Pointer
+	Len  int
+}
+
+func ReadStringHeader(p unsafe.Pointer) *StringHeader {
+	return (*StringHeader)(p)
+}
+
+// Get the bytes of the string at p, without copying. The bytes must not be modified.
+func ReadStringBytes(p unsafe.Pointer) []byte {
+	sh := ReadStringHeader(p)
+	return *(*[]byte)(unsafe.Pointer(GetSliceHeader(sh.Data, uint64(sh.Len))))
+}
+
+// Set the string at p to the given bytes, without copying. The bytes must not be modified afterwards.
+func SetStringBytes(p unsafe.Pointer, data []byte) {
+	sh := ReadStringHeader(p)
+	sh.Data = ReadSliceHeader(unsafe.Pointer(&data)).Data
+	sh.Len = len(data)
+}
@@ -1,7 +1,9 @@
 package tags
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -32,3 +34,59 @@ func HasFlag(vt *reflect.StructField, namespace string, flag string) bool {
 
 	return false
 }
+
+// Get the value of a key=value entry in the tag, e.g. `ssz:"capacity=16"` has value "16" for key "capacity"
+func GetValue(vt *reflect.StructField, namespace string, key string) (value string, ok bool) {
+	if key == "" {
+		return "", false
+	}
+	tag, ok := vt.Tag.Lookup(namespace)
+	if !ok {
+		return "", false
+	}
+
+	// look through the tag to find the key (comma separated)
+	for tag != "" {
+		var next string
+		i := strings.Index(tag, ",")
+		if i >= 0 {
+			tag, next = tag[:i], tag[i+1:]
+		}
+		if j := strings.Index(tag, "="); j >= 0 && tag[:j] == key {
+			return tag[j+1:], true
+		}
+		tag = next
+	}
+
+	return "", false
+}
+
+// A value for a single dimension of a nested type, as declared in a tag with comma separated values.
+// Undefined dimensions are declared with "?".
+type Dimension struct {
+	Value   uint64
+	Defined bool
+}
+
+// Get the per-dimension values of the tag, outer dimension first. E.g. `ssz-size:"?,48"`.
+// Returns nil if the tag is not present.
+func GetDimensions(vt *reflect.StructField, namespace string) ([]Dimension, error) {
+	tag, ok := vt.Tag.Lookup(namespace)
+	if !ok || len(tag) == 0 {
+		return nil, nil
+	}
+	parts := strings.Split(tag, ",")
+	out := make([]Dimension, len(parts))
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "?" {
+			continue
+		}
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s of field %s has invalid dimension %d: '%s'", namespace, vt.Name, i, part)
+		}
+		out[i] = Dimension{Value: v, Defined: true}
+	}
+	return out, nil
+}
@@ -11,6 +11,8 @@ import (
 	"io"
 	"reflect"
 	"runtime"
+	"sync"
+	"unsafe"
 )
 
 const VERSION = "v0.1.5"
@@ -76,6 +78,16 @@ func DecodeFuzzBytes(r io.Reader, bytesLen uint64, val interface{}, sszTyp SSZ)
 	return dr.Index(), nil
 }
 
+// Checks the value, before encoding or hashing it. Errors are annotated with the path to the invalid value,
+// see ValidationError. Custom SSZ definitions can implement Validator to be checked too.
+func Validate(val interface{}, sszTyp SSZ) error {
+	p := ptrutil.IfacePtrToPtr(&val)
+	err := ValidateValue(sszTyp, p)
+	// make sure the data of the object is kept around up to this point.
+	runtime.KeepAlive(&val)
+	return err
+}
+
 func SizeOf(val interface{}, sszTyp SSZ) uint64 {
 	p := ptrutil.IfacePtrToPtr(&val)
 	out := sszTyp.SizeOf(p)
@@ -84,10 +96,39 @@ func SizeOf(val interface{}, sszTyp SSZ) uint64 {
 	return out
 }
 
+var sizeCachePool = sync.Pool{
+	New: func() interface{} {
+		return new(SizeCache)
+	},
+}
+
+// Encodes the value to the writer. Variable-size values are sized once, before encoding,
+// to write offsets with, instead of sizing nested values at every level of nesting.
 func Encode(w io.Writer, val interface{}, sszTyp SSZ) (n int, err error) {
+	return encode(NewEncodingWriter(w), val, sszTyp)
+}
+
+// Encodes the value to the writer, like Encode, but with large lists and vectors encoded with multiple goroutines.
+// The output is the same as Encode.
+func EncodeParallel(w io.Writer, val interface{}, sszTyp SSZ, cfg ParallelConfig) (n int, err error) {
 	ew := NewEncodingWriter(w)
+	ew.Parallel = &cfg
+	return encode(ew, val, sszTyp)
+}
 
+func encode(ew *EncodingWriter, val interface{}, sszTyp SSZ) (n int, err error) {
 	p := ptrutil.IfacePtrToPtr(&val)
+	if r, ok := sszTyp.(SizeRecorder); ok && !sszTyp.IsFixed() {
+		sizes := sizeCachePool.Get().(*SizeCache)
+		defer func() {
+			sizes.Reset()
+			sizeCachePool.Put(sizes)
+		}()
+		if _, err := r.RecordSize(sizes, p); err != nil {
+			return 0, err
+		}
+		ew.Sizes = sizes
+	}
 	err = sszTyp.Encode(ew, p)
 
 	// make sure the data of the object is kept around up to this point.
@@ -96,6 +137,132 @@ func Encode(w io.Writer, val interface{}, sszTyp SSZ) (n int, err error) {
 	return ew.Written(), err
 }
 
+// Gets element i of a list to encode, as pointer to the element, e.g. *Block.
+// The element has to stay valid until the next call.
+type ElemIterator func(i uint64) (elem interface{}, err error)
+
+// Encodes a list of count elements to the writer, like Encode of a slice of the elements,
+// but getting the elements one at a time from the iterator, e.g. to stream them from a database.
+// Variable-size elements are iterated twice, in order: first to write their offsets, then to encode them.
+func EncodeListStream(w io.Writer, count uint64, next ElemIterator, listTyp SSZ) (n int, err error) {
+	list, ok := listTyp.(ListSSZ)
+	if !ok {
+		return 0, fmt.Errorf("cannot stream elements of %T, not a list definition", listTyp)
+	}
+	ew := NewEncodingWriter(w)
+	var elem interface{}
+	elemFn := func(i uint64) (unsafe.Pointer, error) {
+		var err error
+		if elem, err = next(i); err != nil {
+			return nil, err
+		}
+		p := ptrutil.IfacePtrToPtr(&elem)
+		if p == nil {
+			return nil, fmt.Errorf("element %d is nil", i)
+		}
+		return p, nil
+	}
+	err = EncodeElemStream(ew, list, count, elemFn)
+	return ew.Written(), err
+}
+
+var sliceWriterPool = sync.Pool{
+	New: func() interface{} {
+		return NewSliceEncodingWriter(nil)
+	},
+}
+
+// Encodes the value into a new byte slice, allocated once with the size of the encoding.
+func MarshalSSZ(val interface{}, sszTyp SSZ) ([]byte, error) {
+	return MarshalSSZTo(nil, val, sszTyp)
+}
+
+// Appends the encoding of the value to dst, and returns the extended slice.
+// The value is sized once, dst is only re-allocated if it does not have enough capacity for it.
+// On error, dst is returned as-is.
+func MarshalSSZTo(dst []byte, val interface{}, sszTyp SSZ) ([]byte, error) {
+	p := ptrutil.IfacePtrToPtr(&val)
+	sizes := sizeCachePool.Get().(*SizeCache)
+	defer func() {
+		sizes.Reset()
+		sizeCachePool.Put(sizes)
+	}()
+	size, err := RecordSize(sizes, sszTyp, p)
+	if err != nil {
+		return dst, err
+	}
+	start := len(dst)
+	out := dst
+	if uint64(cap(out)-start) < size {
+		out = make([]byte, start, uint64(start)+size)
+		copy(out, dst)
+	}
+	ew := sliceWriterPool.Get().(*EncodingWriter)
+	ew.ResetSlice(out)
+	ew.Sizes = sizes
+	err = sszTyp.Encode(ew, p)
+	out = ew.Bytes()
+	ew.ResetSlice(nil)
+	ew.Sizes = nil
+	sliceWriterPool.Put(ew)
+
+	// make sure the data of the object is kept around up to this point.
+	runtime.KeepAlive(&val)
+
+	if err != nil {
+		return dst, err
+	}
+	if written := uint64(len(out) - start); written != size {
+		return dst, fmt.Errorf("encoded %d bytes, but value was sized %d bytes", written, size)
+	}
+	return out, nil
+}
+
+// Appends the encoding of the value to dst in a single pass, and returns the extended slice.
+// Offsets are written as placeholders, and patched once the data they point to is written,
+// instead of sizing variable-size values before writing their offsets. The output is the same as Encode.
+// On error, dst is returned as-is.
+func EncodePatched(dst []byte, val interface{}, sszTyp SSZ) ([]byte, error) {
+	ew := sliceWriterPool.Get().(*EncodingWriter)
+	ew.ResetSlice(dst)
+	// slice writers can always patch
+	_ = ew.EnablePatching()
+
+	p := ptrutil.IfacePtrToPtr(&val)
+	err := sszTyp.Encode(ew, p)
+	out := ew.Bytes()
+	ew.ResetSlice(nil)
+	sliceWriterPool.Put(ew)
+
+	// make sure the data of the object is kept around up to this point.
+	runtime.KeepAlive(&val)
+
+	if err != nil {
+		return dst, err
+	}
+	return out, nil
+}
+
+// Encodes the value in a single pass, like EncodePatched, to the io.WriterAt, e.g. a file, starting at position off.
+// Offsets are patched with separate writes if the data they point to is large.
+func EncodePatchedAt(w io.WriterAt, off int64, val interface{}, sszTyp SSZ) (n int, err error) {
+	ew := NewWriterAtEncodingWriter(w, off)
+	if err := ew.EnablePatching(); err != nil {
+		return 0, err
+	}
+
+	p := ptrutil.IfacePtrToPtr(&val)
+	err = sszTyp.Encode(ew, p)
+
+	// make sure the data of the object is kept around up to this point.
+	runtime.KeepAlive(&val)
+
+	if err != nil {
+		return ew.Written(), err
+	}
+	return ew.Written(), ew.Flush()
+}
+
 func Pretty(w io.Writer, indent string, val interface{}, sszTyp SSZ) {
 	pw := NewPrettyWriter(w, indent)
 
@@ -114,6 +281,25 @@ func HashTreeRoot(h MerkleFn, val interface{}, sszTyp SSZ) [32]byte {
 	return out
 }
 
+// Like HashTreeRoot, but returns an error instead of panicking if the value cannot be merkleized,
+// e.g. a list over its limit, a nil pointer, or a union value that does not match its selector.
+// Errors of custom hash-tree-root methods are returned as well, see SSZMarshaler.
+func HashTreeRootChecked(h MerkleFn, val interface{}, sszTyp SSZ) (out [32]byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			// runtime errors are bugs, not invalid values.
+			if e, ok := r.(error); ok {
+				if _, isRuntime := e.(runtime.Error); !isRuntime {
+					err = e
+					return
+				}
+			}
+			panic(r)
+		}
+	}()
+	return HashTreeRoot(CheckedMerkleFn{MerkleFn: h}, val, sszTyp), nil
+}
+
 func SigningRoot(h MerkleFn, val interface{}, sszTyp SignedSSZ) [32]byte {
 	p := ptrutil.IfacePtrToPtr(&val)
 	out := sszTyp.SigningRoot(h, p)
@@ -122,11 +308,21 @@ func SigningRoot(h MerkleFn, val interface{}, sszTyp SignedSSZ) [32]byte {
 	return out
 }
 
-// Gets a SSZ type structure for the given Go type.
+// Gets a SSZ type structure for the given Go type, cached in the DefaultRegistry.
 // Pass an pointer instance of the type. Can be nil.
 // Example: GetSSZ((*MyStruct)(nil))
 func GetSSZ(ptr interface{}) SSZ {
-	ssz, err := SSZFactory(reflect.TypeOf(ptr).Elem())
+	ssz, err := DefaultRegistry.Get(reflect.TypeOf(ptr).Elem())
+	if err != nil {
+		panic(err)
+	}
+	return ssz
+}
+
+// Registers the Go type under the given name in the DefaultRegistry, and gets its SSZ type structure.
+// Example: RegisterSSZ("MyStruct", (*MyStruct)(nil))
+func RegisterSSZ(name string, ptr interface{}) SSZ {
+	ssz, err := DefaultRegistry.Register(name, reflect.TypeOf(ptr).Elem())
 	if err != nil {
 		panic(err)
 	}
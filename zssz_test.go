@@ -4,15 +4,24 @@ import (
 	"bufio"
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/protolambda/zssz/bitfields"
+	"github.com/protolambda/zssz/enc"
 	"github.com/protolambda/zssz/htr"
+	"github.com/protolambda/zssz/stable"
 	. "github.com/protolambda/zssz/types"
+	"github.com/protolambda/zssz/uints"
+	"github.com/protolambda/zssz/unions"
+	"github.com/protolambda/zssz/util/ptrutil"
+	"io/ioutil"
+	"math/big"
 	"reflect"
 	"strings"
 	"testing"
+	"unsafe"
 )
 
 type emptyTestStruct struct{}
@@ -79,6 +88,12 @@ type embeddingStruct struct {
 	Foo           smallTestStruct `ssz:"squash"` // Squash field explicitly
 }
 
+type ptrEmbeddingStruct struct {
+	*VarTestStruct // squash pointer field by embedding
+	B              uint16
+	Foo            *smallTestStruct `ssz:"squash"` // Squash pointer field explicitly
+}
+
 type ListA []smallTestStruct
 
 func (*ListA) Limit() uint64 { return 4 }
@@ -92,6 +107,52 @@ type ListStruct struct {
 	B ListB
 }
 
+type validateAttestation struct {
+	AggregationBits bitlist4
+	Slot            uint64
+}
+
+type validateAttestations []validateAttestation
+
+func (*validateAttestations) Limit() uint64 { return 4 }
+
+type boolList4 []bool
+
+func (*boolList4) Limit() uint64 { return 4 }
+
+type validateBody struct {
+	Attestations validateAttestations
+	Flags        boolList4
+	Bits         bitvec10
+}
+
+type validateBlock struct {
+	Slot uint64
+	Body validateBody
+}
+
+// lists nested 4 levels deep, to benchmark encoding of nested variable-size values with
+type benchList1 []bytelist256
+
+func (*benchList1) Limit() uint64 { return 1024 }
+
+type benchList2 []benchList1
+
+func (*benchList2) Limit() uint64 { return 1024 }
+
+type benchList3 []benchList2
+
+func (*benchList3) Limit() uint64 { return 1024 }
+
+type benchList4 []benchList3
+
+func (*benchList4) Limit() uint64 { return 1024 }
+
+type benchNested struct {
+	A uint64
+	B benchList4
+}
+
 type Squash1 struct {
 	A uint8
 	D *uint32 `ssz:"omit"`
@@ -114,6 +175,103 @@ type Squash3 struct {
 	Squash2
 }
 
+type testUnion unions.Union
+
+func (*testUnion) UnionOptions() []interface{} {
+	return []interface{}{nil, (*uint16)(nil), (*smallTestStruct)(nil)}
+}
+
+type unionTestStruct struct {
+	A uint8
+	B testUnion
+}
+
+type Payload interface {
+	isPayload()
+}
+
+type PayloadA struct {
+	X uint16
+}
+
+func (*PayloadA) isPayload() {}
+
+type PayloadB struct {
+	Data bytelist256
+}
+
+func (*PayloadB) isPayload() {}
+
+// not registered as option of Payload
+type PayloadC struct {
+	Y uint8
+}
+
+func (*PayloadC) isPayload() {}
+
+func init() {
+	unions.RegisterInterface((*Payload)(nil), nil, (*PayloadA)(nil), (*PayloadB)(nil))
+}
+
+type payloadTestStruct struct {
+	A uint8
+	P Payload
+}
+
+type Shape struct {
+	_      stable.Container `ssz:"capacity=4"`
+	Side   *uint16
+	Color  *uint8
+	Radius *uint16
+}
+
+type Square struct {
+	_     [0]Shape `ssz:"profile"`
+	Side  uint16
+	Color uint8
+}
+
+type Circle struct {
+	_      [0]Shape `ssz:"profile"`
+	Color  uint8
+	Radius uint16
+}
+
+type OptionalSide struct {
+	_     [0]Shape `ssz:"profile"`
+	Side  *uint16
+	Color uint8
+}
+
+type ShapePair struct {
+	_      stable.Container `ssz:"capacity=8"`
+	First  *Shape
+	Second *Shape
+}
+
+type taggedTestStruct struct {
+	A []uint16 `ssz-max:"4"`
+	B [][]byte `ssz-size:"?,2" ssz-max:"3"`
+	C [][]byte `ssz-max:"2,4"`
+	D []byte   `ssz:"bitlist" ssz-max:"8"`
+	E []byte   `ssz-size:"2"`
+}
+
+type graffitiString string
+
+func (*graffitiString) Limit() uint64 { return 64 }
+
+type stringTestStruct struct {
+	A graffitiString
+	B string   `ssz-max:"16"`
+	C string   `ssz-size:"4"`
+	D []string `ssz-max:"2,8"`
+}
+
+func u8(v uint8) *uint8 { return &v }
+
+func u16(v uint16) *uint16 { return &v }
+
 func chunk(v string) string {
 	res := [32]byte{}
 	data, _ := hex.DecodeString(v)
@@ -229,6 +387,29 @@ type list128bytes32 [][32]byte
 
 func (*list128bytes32) Limit() uint64 { return 128 }
 
+type list4uint256 []uints.Uint256
+
+func (*list4uint256) Limit() uint64 { return 4 }
+
+// Some progressive list types for testing
+
+type progressiveBytes32List [][32]byte
+
+func (*progressiveBytes32List) ProgressiveList() {}
+
+type progressiveUint16List []uint16
+
+func (*progressiveUint16List) ProgressiveList() {}
+
+type progressiveVarList []VarTestStruct
+
+func (*progressiveVarList) ProgressiveList() {}
+
+type progressiveBitlist []byte
+
+func (*progressiveBitlist) ProgressiveList() {}
+func (b progressiveBitlist) BitLen() uint64  { return bitfields.BitlistLen(b) }
+
 func getTyp(ptr interface{}) reflect.Type {
 	return reflect.TypeOf(ptr).Elem()
 }
@@ -251,6 +432,8 @@ var testCases []sszTestCase
 
 var valUint64 uint64 = 0x42
 
+var valUint16 uint16 = 0xabcd
+
 func init() {
 	var zeroHashes = []string{chunk("")}
 
@@ -312,6 +495,20 @@ func init() {
 		{"uint32 01234567", uint32(0x01234567), "67452301", chunk("67452301"), getTyp((*uint32)(nil))},
 		{"uint64 0000000000000000", uint64(0x00000000), "0000000000000000", chunk("0000000000000000"), getTyp((*uint64)(nil))},
 		{"uint64 0123456789abcdef", uint64(0x0123456789abcdef), "efcdab8967452301", chunk("efcdab8967452301"), getTyp((*uint64)(nil))},
+		{"uint128 0x00112233445566778899aabbccddeeff", uints.Uint128{0x8899aabbccddeeff, 0x0011223344556677},
+			"ffeeddccbbaa99887766554433221100", chunk("ffeeddccbbaa99887766554433221100"), getTyp((*uints.Uint128)(nil))},
+		{"uint256 1,2,3,4 limbs", uints.Uint256{1, 2, 3, 4},
+			"01" + repeat("00", 7) + "02" + repeat("00", 7) + "03" + repeat("00", 7) + "04" + repeat("00", 7),
+			"01" + repeat("00", 7) + "02" + repeat("00", 7) + "03" + repeat("00", 7) + "04" + repeat("00", 7),
+			getTyp((*uints.Uint256)(nil))},
+		{"uint128 vector", [3]uints.Uint128{{1, 0}, {2, 0}, {3, 0x42}},
+			"01" + repeat("00", 15) + "02" + repeat("00", 15) + "03" + repeat("00", 7) + "42" + repeat("00", 7),
+			h("01"+repeat("00", 15)+"02"+repeat("00", 15), chunk("03"+repeat("00", 7)+"42")),
+			getTyp((*[3]uints.Uint128)(nil))},
+		{"uint256 list", list4uint256{{1, 0, 0, 0}, {2, 0, 0, 0}},
+			"01" + repeat("00", 31) + "02" + repeat("00", 31),
+			h(h(h(chunk("01"), chunk("02")), zeroHashes[1]), chunk("02")),
+			getTyp((*list4uint256)(nil))},
 		{"sig", [96]byte{0: 1, 32: 2, 64: 3, 95: 0xff},
 			"01" + repeat("00", 31) + "02" + repeat("00", 31) + "03" + repeat("00", 30) + "ff",
 			h(h(chunk("01"), chunk("02")), h("03"+repeat("00", 30)+"ff", chunk(""))), getTyp((*[96]byte)(nil))},
@@ -563,6 +760,99 @@ func init() {
 				),
 			),
 			getTyp((*embeddingStruct)(nil))},
+		{"embedding pointers", ptrEmbeddingStruct{
+			VarTestStruct: &VarTestStruct{A: 0xabcd, B: []uint16{1, 2, 3}, C: 0xff},
+			B:             0x1234,
+			Foo:           &smallTestStruct{A: 0x4567, B: 0x0123},
+		}, "cdab" + "0d000000" + "ff" + "3412" + "6745" + "2301" + "010002000300",
+			h(
+				h(
+					h(chunk("cdab"), h(merge(chunk("010002000300"), zeroHashes[0:6]), chunk("03000000"))),
+					h(chunk("ff"), chunk("3412")),
+				),
+				h(h(chunk("6745"), chunk("2301")), zeroHashes[1]),
+			),
+			getTyp((*ptrEmbeddingStruct)(nil))},
+		{"strings", stringTestStruct{A: "hi", B: "h\u00e9llo", C: "ab", D: []string{"x", "yz"}},
+			"10000000" + "12000000" + "61620000" + "18000000" +
+				"6869" + "68c3a96c6c6f" + "08000000" + "09000000" + "78" + "797a",
+			h(
+				h(h(h(chunk("6869"), chunk("")), chunk("02")), h(chunk("68c3a96c6c6f"), chunk("06"))),
+				h(chunk("61620000"), h(h(h(chunk("78"), chunk("01")), h(chunk("797a"), chunk("02"))), chunk("02"))),
+			),
+			getTyp((*stringTestStruct)(nil))},
+		{"union None", testUnion{Selector: 0, Value: nil}, "00", h(chunk(""), chunk("00")), getTyp((*testUnion)(nil))},
+		{"union uint16", testUnion{Selector: 1, Value: &valUint16}, "01cdab",
+			h(chunk("cdab"), chunk("01")), getTyp((*testUnion)(nil))},
+		{"union container", testUnion{Selector: 2, Value: &smallTestStruct{A: 0x4567, B: 0x0123}}, "0267452301",
+			h(h(chunk("6745"), chunk("2301")), chunk("02")), getTyp((*testUnion)(nil))},
+		{"union field", unionTestStruct{A: 0xff, B: testUnion{Selector: 1, Value: &valUint16}}, "ff" + "05000000" + "01cdab",
+			h(chunk("ff"), h(chunk("cdab"), chunk("01"))), getTyp((*unionTestStruct)(nil))},
+		{"interface union None", payloadTestStruct{A: 0xff, P: nil}, "ff" + "05000000" + "00",
+			h(chunk("ff"), h(chunk(""), chunk("00"))), getTyp((*payloadTestStruct)(nil))},
+		{"interface union container", payloadTestStruct{A: 0xff, P: &PayloadA{X: 0x1234}}, "ff" + "05000000" + "01" + "3412",
+			h(chunk("ff"), h(chunk("3412"), chunk("01"))), getTyp((*payloadTestStruct)(nil))},
+		{"interface union var-size", payloadTestStruct{A: 0xff, P: &PayloadB{Data: bytelist256{1, 2}}},
+			"ff" + "05000000" + "02" + "04000000" + "0102",
+			h(chunk("ff"), h(h(merge(chunk("0102"), zeroHashes[0:3]), chunk("02")), chunk("02"))),
+			getTyp((*payloadTestStruct)(nil))},
+		{"stable container square", Shape{Side: u16(0x42), Color: u8(1)}, "03420001",
+			h(h(h(chunk("4200"), chunk("01")), h(chunk(""), chunk(""))), chunk("03")), getTyp((*Shape)(nil))},
+		{"stable container circle", Shape{Color: u8(1), Radius: u16(0x42)}, "06014200",
+			h(h(h(chunk(""), chunk("01")), h(chunk("4200"), chunk(""))), chunk("06")), getTyp((*Shape)(nil))},
+		{"stable container empty", Shape{}, "00",
+			h(h(h(chunk(""), chunk("")), h(chunk(""), chunk(""))), chunk("00")), getTyp((*Shape)(nil))},
+		{"profile square", Square{Side: 0x42, Color: 1}, "420001",
+			h(h(h(chunk("4200"), chunk("01")), h(chunk(""), chunk(""))), chunk("03")), getTyp((*Square)(nil))},
+		{"profile circle", Circle{Color: 1, Radius: 0x42}, "014200",
+			h(h(h(chunk(""), chunk("01")), h(chunk("4200"), chunk(""))), chunk("06")), getTyp((*Circle)(nil))},
+		{"profile optional present", OptionalSide{Side: u16(0x42), Color: 1}, "01420001",
+			h(h(h(chunk("4200"), chunk("01")), h(chunk(""), chunk(""))), chunk("03")), getTyp((*OptionalSide)(nil))},
+		{"profile optional absent", OptionalSide{Color: 1}, "0001",
+			h(h(h(chunk(""), chunk("01")), h(chunk(""), chunk(""))), chunk("02")), getTyp((*OptionalSide)(nil))},
+		{"nested stable containers", ShapePair{First: &Shape{Side: u16(0x42)}, Second: &Shape{}}, "03" + "08000000" + "0b000000" + "014200" + "00",
+			h(h(h(h(
+				h(h(h(chunk("4200"), chunk("")), h(chunk(""), chunk(""))), chunk("01")),
+				h(h(h(chunk(""), chunk("")), h(chunk(""), chunk(""))), chunk("00"))),
+				zeroHashes[1]), zeroHashes[2]), chunk("03")),
+			getTyp((*ShapePair)(nil))},
+		{"progressive list empty", progressiveBytes32List{}, "", h(chunk(""), chunk("00")), getTyp((*progressiveBytes32List)(nil))},
+		{"progressive list single", progressiveBytes32List{{1}}, "01" + repeat("00", 31),
+			h(h(chunk(""), chunk("01")), chunk("01")), getTyp((*progressiveBytes32List)(nil))},
+		{"progressive list", progressiveBytes32List{{1}, {2}, {3}, {4}, {5}, {6}},
+			"01" + repeat("00", 31) + "02" + repeat("00", 31) + "03" + repeat("00", 31) +
+				"04" + repeat("00", 31) + "05" + repeat("00", 31) + "06" + repeat("00", 31),
+			h(h(
+				h(
+					h(chunk(""), merge(chunk("06"), zeroHashes[0:4])),
+					h(h(chunk("02"), chunk("03")), h(chunk("04"), chunk("05"))),
+				),
+				chunk("01"),
+			), chunk("06")), getTyp((*progressiveBytes32List)(nil))},
+		{"progressive basic list", progressiveUint16List{0xaabb, 0xc0ad, 0xeeff}, "bbaaadc0ffee",
+			h(h(chunk(""), chunk("bbaaadc0ffee")), chunk("03")), getTyp((*progressiveUint16List)(nil))},
+		{"progressive var list", progressiveVarList{{A: 0xabcd, B: []uint16{1, 2, 3}, C: 0xff}},
+			"04000000" + "cdab07000000ff010002000300",
+			h(h(chunk(""), h(h(chunk("cdab"), h(merge(chunk("010002000300"), zeroHashes[0:6]), chunk("03000000"))),
+				h(chunk("ff"), chunk("")))), chunk("01")), getTyp((*progressiveVarList)(nil))},
+		{"progressive bitlist", progressiveBitlist{0x2b, 0x01}, "2b01",
+			h(h(chunk(""), chunk("2b")), chunk("08")), getTyp((*progressiveBitlist)(nil))},
+		{"tagged dimensions", taggedTestStruct{
+			A: []uint16{1, 2},
+			B: [][]byte{{0xaa, 0xbb}},
+			C: [][]byte{{1, 2, 3}, nil},
+			D: []byte{0x0b},
+			E: []byte{0xcc, 0xdd},
+		}, "12000000" + "16000000" + "18000000" + "23000000" + "ccdd" +
+			"01000200" + "aabb" + "08000000" + "0b000000" + "010203" + "0b",
+			h(
+				h(
+					h(h(chunk("01000200"), chunk("02")), h(merge(chunk("aabb"), zeroHashes[0:2]), chunk("01"))),
+					h(h(h(h(chunk("010203"), chunk("03")), h(chunk(""), chunk("00"))), chunk("02")), h(chunk("03"), chunk("03"))),
+				),
+				h(h(chunk("ccdd"), chunk("")), zeroHashes[1]),
+			),
+			getTyp((*taggedTestStruct)(nil))},
 		{"squash chaos", Squash3{
 			Foo:     Squash1{01, nil, 0xa8a7a6a5a4a3a2a1, 0xaabbccdd},
 			Squash1: Squash1{02, nil, 0xb8b7b6b5b4b3b2b1, 0x00001111},
@@ -640,124 +930,1649 @@ func TestEncode(t *testing.T) {
 	}
 }
 
-func TestDecode(t *testing.T) {
+func TestMarshalSSZ(t *testing.T) {
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
 			sszTyp, err := SSZFactory(tt.typ)
 			if err != nil {
 				t.Fatal(err)
 			}
-			data, err := hex.DecodeString(tt.hex)
+			data, err := MarshalSSZ(tt.value, sszTyp)
 			if err != nil {
 				t.Fatal(err)
 			}
-			r := bytes.NewReader(data)
-			// For dynamic types, we need to pass the length of the message to the decoder.
-			// See SSZ-envelope discussion
-			bytesLen := uint64(len(tt.hex)) / 2
+			if res := fmt.Sprintf("%x", data); res != tt.hex {
+				t.Fatalf("encoded different data:\n     got %s\nexpected %s", res, tt.hex)
+			}
+			if len(data) != cap(data) {
+				t.Errorf("expected exact capacity %d, got %d", len(data), cap(data))
+			}
+			prefixed, err := MarshalSSZTo([]byte{0xab}, tt.value, sszTyp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := fmt.Sprintf("%x", prefixed); res != "ab"+tt.hex {
+				t.Fatalf("appended different data:\n     got %s\nexpected ab%s", res, tt.hex)
+			}
+		})
+	}
+}
 
-			destination := reflect.New(tt.typ).Interface()
-			if err := Decode(r, bytesLen, destination, sszTyp); err != nil {
+func TestMarshalSSZToAllocs(t *testing.T) {
+	sszTyp := GetSSZ((*complexTestStruct)(nil))
+	val := &complexTestStruct{A: 0xabcd, B: uint16List128{1, 2, 3}, D: bytelist256("foobar"), E: VarTestStruct{B: uint16List1024{4, 5}}}
+	dst := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := MarshalSSZTo(dst[:0], val, sszTyp); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("expected no allocations when dst has enough capacity, got %f", allocs)
+	}
+}
+
+func TestEncodePatched(t *testing.T) {
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sszTyp, err := SSZFactory(tt.typ)
+			if err != nil {
 				t.Fatal(err)
 			}
-			res, err := json.Marshal(destination)
+			data, err := EncodePatched([]byte{0xab}, tt.value, sszTyp)
 			if err != nil {
 				t.Fatal(err)
 			}
-			expected, err := json.Marshal(tt.value)
+			if res := fmt.Sprintf("%x", data); res != "ab"+tt.hex {
+				t.Fatalf("encoded different data:\n     got %s\nexpected ab%s", res, tt.hex)
+			}
+			w := memWriterAt{data: make([]byte, 3)}
+			n, err := EncodePatchedAt(&w, 3, tt.value, sszTyp)
 			if err != nil {
 				t.Fatal(err)
 			}
-			// adjust expected json string. No effective difference between null and an empty slice. We prefer nil.
-			if adjusted := strings.ReplaceAll(string(expected), "[]", "null"); string(res) != adjusted {
-				t.Fatalf("decoded different data:\n     got %s\nexpected %s", res, adjusted)
+			if res := fmt.Sprintf("%x", w.data); res != "000000"+tt.hex {
+				t.Fatalf("wrote different data:\n     got %s\nexpected 000000%s", res, tt.hex)
+			}
+			if n != len(tt.hex)/2 {
+				t.Errorf("reported %d bytes written, expected %d", n, len(tt.hex)/2)
 			}
 		})
 	}
 }
 
-func TestDryCheck(t *testing.T) {
+// An io.WriterAt in memory, like a file
+type memWriterAt struct {
+	data []byte
+	// positions written at
+	writes []int64
+}
+
+func (w *memWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
+	if end := int(off) + len(p); end > len(w.data) {
+		w.data = append(w.data, make([]byte, end-len(w.data))...)
+	}
+	w.writes = append(w.writes, off)
+	return copy(w.data[off:], p), nil
+}
+
+func TestEncodePatchedAtLarge(t *testing.T) {
+	sszTyp := GetSSZ((*benchNested)(nil))
+	// large enough to patch offsets of data that is already written
+	val := newBenchNested(10)
+	var expected bytes.Buffer
+	if _, err := Encode(&expected, val, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	var w memWriterAt
+	if _, err := EncodePatchedAt(&w, 0, val, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.data, expected.Bytes()) {
+		t.Fatal("wrote different data")
+	}
+	// the offsets of the outer list of 10 elements, after the uint64 and the offset of the list.
+	// The ones in flushed data are patched together, instead of with a write per offset.
+	tableWrites := 0
+	for _, off := range w.writes {
+		if off >= 12 && off < 12+10*4 {
+			tableWrites++
+		}
+	}
+	if tableWrites != 1 {
+		t.Fatalf("expected the flushed offsets of the list to be patched with 1 write, got %d writes", tableWrites)
+	}
+}
+
+func TestEncodePatchedOverLimit(t *testing.T) {
+	sszTyp := GetSSZ((*ListStruct)(nil))
+	val := &ListStruct{B: make(ListB, 9)}
+	dst := []byte{0xab}
+	out, err := EncodePatched(dst, val, sszTyp)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected dst as-is, got %x", out)
+	}
+}
+
+func TestSizeCache(t *testing.T) {
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
 			sszTyp, err := SSZFactory(tt.typ)
 			if err != nil {
 				t.Fatal(err)
 			}
-			data, err := hex.DecodeString(tt.hex)
+			val := tt.value
+			p := ptrutil.IfacePtrToPtr(&val)
+			sizes := new(enc.SizeCache)
+			size, err := RecordSize(sizes, sszTyp, p)
 			if err != nil {
 				t.Fatal(err)
 			}
-			r := bytes.NewReader(data)
-			// For dynamic types, we need to pass the length of the message to the decoder.
-			// See SSZ-envelope discussion
-			bytesLen := uint64(len(tt.hex)) / 2
+			ew := enc.NewSliceEncodingWriter(nil)
+			ew.Sizes = sizes
+			if err := sszTyp.Encode(ew, p); err != nil {
+				t.Fatal(err)
+			}
+			data := ew.Bytes()
+			if res := fmt.Sprintf("%x", data); res != tt.hex {
+				t.Fatalf("encoded different data:\n     got %s\nexpected %s", res, tt.hex)
+			}
+			if uint64(len(data)) != size {
+				t.Errorf("recorded size %d does not match encoded length %d", size, len(data))
+			}
+			if n := sizes.Pending(); n != 0 {
+				t.Errorf("%d recorded sizes were not used", n)
+			}
+		})
+	}
+}
 
-			if err := DryCheck(r, bytesLen, sszTyp); err != nil {
-				t.Error(err)
+type parallelTestStruct struct {
+	A benchList2
+	B uint64
+	C benchList2
+	D ListB
+}
+
+func TestEncodeParallel(t *testing.T) {
+	cfg := enc.ParallelConfig{Workers: 3, Threshold: 2}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sszTyp, err := SSZFactory(tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err := EncodeParallel(&buf, tt.value, sszTyp, cfg); err != nil {
+				t.Fatal(err)
+			}
+			if res := fmt.Sprintf("%x", buf.Bytes()); res != tt.hex {
+				t.Fatalf("encoded different data:\n     got %s\nexpected %s", res, tt.hex)
 			}
 		})
 	}
 }
 
-func TestHashTreeRoot(t *testing.T) {
-	var buf bytes.Buffer
+func TestEncodeParallelNested(t *testing.T) {
+	sszTyp := GetSSZ((*parallelTestStruct)(nil))
+	nested := newBenchNested(5)
+	val := &parallelTestStruct{
+		A: nested.B[0][0],
+		B: 123,
+		C: nested.B[1][0],
+		D: ListB{{A: 1, B: uint16List1024{2, 3}}, {A: 4}, {C: 5}},
+	}
+	var expected bytes.Buffer
+	if _, err := Encode(&expected, val, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 2, 7, 100} {
+		cfg := enc.ParallelConfig{Workers: workers, Threshold: 3}
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := EncodeParallel(&buf, val, sszTyp, cfg); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+				t.Fatal("encoded different data")
+			}
+		})
+		t.Run(fmt.Sprintf("%d workers, recorded sizes", workers), func(t *testing.T) {
+			var v interface{} = val
+			p := ptrutil.IfacePtrToPtr(&v)
+			sizes := new(enc.SizeCache)
+			if _, err := RecordSize(sizes, sszTyp, p); err != nil {
+				t.Fatal(err)
+			}
+			ew := enc.NewSliceEncodingWriter(nil)
+			ew.Sizes = sizes
+			ew.Parallel = &cfg
+			if err := sszTyp.Encode(ew, p); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ew.Bytes(), expected.Bytes()) {
+				t.Fatal("encoded different data")
+			}
+			if n := sizes.Pending(); n != 0 {
+				t.Errorf("%d recorded sizes were not used", n)
+			}
+		})
+		t.Run(fmt.Sprintf("%d workers, patched", workers), func(t *testing.T) {
+			var v interface{} = val
+			ew := enc.NewSliceEncodingWriter(nil)
+			if err := ew.EnablePatching(); err != nil {
+				t.Fatal(err)
+			}
+			ew.Parallel = &cfg
+			if err := sszTyp.Encode(ew, ptrutil.IfacePtrToPtr(&v)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(ew.Bytes(), expected.Bytes()) {
+				t.Fatal("encoded different data")
+			}
+		})
+	}
+	t.Run("invalid element", func(t *testing.T) {
+		invalid := *val
+		invalid.C = append(benchList2{}, val.C...)
+		invalid.C[3] = make(benchList1, 1025)
+		cfg := enc.ParallelConfig{Workers: 2, Threshold: 2}
+		if _, err := EncodeParallel(ioutil.Discard, &invalid, sszTyp, cfg); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
 
-	// re-use a hash function
-	sha := sha256.New()
-	hashFn := htr.HashFn(func(input []byte) (out [32]byte) {
-		sha.Reset()
-		sha.Write(input)
-		copy(out[:], sha.Sum(nil))
-		return
+// Nested lists, each with n elements
+func newBenchNested(n int) *benchNested {
+	out := &benchNested{A: 123}
+	for i := 0; i < n; i++ {
+		var l3 benchList3
+		for j := 0; j < n; j++ {
+			var l2 benchList2
+			for k := 0; k < n; k++ {
+				var l1 benchList1
+				for m := 0; m < n; m++ {
+					l1 = append(l1, bytelist256("foobar"))
+				}
+				l2 = append(l2, l1)
+			}
+			l3 = append(l3, l2)
+		}
+		out.B = append(out.B, l3)
+	}
+	return out
+}
+
+func BenchmarkEncodeNested(b *testing.B) {
+	sszTyp := GetSSZ((*benchNested)(nil))
+	val := newBenchNested(8)
+	b.Run("size cache", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Encode(ioutil.Discard, val, sszTyp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("patched", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, SizeOf(val, sszTyp))
+		for i := 0; i < b.N; i++ {
+			if _, err := EncodePatched(buf[:0], val, sszTyp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		cfg := enc.ParallelConfig{Threshold: 4}
+		for i := 0; i < b.N; i++ {
+			if _, err := EncodeParallel(ioutil.Discard, val, sszTyp, cfg); err != nil {
+				b.Fatal(err)
+			}
+		}
 	})
+	b.Run("sizing per level", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var v interface{} = val
+			if err := sszTyp.Encode(enc.NewEncodingWriter(ioutil.Discard), ptrutil.IfacePtrToPtr(&v)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
 
-	for _, tt := range testCases {
+func TestEncodeListStream(t *testing.T) {
+	nested := newBenchNested(3)
+	cases := []struct {
+		name  string
+		value interface{}
+		typ   reflect.Type
+	}{
+		{"basic elements", &uint16List128{1, 2, 3, 0xffff}, getTyp((*uint16List128)(nil))},
+		{"fixed elements", &ListA{{1, 2}, {3, 4}}, getTyp((*ListA)(nil))},
+		{"var elements", &ListB{{A: 1, B: uint16List1024{2, 3}, C: 4}, {A: 5}, {C: 6, B: uint16List1024{7}}}, getTyp((*ListB)(nil))},
+		{"nested var elements", &nested.B, getTyp((*benchList4)(nil))},
+		{"no elements", &ListB{}, getTyp((*ListB)(nil))},
+		{"progressive basic elements", &progressiveUint16List{1, 2, 3}, getTyp((*progressiveUint16List)(nil))},
+		{"progressive var elements", &progressiveVarList{{A: 1, B: uint16List1024{2, 3}, C: 4}, {C: 5}}, getTyp((*progressiveVarList)(nil))},
+	}
+	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
-			buf.Reset()
 			sszTyp, err := SSZFactory(tt.typ)
 			if err != nil {
 				t.Fatal(err)
 			}
-			root := HashTreeRoot(hashFn, tt.value, sszTyp)
-			res := hex.EncodeToString(root[:])
-			if res != tt.root {
-				t.Errorf("Expected root %s but got %s", tt.root, res)
+			var expected bytes.Buffer
+			if _, err := Encode(&expected, tt.value, sszTyp); err != nil {
+				t.Fatal(err)
+			}
+			// iterate the elements of the slice, as pointers
+			slice := reflect.ValueOf(tt.value).Elem()
+			next := func(i uint64) (interface{}, error) {
+				return slice.Index(int(i)).Addr().Interface(), nil
+			}
+			var buf bytes.Buffer
+			n, err := EncodeListStream(&buf, uint64(slice.Len()), next, sszTyp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != buf.Len() {
+				t.Errorf("reported %d bytes written, but wrote %d", n, buf.Len())
+			}
+			if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+				t.Fatalf("encoded different data:\n     got %x\nexpected %x", buf.Bytes(), expected.Bytes())
 			}
 		})
 	}
 }
 
-func TestSigningRoot(t *testing.T) {
-	var buf bytes.Buffer
+type unionList4 []testUnion
 
-	// re-use a hash function
-	sha := sha256.New()
-	hashFn := htr.HashFn(func(input []byte) (out [32]byte) {
-		sha.Reset()
-		sha.Write(input)
-		copy(out[:], sha.Sum(nil))
-		return
+func (*unionList4) Limit() uint64 { return 4 }
+
+func TestEncodeListStreamInvalid(t *testing.T) {
+	listB := GetSSZ((*ListB)(nil))
+	elems := ListB{{A: 1, B: uint16List1024{2, 3}}, {A: 4}}
+	next := func(i uint64) (interface{}, error) {
+		return &elems[i%2], nil
+	}
+	t.Run("over limit", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := EncodeListStream(&buf, 9, next, listB); err == nil {
+			t.Fatal("expected error")
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("wrote %d bytes of a list over its limit", buf.Len())
+		}
+	})
+	t.Run("iterator error", func(t *testing.T) {
+		failing := func(i uint64) (interface{}, error) {
+			if i == 1 {
+				return nil, fmt.Errorf("element %d is missing", i)
+			}
+			return next(i)
+		}
+		if _, err := EncodeListStream(ioutil.Discard, 2, failing, listB); err == nil || err.Error() != "element 1 is missing" {
+			t.Fatalf("expected iterator error, got: %v", err)
+		}
+	})
+	t.Run("nil element", func(t *testing.T) {
+		nilElem := func(i uint64) (interface{}, error) {
+			return (*VarTestStruct)(nil), nil
+		}
+		if _, err := EncodeListStream(ioutil.Discard, 1, nilElem, listB); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+	t.Run("invalid element", func(t *testing.T) {
+		valUint16 := uint16(0xabcd)
+		unionElems := unionList4{{Selector: 1, Value: &valUint16}, {Selector: 1, Value: &smallTestStruct{}}}
+		invalid := func(i uint64) (interface{}, error) {
+			return &unionElems[i], nil
+		}
+		if _, err := EncodeListStream(ioutil.Discard, 2, invalid, GetSSZ((*unionList4)(nil))); err == nil {
+			t.Fatal("expected error")
+		}
 	})
+	t.Run("changed elements", func(t *testing.T) {
+		calls := 0
+		changing := func(i uint64) (interface{}, error) {
+			calls++
+			// a smaller element when encoding than when sizing
+			if calls > 2 {
+				return next(1)
+			}
+			return next(i)
+		}
+		if _, err := EncodeListStream(ioutil.Discard, 2, changing, listB); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+	t.Run("not a list", func(t *testing.T) {
+		if _, err := EncodeListStream(ioutil.Discard, 0, next, GetSSZ((*VarTestStruct)(nil))); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
 
+func TestSnappy(t *testing.T) {
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
-			buf.Reset()
 			sszTyp, err := SSZFactory(tt.typ)
 			if err != nil {
 				t.Fatal(err)
 			}
-			signedSSZ, ok := sszTyp.(SignedSSZ)
-			if !ok {
-				// only test signing root for applicable types
-				return
+			compressed, err := EncodeSnappy(tt.value, sszTyp)
+			if err != nil {
+				t.Fatal(err)
 			}
-			root := SigningRoot(hashFn, tt.value, signedSSZ)
-			res := hex.EncodeToString(root[:])
-			if res == tt.root && root != ([32]byte{}) {
-				t.Errorf("Signing root is not different than hash-tree-root. "+
-					"Expected root: %s but got %s (should be different)", tt.root, res)
+			blockDst := reflect.New(tt.typ).Interface()
+			if err := DecodeSnappy(compressed, blockDst, sszTyp); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := MarshalSSZ(blockDst, sszTyp); err != nil {
+				t.Fatal(err)
+			} else if res := fmt.Sprintf("%x", data); res != tt.hex {
+				t.Fatalf("block decoded to different data:\n     got %s\nexpected %s", res, tt.hex)
+			}
+
+			var buf bytes.Buffer
+			if err := EncodeSnappyFramed(&buf, tt.value, sszTyp); err != nil {
+				t.Fatal(err)
+			}
+			framedDst := reflect.New(tt.typ).Interface()
+			if err := DecodeSnappyFramed(&buf, framedDst, sszTyp); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := MarshalSSZ(framedDst, sszTyp); err != nil {
+				t.Fatal(err)
+			} else if res := fmt.Sprintf("%x", data); res != tt.hex {
+				t.Fatalf("framed decoded to different data:\n     got %s\nexpected %s", res, tt.hex)
+			}
+		})
+	}
+}
+
+func TestSnappyMaxLen(t *testing.T) {
+	sszTyp := GetSSZ((*smallTestStruct)(nil))
+	var dst smallTestStruct
+	// a block header that declares 1 GiB of decompressed data
+	if err := DecodeSnappy([]byte{0x80, 0x80, 0x80, 0x80, 0x04}, &dst, sszTyp); err == nil {
+		t.Fatal("expected error for decompressed length over the max length")
+	}
+	var buf bytes.Buffer
+	if err := EncodeSnappyFramed(&buf, &complexTestStruct{D: bytelist256("foobar")}, GetSSZ((*complexTestStruct)(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodeSnappyFramed(&buf, &dst, sszTyp); err == nil {
+		t.Fatal("expected error for decompressed data over the max length")
+	}
+}
+
+type bytelist4 []byte
+
+func (*bytelist4) Limit() uint64 { return 4 }
+
+type bytelist4List2 []bytelist4
+
+func (*bytelist4List2) Limit() uint64 { return 2 }
+
+func TestSnappyFullList(t *testing.T) {
+	sszTyp := GetSSZ((*bytelist4List2)(nil))
+	// the max length of a list of variable-size elements includes the offsets
+	full := &bytelist4List2{{1, 2, 3, 4}, {5, 6, 7, 8}}
+	compressed, err := EncodeSnappy(full, sszTyp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blockDst bytelist4List2
+	if err := DecodeSnappy(compressed, &blockDst, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&blockDst, full) {
+		t.Fatalf("block decoded to different value: %v", blockDst)
+	}
+	var buf bytes.Buffer
+	if err := EncodeSnappyFramed(&buf, full, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	var framedDst bytelist4List2
+	if err := DecodeSnappyFramed(&buf, &framedDst, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&framedDst, full) {
+		t.Fatalf("framed decoded to different value: %v", framedDst)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sszTyp, err := SSZFactory(tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			// For dynamic types, we need to pass the length of the message to the decoder.
+			// See SSZ-envelope discussion
+			bytesLen := uint64(len(tt.hex)) / 2
+
+			destination := reflect.New(tt.typ).Interface()
+			if err := Decode(r, bytesLen, destination, sszTyp); err != nil {
+				t.Fatal(err)
+			}
+			res, err := json.Marshal(destination)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// adjust expected json string. No effective difference between null and an empty slice. We prefer nil.
+			if adjusted := strings.ReplaceAll(string(expected), "[]", "null"); string(res) != adjusted {
+				t.Fatalf("decoded different data:\n     got %s\nexpected %s", res, adjusted)
+			}
+		})
+	}
+}
+
+func TestDryCheck(t *testing.T) {
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sszTyp, err := SSZFactory(tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			// For dynamic types, we need to pass the length of the message to the decoder.
+			// See SSZ-envelope discussion
+			bytesLen := uint64(len(tt.hex)) / 2
+
+			if err := DryCheck(r, bytesLen, sszTyp); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestHashTreeRoot(t *testing.T) {
+	var buf bytes.Buffer
+
+	// re-use a hash function
+	sha := sha256.New()
+	hashFn := htr.HashFn(func(input []byte) (out [32]byte) {
+		sha.Reset()
+		sha.Write(input)
+		copy(out[:], sha.Sum(nil))
+		return
+	})
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			sszTyp, err := SSZFactory(tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := HashTreeRoot(hashFn, tt.value, sszTyp)
+			res := hex.EncodeToString(root[:])
+			if res != tt.root {
+				t.Errorf("Expected root %s but got %s", tt.root, res)
+			}
+		})
+	}
+}
+
+func TestSigningRoot(t *testing.T) {
+	var buf bytes.Buffer
+
+	// re-use a hash function
+	sha := sha256.New()
+	hashFn := htr.HashFn(func(input []byte) (out [32]byte) {
+		sha.Reset()
+		sha.Write(input)
+		copy(out[:], sha.Sum(nil))
+		return
+	})
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			sszTyp, err := SSZFactory(tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signedSSZ, ok := sszTyp.(SignedSSZ)
+			if !ok {
+				// only test signing root for applicable types
+				return
+			}
+			root := SigningRoot(hashFn, tt.value, signedSSZ)
+			res := hex.EncodeToString(root[:])
+			if res == tt.root && root != ([32]byte{}) {
+				t.Errorf("Signing root is not different than hash-tree-root. "+
+					"Expected root: %s but got %s (should be different)", tt.root, res)
 			}
 			t.Logf("signing root: %x\n", root)
 		})
 	}
 }
+
+type bigUintTestStruct struct {
+	A uint8
+	B *big.Int
+	C big.Int
+}
+
+type limbsUintTestStruct struct {
+	A uint8
+	B uints.Uint128
+	C uints.Uint128
+}
+
+func TestBigUint(t *testing.T) {
+	bigUintFactoryFn := BigUintFactoryFn(16)
+	var factory SSZFactoryFn
+	factory = func(typ reflect.Type) (SSZ, error) {
+		return bigUintFactoryFn(factory, typ)
+	}
+	bigSSZ, err := factory(getTyp((*bigUintTestStruct)(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limbsSSZ := GetSSZ((*limbsUintTestStruct)(nil))
+
+	b, _ := new(big.Int).SetString("112233445566778899aabbccddeeff00", 16)
+	bigVal := bigUintTestStruct{A: 0xab, B: b}
+	bigVal.C.SetUint64(0x42)
+	limbsVal := limbsUintTestStruct{A: 0xab, B: uints.Uint128{0x99aabbccddeeff00, 0x1122334455667788}, C: uints.Uint128{0x42, 0}}
+
+	var bigBuf, limbsBuf bytes.Buffer
+	if _, err := Encode(&bigBuf, &bigVal, bigSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(&limbsBuf, &limbsVal, limbsSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bigBuf.Bytes(), limbsBuf.Bytes()) {
+		t.Fatalf("big.Int encoding %x does not match uint128 encoding %x", bigBuf.Bytes(), limbsBuf.Bytes())
+	}
+	if a, b := HashTreeRoot(htr.HashFn(sha256.Sum256), &bigVal, bigSSZ), HashTreeRoot(htr.HashFn(sha256.Sum256), &limbsVal, limbsSSZ); a != b {
+		t.Fatalf("big.Int root %x does not match uint128 root %x", a, b)
+	}
+
+	var decoded bigUintTestStruct
+	data := bigBuf.Bytes()
+	if err := Decode(bytes.NewReader(data), uint64(len(data)), &decoded, bigSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.B.Cmp(b) != 0 || decoded.C.Uint64() != 0x42 {
+		t.Fatalf("decoded different data: %s %s", decoded.B, &decoded.C)
+	}
+
+	var pretty strings.Builder
+	Pretty(&pretty, "  ", &limbsVal, limbsSSZ)
+	if !strings.Contains(pretty.String(), b.String()) {
+		t.Fatalf("expected decimal uint128 %s in pretty output:\n%s", b.String(), pretty.String())
+	}
+
+	tooLarge := bigUintTestStruct{B: new(big.Int).Lsh(big.NewInt(1), 128)}
+	if _, err := Encode(&bigBuf, &tooLarge, bigSSZ); err == nil {
+		t.Fatal("expected error when encoding too large integer")
+	}
+}
+
+func TestNilPointers(t *testing.T) {
+	zeroVal := withPointerChildren{A: &smallTestStruct{}, C: new(uint64)}
+	nilVal := withPointerChildren{}
+	sszTyp := GetSSZ((*withPointerChildren)(nil))
+
+	var zeroBuf, nilBuf bytes.Buffer
+	if _, err := Encode(&zeroBuf, &zeroVal, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(&nilBuf, &nilVal, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(zeroBuf.Bytes(), nilBuf.Bytes()) {
+		t.Fatalf("nil pointers encoded as %x, but expected zero values %x", nilBuf.Bytes(), zeroBuf.Bytes())
+	}
+	if size := SizeOf(&nilVal, sszTyp); size != uint64(nilBuf.Len()) {
+		t.Fatalf("size of nil pointers %d does not match encoding length %d", size, nilBuf.Len())
+	}
+	hFn := htr.HashFn(sha256.Sum256)
+	if a, b := HashTreeRoot(hFn, &nilVal, sszTyp), HashTreeRoot(hFn, &zeroVal, sszTyp); a != b {
+		t.Fatalf("nil pointers root %x does not match zero values root %x", a, b)
+	}
+
+	var strictFactory SSZFactoryFn
+	strictFactory = func(typ reflect.Type) (SSZ, error) {
+		return StrictNilPtrFactoryFn(strictFactory, typ)
+	}
+	strictTyp, err := strictFactory(getTyp((*withPointerChildren)(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(&nilBuf, &nilVal, strictTyp); err == nil {
+		t.Fatal("expected error when encoding nil pointer with strict nil policy")
+	}
+	if _, err := Encode(&zeroBuf, &zeroVal, strictTyp); err != nil {
+		t.Fatalf("expected no error for non-nil pointers with strict nil policy, got: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic when hashing nil pointer with strict nil policy")
+		}
+	}()
+	HashTreeRoot(hFn, &nilVal, strictTyp)
+}
+
+// limits that do not fill their last chunk
+type bytelist33 []byte
+
+func (*bytelist33) Limit() uint64 { return 33 }
+
+type uint16List3 []uint16
+
+func (*uint16List3) Limit() uint64 { return 3 }
+
+type string33 string
+
+func (*string33) Limit() uint64 { return 33 }
+
+func TestOverLimit(t *testing.T) {
+	hFn := htr.HashFn(sha256.Sum256)
+	tooManyBits := bitlist4{0x3f}
+	// over the limit, but within the chunks of the limit
+	tooManyBytes := make(bytelist33, 40)
+	tooManyUint16s := make(uint16List3, 4)
+	tooLongString := string33(strings.Repeat("x", 40))
+	cases := []struct {
+		name   string
+		value  interface{}
+		sszTyp SSZ
+	}{
+		{"list", &complexTestStruct{B: make(uint16List128, 129)}, GetSSZ((*complexTestStruct)(nil))},
+		{"bytes", &complexTestStruct{D: make(bytelist256, 257)}, GetSSZ((*complexTestStruct)(nil))},
+		{"var elems", &ListStruct{B: make(ListB, 9)}, GetSSZ((*ListStruct)(nil))},
+		{"bitlist", &tooManyBits, GetSSZ((*bitlist4)(nil))},
+		{"bytes within limit chunks", &tooManyBytes, GetSSZ((*bytelist33)(nil))},
+		{"basic list within limit chunks", &tooManyUint16s, GetSSZ((*uint16List3)(nil))},
+		{"string within limit chunks", &tooLongString, GetSSZ((*string33)(nil))},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := Encode(&buf, tt.value, tt.sszTyp); err == nil {
+				t.Error("expected encoding error")
+			}
+			if _, err := MarshalSSZ(tt.value, tt.sszTyp); err == nil {
+				t.Error("expected marshaling error")
+			}
+			if _, err := HashTreeRootChecked(hFn, tt.value, tt.sszTyp); err == nil {
+				t.Error("expected hash-tree-root error")
+			}
+		})
+	}
+
+	var strictFactory SSZFactoryFn
+	strictFactory = func(typ reflect.Type) (SSZ, error) {
+		return StrictNilPtrFactoryFn(strictFactory, typ)
+	}
+	strictTyp, err := strictFactory(getTyp((*withPointerChildren)(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := HashTreeRootChecked(hFn, &withPointerChildren{}, strictTyp); err == nil {
+		t.Error("expected hash-tree-root error for nil pointer with strict nil policy")
+	}
+	valid := &complexTestStruct{B: make(uint16List128, 128)}
+	if root, err := HashTreeRootChecked(hFn, valid, GetSSZ((*complexTestStruct)(nil))); err != nil {
+		t.Fatal(err)
+	} else if expected := HashTreeRoot(hFn, valid, GetSSZ((*complexTestStruct)(nil))); root != expected {
+		t.Errorf("got root %x, expected %x", root, expected)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	newBlock := func() *validateBlock {
+		return &validateBlock{Body: validateBody{
+			Attestations: validateAttestations{
+				{AggregationBits: bitlist4{0x1f}},
+				{AggregationBits: bitlist4{0x01}},
+				{AggregationBits: bitlist4{0x03}},
+				{AggregationBits: bitlist4{0x01}},
+			},
+			Flags: boolList4{true, false},
+		}}
+	}
+	cases := []struct {
+		name   string
+		modify func(b *validateBlock)
+		path   string
+	}{
+		{"valid", func(b *validateBlock) {}, ""},
+		{"bitlist delimiter", func(b *validateBlock) { b.Body.Attestations[3].AggregationBits = bitlist4{0x00} }, "Body.Attestations[3].AggregationBits"},
+		{"bitlist over limit", func(b *validateBlock) { b.Body.Attestations[0].AggregationBits = bitlist4{0x3f} }, "Body.Attestations[0].AggregationBits"},
+		{"list over limit", func(b *validateBlock) {
+			b.Body.Attestations = append(b.Body.Attestations, b.Body.Attestations[0])
+		}, "Body.Attestations"},
+		{"bool", func(b *validateBlock) { *(*byte)(unsafe.Pointer(&b.Body.Flags[1])) = 2 }, "Body.Flags[1]"},
+		{"bitvector padding", func(b *validateBlock) { b.Body.Bits[1] = 0x04 }, "Body.Bits"},
+	}
+	sszTyp := GetSSZ((*validateBlock)(nil))
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBlock()
+			tt.modify(b)
+			err := Validate(b, sszTyp)
+			if tt.path == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			vErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("expected validation error, got %T: %v", err, err)
+			}
+			if vErr.Path != tt.path {
+				t.Errorf("expected path %q, got %q (%v)", tt.path, vErr.Path, err)
+			}
+		})
+	}
+
+	var strictFactory SSZFactoryFn
+	strictFactory = func(typ reflect.Type) (SSZ, error) {
+		return StrictNilPtrFactoryFn(strictFactory, typ)
+	}
+	strictTyp, err := strictFactory(getTyp((*withPointerChildren)(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(&withPointerChildren{C: new(uint64)}, strictTyp); err == nil {
+		t.Error("expected validation error for nil pointer with strict nil policy")
+	} else if vErr, ok := err.(*ValidationError); !ok || vErr.Path != "A" {
+		t.Errorf("expected error at path A, got %v", err)
+	}
+}
+
+func TestSquashNilPointers(t *testing.T) {
+	zeroVal := ptrEmbeddingStruct{VarTestStruct: &VarTestStruct{}, B: 0x1234, Foo: &smallTestStruct{}}
+	nilVal := ptrEmbeddingStruct{B: 0x1234}
+	sszTyp := GetSSZ((*ptrEmbeddingStruct)(nil))
+
+	var zeroBuf, nilBuf bytes.Buffer
+	if _, err := Encode(&zeroBuf, &zeroVal, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(&nilBuf, &nilVal, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(zeroBuf.Bytes(), nilBuf.Bytes()) {
+		t.Fatalf("nil squashed pointers encoded as %x, but expected zero values %x", nilBuf.Bytes(), zeroBuf.Bytes())
+	}
+	hFn := htr.HashFn(sha256.Sum256)
+	if a, b := HashTreeRoot(hFn, &nilVal, sszTyp), HashTreeRoot(hFn, &zeroVal, sszTyp); a != b {
+		t.Fatalf("nil squashed pointers root %x does not match zero values root %x", a, b)
+	}
+	var dst ptrEmbeddingStruct
+	if err := Decode(bytes.NewReader(nilBuf.Bytes()), uint64(nilBuf.Len()), &dst, sszTyp); err != nil {
+		t.Fatal(err)
+	}
+	if dst.VarTestStruct == nil || dst.Foo == nil {
+		t.Fatal("expected squashed pointers to be allocated when decoding")
+	}
+	if dst.B != 0x1234 {
+		t.Fatalf("unexpected decoded field value: %x", dst.B)
+	}
+}
+
+func TestStringInvalid(t *testing.T) {
+	sszTyp := GetSSZ((*stringTestStruct)(nil))
+	var buf bytes.Buffer
+	if _, err := Encode(&buf, &stringTestStruct{C: "abcde"}, sszTyp); err == nil {
+		t.Fatal("expected error when encoding fixed-length string that is too long")
+	}
+	if _, err := Encode(&buf, &stringTestStruct{C: "a\x00"}, sszTyp); err == nil {
+		t.Fatal("expected error when encoding fixed-length string that ends with a zero byte")
+	}
+	if _, err := SSZFactory(getTyp((*struct {
+		C string `ssz-size:"0"`
+	})(nil))); err == nil {
+		t.Fatal("expected error for zero-length fixed-length string")
+	}
+	for _, input := range []string{
+		"10000000" + "11000000" + "61620000" + "11000000" + "ff", // invalid UTF-8 in list-like string
+		"10000000" + "10000000" + "61ff0000" + "10000000",        // invalid UTF-8 in fixed-length string
+		"10000000" + "10000000" + "61620000" + "11000000" + "c3", // truncated UTF-8 sequence
+	} {
+		data, _ := hex.DecodeString(input)
+		if err := DryCheck(bytes.NewReader(data), uint64(len(data)), sszTyp); err == nil {
+			t.Errorf("expected dry check of %s to fail", input)
+		}
+		var dst stringTestStruct
+		if err := Decode(bytes.NewReader(data), uint64(len(data)), &dst, sszTyp); err == nil {
+			t.Errorf("expected decoding of %s to fail", input)
+		}
+	}
+}
+
+type longString string
+
+func (*longString) Limit() uint64 { return 4096 }
+
+func TestStringDryCheckChunks(t *testing.T) {
+	sszTyp := GetSSZ((*longString)(nil))
+	// runes of 2 and 3 bytes, split over the chunks of the UTF-8 check
+	for _, str := range []string{"x" + strings.Repeat("\u00e9", 1500), strings.Repeat("\u20ac", 1300)} {
+		data := []byte(str)
+		if err := DryCheck(bytes.NewReader(data), uint64(len(data)), sszTyp); err != nil {
+			t.Fatal(err)
+		}
+		truncated := data[:len(data)-1]
+		if err := DryCheck(bytes.NewReader(truncated), uint64(len(truncated)), sszTyp); err == nil {
+			t.Error("expected dry check of truncated UTF-8 sequence to fail")
+		}
+		invalid := append([]byte{}, data...)
+		invalid[2000] = 0xff
+		if err := DryCheck(bytes.NewReader(invalid), uint64(len(invalid)), sszTyp); err == nil {
+			t.Error("expected dry check of invalid UTF-8 to fail")
+		}
+	}
+}
+
+func TestIfaceUnion(t *testing.T) {
+	payloadSSZ := GetSSZ((*Payload)(nil))
+	var buf bytes.Buffer
+	var unregistered Payload = &PayloadC{Y: 1}
+	if _, err := Encode(&buf, &unregistered, payloadSSZ); err == nil {
+		t.Fatal("expected error when encoding unregistered interface value type")
+	}
+	var typedNil Payload = (*PayloadA)(nil)
+	if _, err := Encode(&buf, &typedNil, payloadSSZ); err == nil {
+		t.Fatal("expected error when encoding typed nil interface value")
+	}
+	sszTyp := GetSSZ((*payloadTestStruct)(nil))
+	var out strings.Builder
+	Pretty(&out, "  ", &payloadTestStruct{P: &PayloadA{X: 1}}, sszTyp)
+	if !strings.Contains(out.String(), "type: zssz.PayloadA") {
+		t.Fatalf("expected pretty output to show active union option type, got:\n%s", out.String())
+	}
+	data, _ := hex.DecodeString("ff" + "05000000" + "03")
+	var dst payloadTestStruct
+	if err := Decode(bytes.NewReader(data), uint64(len(data)), &dst, sszTyp); err == nil {
+		t.Fatal("expected error when decoding invalid selector")
+	}
+	if _, err := SSZFactory(reflect.TypeOf((*interface{ Foo() })(nil)).Elem()); err == nil {
+		t.Fatal("expected error for unregistered interface type")
+	}
+}
+
+// counts calls to the custom methods
+var customCalls int
+
+// A container with hand-written SSZ methods, equivalent to the reflection-based definition
+type customMarshalStruct struct {
+	A uint16
+	B uint64
+}
+
+func (c *customMarshalStruct) SizeSSZ() int {
+	customCalls++
+	return 10
+}
+
+func (c *customMarshalStruct) MarshalSSZTo(buf []byte) ([]byte, error) {
+	customCalls++
+	var tmp [10]byte
+	binary.LittleEndian.PutUint16(tmp[0:2], c.A)
+	binary.LittleEndian.PutUint64(tmp[2:10], c.B)
+	return append(buf, tmp[:]...), nil
+}
+
+func (c *customMarshalStruct) UnmarshalSSZ(buf []byte) error {
+	customCalls++
+	if len(buf) != 10 {
+		return fmt.Errorf("expected 10 bytes, got %d", len(buf))
+	}
+	c.A = binary.LittleEndian.Uint16(buf[0:2])
+	c.B = binary.LittleEndian.Uint64(buf[2:10])
+	return nil
+}
+
+func (c *customMarshalStruct) HashTreeRoot() ([32]byte, error) {
+	customCalls++
+	var chunks [64]byte
+	binary.LittleEndian.PutUint16(chunks[0:2], c.A)
+	binary.LittleEndian.PutUint64(chunks[32:40], c.B)
+	return sha256.Sum256(chunks[:]), nil
+}
+
+type customMarshalContainer struct {
+	X uint8
+	C customMarshalStruct
+	L []customMarshalStruct `ssz-max:"4"`
+	P *customMarshalStruct
+}
+
+func TestMarshalerAwareFactory(t *testing.T) {
+	typ := getTyp((*customMarshalContainer)(nil))
+	customSSZ, err := MarshalerAwareFactory(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultSSZ := GetSSZ((*customMarshalContainer)(nil))
+	val := customMarshalContainer{
+		X: 0xff,
+		C: customMarshalStruct{A: 0x1234, B: 0x0102030405060708},
+		L: []customMarshalStruct{{A: 1, B: 2}, {A: 3, B: 4}},
+		P: &customMarshalStruct{A: 5, B: 6},
+	}
+
+	customCalls = 0
+	var customBuf, defaultBuf bytes.Buffer
+	if _, err := Encode(&customBuf, &val, customSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if customCalls == 0 {
+		t.Fatal("expected custom marshaling methods to be used")
+	}
+	if _, err := Encode(&defaultBuf, &val, defaultSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(customBuf.Bytes(), defaultBuf.Bytes()) {
+		t.Fatalf("custom encoding %x does not match default encoding %x", customBuf.Bytes(), defaultBuf.Bytes())
+	}
+
+	customCalls = 0
+	hFn := htr.HashFn(sha256.Sum256)
+	if a, b := HashTreeRoot(hFn, &val, customSSZ), HashTreeRoot(hFn, &val, defaultSSZ); a != b {
+		t.Fatalf("custom root %x does not match default root %x", a, b)
+	}
+	if customCalls == 0 {
+		t.Fatal("expected custom hash-tree-root method to be used")
+	}
+
+	customCalls = 0
+	data := customBuf.Bytes()
+	var dst customMarshalContainer
+	if err := Decode(bytes.NewReader(data), uint64(len(data)), &dst, customSSZ); err != nil {
+		t.Fatal(err)
+	}
+	if customCalls == 0 {
+		t.Fatal("expected custom unmarshaling method to be used")
+	}
+	if !reflect.DeepEqual(dst, val) {
+		t.Fatalf("decoded %v, expected %v", dst, val)
+	}
+}
+
+// Like the fastssz hasher: HashTreeRootWith methods put chunks in it, and HashRoot gets the root of these.
+type testHasher struct {
+	chunks []byte
+}
+
+func (h *testHasher) PutUint64(v uint64) {
+	var chunk [32]byte
+	binary.LittleEndian.PutUint64(chunk[:8], v)
+	h.chunks = append(h.chunks, chunk[:]...)
+}
+
+func (h *testHasher) HashRoot() ([32]byte, error) {
+	return sha256.Sum256(h.chunks), nil
+}
+
+type hashWithStruct struct {
+	A uint16
+	B uint64
+}
+
+func (c *hashWithStruct) HashTreeRootWith(h *testHasher) error {
+	customCalls++
+	if c.A == 0xffff {
+		return fmt.Errorf("invalid A")
+	}
+	h.PutUint64(uint64(c.A))
+	h.PutUint64(c.B)
+	return nil
+}
+
+type hashWithContainer struct {
+	X uint8
+	C hashWithStruct
+}
+
+func TestMarshalerAwareFactoryWithHasher(t *testing.T) {
+	typ := getTyp((*hashWithContainer)(nil))
+	withHasher := MarshalerAwareFactoryFnWithHasher(func() interface{} { return new(testHasher) })
+	var factory SSZFactoryFn
+	factory = func(typ reflect.Type) (SSZ, error) {
+		return withHasher(factory, typ)
+	}
+	customSSZ, err := factory(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultSSZ := GetSSZ((*hashWithContainer)(nil))
+	hFn := htr.HashFn(sha256.Sum256)
+
+	val := hashWithContainer{X: 1, C: hashWithStruct{A: 2, B: 3}}
+	customCalls = 0
+	if a, b := HashTreeRoot(hFn, &val, customSSZ), HashTreeRoot(hFn, &val, defaultSSZ); a != b {
+		t.Fatalf("custom root %x does not match default root %x", a, b)
+	}
+	if customCalls == 0 {
+		t.Fatal("expected HashTreeRootWith method to be used")
+	}
+
+	// errors of the method are only returned by HashTreeRootChecked, HashTreeRoot uses the default definition.
+	invalid := hashWithContainer{C: hashWithStruct{A: 0xffff}}
+	if a, b := HashTreeRoot(hFn, &invalid, customSSZ), HashTreeRoot(hFn, &invalid, defaultSSZ); a != b {
+		t.Fatalf("root %x does not match default root %x", a, b)
+	}
+	if _, err := HashTreeRootChecked(hFn, &invalid, customSSZ); err == nil || err.Error() != "invalid A" {
+		t.Fatalf("expected error of the method, got: %v", err)
+	}
+
+	noRoot := MarshalerAwareFactoryFnWithHasher(func() interface{} { return new(bytes.Buffer) })
+	if _, err := noRoot(factory, typ); err == nil {
+		t.Fatal("expected error for hasher without HashRoot method")
+	}
+}
+
+type selfRefStruct struct {
+	A    uint8
+	Next *selfRefStruct
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(DefaultSSZFactory)
+	typ := getTyp((*complexTestStruct)(nil))
+	results := make(chan SSZ, 8)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			res, err := registry.Get(typ)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- res
+		}()
+	}
+	first := <-results
+	for i := 1; i < cap(results); i++ {
+		if res := <-results; res != first {
+			t.Fatal("expected the same cached definition for every call")
+		}
+	}
+
+	if _, err := registry.Get(getTyp((*selfRefStruct)(nil))); err == nil || !strings.Contains(err.Error(), "self-referential") {
+		t.Fatalf("expected self-referential type error, got: %v", err)
+	}
+
+	if _, err := registry.Register("Complex", typ); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Register("Complex", getTyp((*smallTestStruct)(nil))); err == nil {
+		t.Fatal("expected error when registering a name for a different type")
+	}
+	if res, err := registry.GetByName("Complex"); err != nil || res != first {
+		t.Fatalf("expected cached definition by name, got %v, err: %v", res, err)
+	}
+	if _, err := registry.GetByName("Unknown"); err == nil {
+		t.Fatal("expected error for unknown name")
+	}
+	if names := registry.Names(); len(names) != 1 || names[0] != "Complex" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+}
+
+type forkTestStruct struct {
+	A uint8
+	B uint16 `ssz:"since=altair"`
+	C uint8  `ssz:"until=altair"`
+	D uint16 `ssz:"since=altair,until=deneb"`
+}
+
+func TestForkContainer(t *testing.T) {
+	schedule := ForkSchedule{"phase0", "altair", "bellatrix", "deneb"}
+	val := forkTestStruct{A: 1, B: 2, C: 3, D: 4}
+	typ := getTyp((*forkTestStruct)(nil))
+	hFn := htr.HashFn(sha256.Sum256)
+	for _, testCase := range []struct {
+		fork string
+		hex  string
+		root string
+	}{
+		{"phase0", "0103", h(chunk("01"), chunk("03"))},
+		{"altair", "0102000400", h(h(chunk("01"), chunk("0200")), h(chunk("0400"), chunk("")))},
+		{"bellatrix", "0102000400", h(h(chunk("01"), chunk("0200")), h(chunk("0400"), chunk("")))},
+		{"deneb", "010200", h(chunk("01"), chunk("0200"))},
+	} {
+		t.Run(testCase.fork, func(t *testing.T) {
+			fork, err := NewForkSelection(schedule, testCase.fork)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sszTyp, err := NewRegistry(ForkFactoryFn(fork, DefaultSSZFactory)).Get(typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err := Encode(&buf, &val, sszTyp); err != nil {
+				t.Fatal(err)
+			}
+			if res := fmt.Sprintf("%x", buf.Bytes()); res != testCase.hex {
+				t.Fatalf("encoded %s, expected %s", res, testCase.hex)
+			}
+			if root := HashTreeRoot(hFn, &val, sszTyp); fmt.Sprintf("%x", root) != testCase.root {
+				t.Fatalf("root %x, expected %s", root, testCase.root)
+			}
+		})
+	}
+	if _, err := SSZFactory(typ); err == nil {
+		t.Fatal("expected error for fork-specific fields without selected fork")
+	}
+	if _, err := NewForkSelection(schedule, "unknown"); err == nil {
+		t.Fatal("expected error for unknown fork")
+	}
+	fork, _ := NewForkSelection(ForkSchedule{"phase0", "bellatrix"}, "phase0")
+	if _, err := NewRegistry(ForkFactoryFn(fork, DefaultSSZFactory)).Get(typ); err == nil {
+		t.Fatal("expected error for unknown fork in field tag")
+	}
+}
+
+type forkComposeStruct struct {
+	A *uint64
+	B customMarshalStruct `ssz:"since=altair"`
+}
+
+func TestForkContainerComposed(t *testing.T) {
+	fork, err := NewForkSelection(ForkSchedule{"phase0", "altair"}, "altair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := getTyp((*forkComposeStruct)(nil))
+	val := forkComposeStruct{B: customMarshalStruct{A: 1, B: 2}}
+
+	strictTyp, err := NewRegistry(ForkFactoryFn(fork, StrictNilPtrFactoryFn)).Get(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Encode(ioutil.Discard, &val, strictTyp); err == nil {
+		t.Fatal("expected error when encoding nil pointer with strict nil policy")
+	}
+
+	customTyp, err := NewRegistry(ForkFactoryFn(fork, MarshalerAwareFactoryFn)).Get(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	customCalls = 0
+	var buf bytes.Buffer
+	if _, err := Encode(&buf, &val, customTyp); err != nil {
+		t.Fatal(err)
+	}
+	if customCalls == 0 {
+		t.Fatal("expected custom marshaling methods to be used")
+	}
+	if res := fmt.Sprintf("%x", buf.Bytes()); res != "0000000000000000"+"0100"+"0200000000000000" {
+		t.Fatalf("encoded different data: %s", res)
+	}
+}
+
+// Custom marshaling of the altair layout, the other behavior is left to the fork container
+type forkCustomStruct struct {
+	A uint16
+	B uint64 `ssz:"since=altair"`
+}
+
+func (c *forkCustomStruct) SizeSSZ() int {
+	customCalls++
+	return 10
+}
+
+func (c *forkCustomStruct) MarshalSSZTo(buf []byte) ([]byte, error) {
+	customCalls++
+	var tmp [10]byte
+	binary.LittleEndian.PutUint16(tmp[0:2], c.A)
+	binary.LittleEndian.PutUint64(tmp[2:10], c.B)
+	return append(buf, tmp[:]...), nil
+}
+
+func TestForkContainerCustomMarshaling(t *testing.T) {
+	schedule := ForkSchedule{"phase0", "altair"}
+	typ := getTyp((*forkCustomStruct)(nil))
+	val := forkCustomStruct{A: 1, B: 2}
+	for _, fallback := range []ComposableFactoryFn{DefaultSSZFactory, MarshalerAwareFactoryFn} {
+		phase0, _ := NewForkSelection(schedule, "phase0")
+		if sszTyp, err := NewRegistry(ForkFactoryFn(phase0, fallback)).Get(typ); err != nil {
+			t.Fatal(err)
+		} else if sszTyp.FixedLen() != 2 {
+			t.Fatalf("expected phase0 fixed length 2, got %d", sszTyp.FixedLen())
+		}
+		altair, _ := NewForkSelection(schedule, "altair")
+		sszTyp, err := NewRegistry(ForkFactoryFn(altair, fallback)).Get(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		customCalls = 0
+		var buf bytes.Buffer
+		if _, err := Encode(&buf, &val, sszTyp); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		if customCalls == 0 {
+			t.Fatal("expected custom marshaling methods to be used")
+		}
+		if res := fmt.Sprintf("%x", data); res != "0100"+"0200000000000000" {
+			t.Fatalf("encoded different data: %s", res)
+		}
+		var dst forkCustomStruct
+		if err := Decode(bytes.NewReader(data), uint64(len(data)), &dst, sszTyp); err != nil {
+			t.Fatal(err)
+		}
+		if dst != val {
+			t.Fatalf("decoded different value: %v", dst)
+		}
+	}
+}
+
+func TestStableContainerInvalid(t *testing.T) {
+	sszTyp := GetSSZ((*Shape)(nil))
+	for _, input := range []string{
+		"08" + "0000",   // unknown field bit
+		"03" + "4200",   // missing data of active field
+		"01" + "420000", // too much data
+		"f1" + "4200",   // bits beyond the fields
+	} {
+		data, _ := hex.DecodeString(input)
+		if err := DryCheck(bytes.NewReader(data), uint64(len(data)), sszTyp); err == nil {
+			t.Errorf("expected dry check of %s to fail", input)
+		}
+		var dst Shape
+		if err := Decode(bytes.NewReader(data), uint64(len(data)), &dst, sszTyp); err == nil {
+			t.Errorf("expected decoding of %s to fail", input)
+		}
+	}
+}
+
+func TestStableContainerForkTags(t *testing.T) {
+	fork, err := NewForkSelection(ForkSchedule{"phase0", "altair"}, "altair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []reflect.Type{
+		getTyp((*struct {
+			_ stable.Container `ssz:"capacity=4"`
+			A *uint16          `ssz:"since=altair"`
+		})(nil)),
+		getTyp((*struct {
+			_    [0]Shape `ssz:"profile"`
+			Side uint16   `ssz:"until=altair"`
+		})(nil)),
+	} {
+		if _, err := SSZFactory(typ); err == nil {
+			t.Errorf("expected error for fork-specific field of %s", typ.String())
+		}
+		if _, err := NewRegistry(ForkFactoryFn(fork, DefaultSSZFactory)).Get(typ); err == nil {
+			t.Errorf("expected error for fork-specific field of %s with selected fork", typ.String())
+		}
+	}
+}
+
+func TestSeriesDimsInvalid(t *testing.T) {
+	sszTyp := GetSSZ((*taggedTestStruct)(nil))
+	var buf bytes.Buffer
+	if _, err := Encode(&buf, &taggedTestStruct{E: []byte{1}}, sszTyp); err == nil {
+		t.Fatal("expected error when encoding fixed-size slice with wrong length")
+	}
+	for _, typ := range []reflect.Type{
+		getTyp((*struct {
+			A [4]byte `ssz-max:"4"`
+		})(nil)),
+		getTyp((*struct {
+			A [4]byte `ssz-size:"3"`
+		})(nil)),
+		getTyp((*struct {
+			A []byte `ssz-max:"4,4"`
+		})(nil)),
+		getTyp((*struct {
+			A uint64 `ssz-max:"4"`
+		})(nil)),
+		getTyp((*struct {
+			A []byte `ssz-max:"x"`
+		})(nil)),
+		getTyp((*struct {
+			A []byte `ssz:"bitlist"`
+		})(nil)),
+	} {
+		if _, err := SSZFactory(typ); err == nil {
+			t.Errorf("expected error for invalid tags of type %s", typ.String())
+		}
+	}
+}
+
+type schemaTestStruct struct {
+	A uint16
+	B []uint32 `ssz-max:"4"`
+	C *[2]byte
+	D bitlist4
+	E testUnion
+}
+
+// custom SSZ definition, describing itself as a uint16
+type describedUint16 struct {
+	SSZUint16
+}
+
+func (describedUint16) Schema(describe func(SSZ) Schema) Schema {
+	return describe(SSZUint16{})
+}
+
+func TestDescribe(t *testing.T) {
+	schema := Describe(GetSSZ((*schemaTestStruct)(nil)))
+	data, err := json.Marshal(&schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uint16Schema := `{"kind":"uint16","is_fixed":true,"fixed_len":2,"min_len":2,"max_len":2}`
+	expected := `{"kind":"container","fields":[` +
+		`{"name":"A","type":` + uint16Schema + `},` +
+		`{"name":"B","type":{"kind":"list","limit":4,` +
+		`"elem":{"kind":"uint32","is_fixed":true,"fixed_len":4,"min_len":4,"max_len":4},` +
+		`"is_fixed":false,"fixed_len":0,"min_len":0,"max_len":16}},` +
+		`{"name":"C","type":{"kind":"vector","length":2,` +
+		`"elem":{"kind":"uint8","is_fixed":true,"fixed_len":1,"min_len":1,"max_len":1},` +
+		`"is_fixed":true,"fixed_len":2,"min_len":2,"max_len":2}},` +
+		`{"name":"D","type":{"kind":"bitlist","limit":4,"is_fixed":false,"fixed_len":0,"min_len":1,"max_len":1}},` +
+		`{"name":"E","type":{"kind":"union","options":[null,` +
+		`{"kind":"uint16","go_type":"uint16","is_fixed":true,"fixed_len":2,"min_len":2,"max_len":2},` +
+		`{"kind":"container","go_type":"zssz.smallTestStruct","fields":[` +
+		`{"name":"A","type":` + uint16Schema + `},{"name":"B","type":` + uint16Schema + `}],` +
+		`"is_fixed":true,"fixed_len":4,"min_len":4,"max_len":4}],` +
+		`"is_fixed":false,"fixed_len":0,"min_len":1,"max_len":5}}],` +
+		`"is_fixed":false,"fixed_len":16,"min_len":18,"max_len":38}`
+	if string(data) != expected {
+		t.Errorf("unexpected schema:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	if s := Describe(describedUint16{}); s.Kind != KindUint16 {
+		t.Errorf("expected custom schema of kind %s, got %s", KindUint16, s.Kind)
+	}
+	if s := Describe(struct{ SSZUint16 }{}); s.Kind != KindCustom {
+		t.Errorf("expected undescribed custom schema of kind %s, got %s", KindCustom, s.Kind)
+	}
+}
+
+type lockTestV1 struct {
+	A uint64
+	B []uint16 `ssz-max:"16"`
+	C [4]byte
+	D uint32
+}
+
+type lockTestV2 struct {
+	D uint32
+	A uint64
+	B []uint16 `ssz-max:"32"`
+	C [5]byte
+	E bool
+}
+
+type lockTestRenamed struct {
+	SlotNumber uint64
+	B          []uint16 `ssz-max:"16"`
+	C          [4]byte
+	D          uint32
+}
+
+type lockTestRootsV1 struct {
+	A [32]byte
+	B [32]byte
+}
+
+type lockTestRootsV2 struct {
+	B [32]byte
+	A [32]byte
+}
+
+type lockTestStableV1 struct {
+	_ stable.Container `ssz:"capacity=4"`
+	A *uint64
+	B *uint16
+}
+
+type lockTestStableV2 struct {
+	_ stable.Container `ssz:"capacity=4"`
+	B *uint16
+	A *uint64
+}
+
+type lockTestProfileV1 struct {
+	_ [0]lockTestStableV1 `ssz:"profile"`
+	A uint64
+	B uint16
+}
+
+type lockTestProfileV2 struct {
+	_ [0]lockTestStableV2 `ssz:"profile"`
+	B uint16
+	A uint64
+}
+
+func TestLockfile(t *testing.T) {
+	prevRegistry := NewRegistry(DefaultSSZFactory)
+	if _, err := prevRegistry.Register("Thing", getTyp((*lockTestV1)(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prevRegistry.Register("Old", getTyp((*smallTestStruct)(nil))); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := prevRegistry.Lockfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var committed Lockfile
+	if err := json.Unmarshal(data, &committed); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := prevRegistry.CheckLockfile(&committed); err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v", changes)
+	}
+	if a, b := Fingerprint(GetSSZ((*lockTestV1)(nil))), Fingerprint(GetSSZ((*lockTestV1)(nil))); a != b {
+		t.Fatal("fingerprint is not deterministic")
+	}
+
+	curRegistry := NewRegistry(DefaultSSZFactory)
+	if _, err := curRegistry.Register("Thing", getTyp((*lockTestV2)(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := curRegistry.Register("New", getTyp((*smallTestStruct)(nil))); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := curRegistry.CheckLockfile(&committed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	expected := []string{
+		"New: type added (added), affects encoding and merkleization",
+		"Thing.B: limit changed from 16 to 32 (modified), affects encoding",
+		"Thing.C: length changed from 4 to 5 (modified), affects encoding and merkleization",
+		"Thing.E: field added (added), affects encoding and merkleization",
+		"Thing: fields reordered, field A moved (modified), affects encoding and merkleization",
+		"Old: type deleted (deleted), affects encoding and merkleization",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected changes:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	diffCases := []struct {
+		name     string
+		prev     SSZ
+		cur      SSZ
+		expected []string
+	}{
+		{"renamed", GetSSZ((*lockTestV1)(nil)), GetSSZ((*lockTestRenamed)(nil)), []string{
+			"x.SlotNumber: field renamed from A to SlotNumber (modified), affects none",
+		}},
+		// fields of the same type are matched by name, not by position
+		{"swapped", GetSSZ((*lockTestRootsV1)(nil)), GetSSZ((*lockTestRootsV2)(nil)), []string{
+			"x: fields reordered, field A moved (modified), affects encoding and merkleization",
+		}},
+		// the active fields and the encoding order of stable containers follow the index
+		{"stable container", GetSSZ((*lockTestStableV1)(nil)), GetSSZ((*lockTestStableV2)(nil)), []string{
+			"x.B: index changed from 1 to 0 (modified), affects encoding and merkleization",
+			"x.A: index changed from 0 to 1 (modified), affects encoding and merkleization",
+			"x: fields reordered, field A moved (modified), affects encoding",
+		}},
+		// profiles encode their fields in order, without the active fields of the stable container
+		{"profile", GetSSZ((*lockTestProfileV1)(nil)), GetSSZ((*lockTestProfileV2)(nil)), []string{
+			"x.B: index changed from 1 to 0 (modified), affects merkleization",
+			"x.A: index changed from 0 to 1 (modified), affects merkleization",
+			"x: fields reordered, field A moved (modified), affects encoding",
+		}},
+	}
+	for _, tt := range diffCases {
+		got = got[:0]
+		for _, c := range DiffSchemas("x", Describe(tt.prev), Describe(tt.cur)) {
+			got = append(got, c.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: unexpected changes:\n%s\nexpected:\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+		}
+	}
+
+	// a limit change that changes the depth of the tree also affects merkleization
+	changes = DiffSchemas("x", Describe(GetSSZ((*uint16List128)(nil))), Describe(GetSSZ((*uint16List1024)(nil))))
+	if len(changes) != 1 || changes[0].Impact != ImpactBoth {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+}